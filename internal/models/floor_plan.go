@@ -0,0 +1,77 @@
+package models
+
+import (
+    "fmt"
+    "strings"
+    "time"
+)
+
+// Default size of a restaurant's floor plan grid, in cells, until staff set one.
+const (
+    DefaultFloorWidth  = 40
+    DefaultFloorHeight = 30
+)
+
+// TableShapes are the shapes a table can be drawn with.
+var TableShapes = []string{"rect", "square", "round", "oval"}
+
+// FloorPlan is the grid a restaurant's dining room is drawn on. Sections and
+// tables are placed on it in whole cells from the top-left corner.
+type FloorPlan struct {
+    RestaurantID string    `json:"restaurantId"`
+    Width        int       `json:"width"`
+    Height       int       `json:"height"`
+    UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+// FloorSection is an area of the plan such as the patio or the bar. Tables
+// belong to the section whose Name matches their Table.Section.
+type FloorSection struct {
+    ID           string    `json:"id"`
+    RestaurantID string    `json:"restaurantId"`
+    Name         string    `json:"name"` // lower-case, as in Table.Section
+    X            int       `json:"x"`
+    Y            int       `json:"y"`
+    Width        int       `json:"width"`
+    Height       int       `json:"height"`
+    CreatedAt    time.Time `json:"createdAt"`
+}
+
+// TablePosition is where a table is drawn on the plan. Rotation turns the
+// shape clockwise about its centre and does not change the cells it is
+// checked against.
+type TablePosition struct {
+    TableID      string    `json:"tableId"`
+    RestaurantID string    `json:"restaurantId"`
+    X            int       `json:"x"`
+    Y            int       `json:"y"`
+    Width        int       `json:"width"`
+    Height       int       `json:"height"`
+    Shape        string    `json:"shape"`    // one of TableShapes
+    Rotation     int       `json:"rotation"` // degrees, 0-359
+    UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+// Fits reports whether the w x h rectangle at (x, y) lies on the plan.
+func (p FloorPlan) Fits(x, y, w, h int) bool {
+    return x >= 0 && y >= 0 && w > 0 && h > 0 && x+w <= p.Width && y+h <= p.Height
+}
+
+// Normalize fills in the default shape and checks the position against the
+// plan.
+func (t *TablePosition) Normalize(plan FloorPlan) error {
+    t.Shape = strings.ToLower(strings.TrimSpace(t.Shape))
+    if t.Shape == "" {
+        t.Shape = "rect"
+    }
+    if !contains(TableShapes, t.Shape) {
+        return fmt.Errorf("unknown shape %q", t.Shape)
+    }
+    if t.Rotation < 0 || t.Rotation >= 360 {
+        return fmt.Errorf("rotation must be between 0 and 359")
+    }
+    if !plan.Fits(t.X, t.Y, t.Width, t.Height) {
+        return fmt.Errorf("table must lie within the %dx%d plan", plan.Width, plan.Height)
+    }
+    return nil
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// ServicePeriod is one opening window in a restaurant's weekly schedule, e.g.
+// Tuesday lunch 11:30-14:00. A CloseTime at or before OpenTime means the
+// period runs past midnight into the next day.
+type ServicePeriod struct {
+    ID           string       `json:"id"`
+    RestaurantID string       `json:"restaurantId"`
+    Weekday      time.Weekday `json:"weekday"` // 0 = Sunday ... 6 = Saturday
+    Name         string       `json:"name"`    // e.g., lunch, dinner
+    OpenTime     string       `json:"openTime"`
+    CloseTime    string       `json:"closeTime"`
+    CreatedAt    time.Time    `json:"createdAt"`
+}
+
+// HoursException overrides the weekly schedule on a single local date: either
+// a full closure or a replacement window (shortened or extended hours). As
+// with service periods, a CloseTime at or before OpenTime ends the next day.
+type HoursException struct {
+    ID           string    `json:"id"`
+    RestaurantID string    `json:"restaurantId"`
+    Date         string    `json:"date"` // YYYY-MM-DD in the restaurant's time zone
+    Closed       bool      `json:"closed"`
+    OpenTime     string    `json:"openTime,omitempty"`
+    CloseTime    string    `json:"closeTime,omitempty"`
+    Note         string    `json:"note"`
+    CreatedAt    time.Time `json:"createdAt"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// IdempotencyRecord remembers the response to a request sent with an
+// Idempotency-Key header so that retries get the same answer instead of
+// repeating the request.
+type IdempotencyRecord struct {
+    Key         string    // requesting user ID and the header value, see middleware.Idempotency
+    RequestHash string    // SHA-256 of method, path and body of the first request
+    Completed   bool      // false while the first request is still being handled
+    StatusCode  int
+    ContentType string
+    Body        []byte
+    CreatedAt   time.Time
+    ExpiresAt   time.Time
+}
+
+// Expired reports whether the record may be forgotten as of now.
+func (r *IdempotencyRecord) Expired(now time.Time) bool {
+    return !now.Before(r.ExpiresAt)
+}
@@ -2,15 +2,180 @@ package models
 
 import "time"
 
+// Reservation statuses. A reservation normally moves
+// pending -> confirmed -> seated -> completed, and can end as cancelled or
+// no_show instead. A held reservation keeps a table for a guest who is still
+// checking out; it becomes confirmed or lapses at HoldExpiresAt.
+const (
+    StatusHeld      = "held"
+    StatusPending   = "pending"
+    StatusConfirmed = "confirmed"
+    StatusSeated    = "seated"
+    StatusCompleted = "completed"
+    StatusNoShow    = "no_show"
+    StatusCancelled = "cancelled"
+)
+
+var transitions = map[string][]string{
+    StatusHeld:      {StatusConfirmed, StatusCancelled},
+    StatusPending:   {StatusConfirmed, StatusCancelled},
+    StatusConfirmed: {StatusSeated, StatusNoShow, StatusCancelled},
+    StatusSeated:    {StatusCompleted},
+}
+
+// CanTransition reports whether a reservation may move from one status to another.
+func CanTransition(from, to string) bool {
+    for _, s := range transitions[from] {
+        if s == to {
+            return true
+        }
+    }
+    return false
+}
+
+// TransitionsTo returns the statuses from which a reservation may move to status.
+func TransitionsTo(status string) []string {
+    var out []string
+    for from, tos := range transitions {
+        for _, to := range tos {
+            if to == status {
+                out = append(out, from)
+            }
+        }
+    }
+    return out
+}
+
+// HoldsTable reports whether reservations in status occupy their table.
+func HoldsTable(status string) bool {
+    return status == StatusHeld || status == StatusPending || status == StatusConfirmed || status == StatusSeated
+}
+
+// Booked reports whether reservations in status count as bookings in
+// statistics: awaiting confirmation, confirmed, seated or completed. Holds are
+// not bookings yet, and cancelled and no-show parties never dined.
+func Booked(status string) bool {
+    return status == StatusPending || status == StatusConfirmed || status == StatusSeated || status == StatusCompleted
+}
+
 type Reservation struct {
-    ID           string    `json:"id"`
-    RestaurantID string    `json:"restaurantId"`
-    TableID      string    `json:"tableId"`
-    UserID       string    `json:"userId"`
-    StartTime    time.Time `json:"startTime"`
-    EndTime      time.Time `json:"endTime"`
-    Guests       int       `json:"guests"`
-    Status       string    `json:"status"` // confirmed | cancelled
-    CreatedAt    time.Time `json:"createdAt"`
+    ID               string     `json:"id"`
+    RestaurantID     string     `json:"restaurantId"`
+    TableID          string     `json:"tableId"`                    // primary table
+    TableIDs         []string   `json:"tableIds"`                   // every table held, including TableID
+    CombinationID    string     `json:"combinationId,omitempty"`
+    SeriesID         string     `json:"seriesId,omitempty"`         // set on occurrences of a ReservationSeries
+    UserID           string     `json:"userId,omitempty"`           // empty for guest bookings
+    GuestName        string     `json:"guestName,omitempty"`
+    GuestPhone       string     `json:"guestPhone,omitempty"`
+    GuestEmail       string     `json:"guestEmail,omitempty"`
+    ConfirmationCode string     `json:"confirmationCode,omitempty"` // short code guests quote to manage a booking
+    ManageTokenHash  string     `json:"-"`                          // SHA-256 of the guest's manage token
+    StartTime        time.Time  `json:"startTime"`
+    EndTime          time.Time  `json:"endTime"`
+    Guests           int        `json:"guests"`
+    TurnoverMinutes  int        `json:"turnoverMinutes,omitempty"`  // table reset time after EndTime
+    Status           string     `json:"status"`                     // see Status* constants
+    HoldExpiresAt    *time.Time `json:"holdExpiresAt,omitempty"`    // set while Status is held
+    CreatedAt        time.Time  `json:"createdAt"`
+    ConfirmedAt      *time.Time `json:"confirmedAt,omitempty"`
+    SeatedAt         *time.Time `json:"seatedAt,omitempty"`
+    CompletedAt      *time.Time `json:"completedAt,omitempty"`
+    NoShowAt         *time.Time `json:"noShowAt,omitempty"`
+    CancelledAt      *time.Time `json:"cancelledAt,omitempty"`
+    LateCancel       bool       `json:"lateCancel,omitempty"`       // cancelled after the restaurant's deadline
+    CancelReason     string     `json:"cancelReason,omitempty"`
+    SpecialRequests                                                 // occasion, dietary, accessibility and notes
+}
+
+// Tables returns every table the reservation holds. TableID is always the
+// first entry; if TableIDs disagrees, only TableID was changed and it wins.
+func (r *Reservation) Tables() []string {
+    if len(r.TableIDs) == 0 || r.TableIDs[0] != r.TableID {
+        return []string{r.TableID}
+    }
+    return r.TableIDs
 }
 
+// UsesTable reports whether tableID is one of the reservation's tables.
+func (r *Reservation) UsesTable(tableID string) bool {
+    for _, id := range r.Tables() {
+        if id == tableID {
+            return true
+        }
+    }
+    return false
+}
+
+// EffectiveEnd is when the table becomes free again as seen at now: a seated
+// party that stays past its booked end keeps the table until completed.
+func (r *Reservation) EffectiveEnd(now time.Time) time.Time {
+    if r.Status == StatusSeated && now.After(r.EndTime) {
+        return now
+    }
+    return r.EndTime
+}
+
+// ClearAt is when the table is ready for the next party as seen at now: the
+// effective end plus the turnover time recorded with the booking.
+func (r *Reservation) ClearAt(now time.Time) time.Time {
+    return r.EffectiveEnd(now).Add(time.Duration(r.TurnoverMinutes) * time.Minute)
+}
+
+// HoldExpired reports whether the reservation is a hold that has lapsed by now.
+func (r *Reservation) HoldExpired(now time.Time) bool {
+    return r.Status == StatusHeld && r.HoldExpiresAt != nil && !now.Before(*r.HoldExpiresAt)
+}
+
+// Occupies reports whether the reservation holds its table at any point in
+// [start, end), including its turnover time, as seen at now.
+func (r *Reservation) Occupies(start, end, now time.Time) bool {
+    return HoldsTable(r.Status) && !r.HoldExpired(now) && r.StartTime.Before(end) && r.ClearAt(now).After(start)
+}
+
+// AsOf returns a copy of the reservation in the status it had at at, worked
+// out from its lifecycle timestamps, or nil if it had not been made yet.
+// Changes to its time, party size or tables are not recorded, so the copy
+// keeps the current ones.
+func (r *Reservation) AsOf(at time.Time) *Reservation {
+    if r.CreatedAt.After(at) {
+        return nil
+    }
+    reached := func(t *time.Time) bool { return t != nil && !t.After(at) }
+    cp := *r
+    switch {
+    case reached(r.CancelledAt):
+        cp.Status = StatusCancelled
+    case reached(r.NoShowAt):
+        cp.Status = StatusNoShow
+    case reached(r.CompletedAt):
+        cp.Status = StatusCompleted
+    case reached(r.SeatedAt):
+        cp.Status = StatusSeated
+    case reached(r.ConfirmedAt):
+        cp.Status = StatusConfirmed
+    case r.HoldExpiresAt != nil:
+        cp.Status = StatusHeld
+    default:
+        cp.Status = StatusPending
+    }
+    return &cp
+}
+
+// SetStatus changes the status and records when it happened.
+func (r *Reservation) SetStatus(status string, at time.Time) {
+    r.Status = status
+    t := at
+    switch status {
+    case StatusConfirmed:
+        r.ConfirmedAt = &t
+    case StatusSeated:
+        r.SeatedAt = &t
+    case StatusCompleted:
+        r.CompletedAt = &t
+    case StatusNoShow:
+        r.NoShowAt = &t
+    case StatusCancelled:
+        r.CancelledAt = &t
+    }
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+    "testing"
+    "time"
+)
+
+func TestReservationAsOf(t *testing.T) {
+    base := time.Date(2026, 3, 3, 18, 0, 0, 0, time.UTC)
+    at := func(m int) *time.Time { ts := base.Add(time.Duration(m) * time.Minute); return &ts }
+    r := &Reservation{
+        Status:      StatusCompleted,
+        CreatedAt:   base,
+        ConfirmedAt: at(0),
+        SeatedAt:    at(60),
+        CompletedAt: at(150),
+        StartTime:   base.Add(time.Hour),
+        EndTime:     base.Add(2 * time.Hour),
+    }
+    if r.AsOf(base.Add(-time.Minute)) != nil {
+        t.Fatalf("expected no reservation before it was made")
+    }
+    cases := []struct {
+        minutes int
+        want    string
+    }{
+        {30, StatusConfirmed},
+        {60, StatusSeated},
+        {149, StatusSeated},
+        {150, StatusCompleted},
+    }
+    for _, c := range cases {
+        if got := r.AsOf(base.Add(time.Duration(c.minutes) * time.Minute)); got == nil || got.Status != c.want {
+            t.Errorf("at +%dm: got %v, want %s", c.minutes, got, c.want)
+        }
+    }
+    if r.Status != StatusCompleted {
+        t.Fatalf("AsOf must not change the reservation, got %s", r.Status)
+    }
+}
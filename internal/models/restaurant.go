@@ -1,13 +1,165 @@
 package models
 
-import "time"
+import (
+    "sort"
+    "strings"
+    "time"
+)
+
+// DefaultTimeZone is used for restaurants created before time zones were stored.
+const DefaultTimeZone = "Asia/Shanghai"
+
+// DefaultDurationMinutes is the dining time assumed when neither the booking
+// nor the restaurant's settings give one.
+const DefaultDurationMinutes = 120
 
 type Restaurant struct {
-    ID        string    `json:"id"`
-    Name      string    `json:"name"`
-    Address   string    `json:"address"`
-    OpenTime  string    `json:"openTime"`  // e.g., 10:00
-    CloseTime string    `json:"closeTime"` // e.g., 22:00
-    CreatedAt time.Time `json:"createdAt"`
+    ID         string             `json:"id"`
+    Name       string             `json:"name"`
+    Address    string             `json:"address"`
+    OpenTime   string             `json:"openTime"`             // e.g., 10:00
+    CloseTime  string             `json:"closeTime"`            // e.g., 22:00
+    TimeZone   string             `json:"timeZone"`             // IANA name, e.g., Asia/Shanghai
+    Settings   RestaurantSettings `json:"settings"`
+    CreatedAt  time.Time          `json:"createdAt"`
+    ArchivedAt *time.Time         `json:"archivedAt,omitempty"` // set while archived: hidden and closed to new bookings
+}
+
+// RestaurantSettings is the restaurant's booking configuration.
+type RestaurantSettings struct {
+    DefaultDurationMinutes int                `json:"defaultDurationMinutes"` // 0 means DefaultDurationMinutes
+    TurnoverMinutes        int                `json:"turnoverMinutes"`        // cleanup time after each booking
+    PartyDurations         []PartyDuration    `json:"partyDurations,omitempty"`
+    Cancellation           CancellationPolicy `json:"cancellation"`
+    BookingRules           BookingRules       `json:"bookingRules"`
+    Pacing                 PacingRules        `json:"pacing"`
+}
+
+// PacingRules cap how many parties and guests may start within any window of
+// IntervalMinutes, so the kitchen is not swamped even when tables are free.
+// Zero limits are off.
+type PacingRules struct {
+    IntervalMinutes int            `json:"intervalMinutes"` // window length; 0 means 15
+    MaxGuests       int            `json:"maxGuests"`
+    MaxParties      int            `json:"maxParties"`
+    Periods         []PeriodPacing `json:"periods,omitempty"` // overrides for named service periods
+}
+
+// PeriodPacing replaces the pacing limits during service periods of that name.
+type PeriodPacing struct {
+    Period     string `json:"period"` // ServicePeriod.Name, e.g. dinner
+    MaxGuests  int    `json:"maxGuests"`
+    MaxParties int    `json:"maxParties"`
+}
+
+// Interval is the length of the pacing window.
+func (p PacingRules) Interval() time.Duration {
+    if p.IntervalMinutes > 0 {
+        return time.Duration(p.IntervalMinutes) * time.Minute
+    }
+    return 15 * time.Minute
+}
+
+// Enabled reports whether any pacing limit is set.
+func (p PacingRules) Enabled() bool {
+    if p.MaxGuests > 0 || p.MaxParties > 0 {
+        return true
+    }
+    for _, o := range p.Periods {
+        if o.MaxGuests > 0 || o.MaxParties > 0 {
+            return true
+        }
+    }
+    return false
+}
+
+// LimitsFor returns the limits that apply during the named service period;
+// "" means outside any period.
+func (p PacingRules) LimitsFor(period string) (maxGuests, maxParties int) {
+    for _, o := range p.Periods {
+        if period != "" && strings.EqualFold(o.Period, period) {
+            return o.MaxGuests, o.MaxParties
+        }
+    }
+    return p.MaxGuests, p.MaxParties
+}
+
+// BookingRules limit which reservations can be made. Zero values mean no limit.
+type BookingRules struct {
+    MinLeadMinutes       int `json:"minLeadMinutes"`       // how far ahead of its start a booking must be made
+    MaxAdvanceDays       int `json:"maxAdvanceDays"`       // how far ahead bookings open
+    MinPartySize         int `json:"minPartySize"`
+    MaxPartySize         int `json:"maxPartySize"`
+    StartIntervalMinutes int `json:"startIntervalMinutes"` // starts fall on multiples of this past local midnight
 }
 
+// Window returns the earliest and latest start a booking made at now may
+// have; latest is zero when there is no advance limit.
+func (b BookingRules) Window(now time.Time) (earliest, latest time.Time) {
+    earliest = now.Add(time.Duration(b.MinLeadMinutes) * time.Minute)
+    if b.MaxAdvanceDays > 0 {
+        latest = now.AddDate(0, 0, b.MaxAdvanceDays)
+    }
+    return earliest, latest
+}
+
+// CancellationPolicy limits when guests may cancel on their own. Admins can
+// always cancel; cancellations after the deadline are recorded as late.
+type CancellationPolicy struct {
+    DeadlineHours   int  `json:"deadlineHours"`   // no self-service cancellation within this many hours of the start
+    AllowLateCancel bool `json:"allowLateCancel"` // let guests cancel after the deadline, recorded as late
+}
+
+// Deadline is the last moment a reservation starting at start can be
+// cancelled without counting as late.
+func (p CancellationPolicy) Deadline(start time.Time) time.Time {
+    return start.Add(-time.Duration(p.DeadlineHours) * time.Hour)
+}
+
+// PartyDuration sets the dining time for parties of up to MaxGuests.
+type PartyDuration struct {
+    MaxGuests int `json:"maxGuests"`
+    Minutes   int `json:"minutes"`
+}
+
+// DurationFor returns how long a party of guests is booked for when the
+// request gives only a start time.
+func (s RestaurantSettings) DurationFor(guests int) time.Duration {
+    rules := append([]PartyDuration(nil), s.PartyDurations...)
+    sort.Slice(rules, func(i, j int) bool { return rules[i].MaxGuests < rules[j].MaxGuests })
+    for _, p := range rules {
+        if guests <= p.MaxGuests {
+            return time.Duration(p.Minutes) * time.Minute
+        }
+    }
+    if s.DefaultDurationMinutes > 0 {
+        return time.Duration(s.DefaultDurationMinutes) * time.Minute
+    }
+    return DefaultDurationMinutes * time.Minute
+}
+
+// Turnover is the time a table needs to be cleared and reset after a booking.
+func (s RestaurantSettings) Turnover() time.Duration {
+    return time.Duration(s.TurnoverMinutes) * time.Minute
+}
+
+// Location returns the restaurant's time zone, falling back to DefaultTimeZone
+// (and finally UTC+8) when the stored name is empty or cannot be loaded.
+func (r *Restaurant) Location() *time.Location {
+    name := r.TimeZone
+    if name == "" {
+        name = DefaultTimeZone
+    }
+    if loc, err := time.LoadLocation(name); err == nil {
+        return loc
+    }
+    if loc, err := time.LoadLocation(DefaultTimeZone); err == nil {
+        return loc
+    }
+    return time.FixedZone("CST", 8*3600)
+}
+
+// Archived reports whether the restaurant has been archived.
+func (r *Restaurant) Archived() bool {
+    return r.ArchivedAt != nil
+}
@@ -0,0 +1,136 @@
+package models
+
+import (
+    "errors"
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Recurrence frequencies supported in series rules.
+const (
+    FreqWeekly  = "WEEKLY"
+    FreqMonthly = "MONTHLY"
+)
+
+// Series statuses.
+const (
+    SeriesActive    = "active"
+    SeriesCancelled = "cancelled"
+)
+
+// ReservationSeries is a recurring booking, e.g. every Tuesday at 12:00. Its
+// occurrences are ordinary reservations that carry the series ID, so each can
+// be changed or cancelled on its own.
+type ReservationSeries struct {
+    ID              string    `json:"id"`
+    RestaurantID    string    `json:"restaurantId"`
+    UserID          string    `json:"userId"`
+    Rule            string    `json:"rule"`       // RRULE, e.g. FREQ=WEEKLY;COUNT=10
+    FirstStart      time.Time `json:"firstStart"` // start of the first occurrence; later ones keep its local time
+    DurationMinutes int       `json:"durationMinutes"`
+    Guests          int       `json:"guests"`
+    TableID         string    `json:"tableId,omitempty"` // requested table or combination, if any
+    CombinationID   string    `json:"combinationId,omitempty"`
+    Status          string    `json:"status"`
+    CreatedAt       time.Time `json:"createdAt"`
+}
+
+// RecurrenceRule is the supported subset of an RFC 5545 RRULE: FREQ=WEEKLY or
+// FREQ=MONTHLY, an optional INTERVAL, and an end given by COUNT or UNTIL.
+type RecurrenceRule struct {
+    Freq     string
+    Interval int       // 1 = every week or month
+    Count    int       // number of occurrences, or 0
+    Until    time.Time // last possible start (inclusive), or zero
+}
+
+// ParseRecurrenceRule parses a rule such as "FREQ=WEEKLY;INTERVAL=2;COUNT=8"
+// or "FREQ=MONTHLY;UNTIL=20261231". A date-only UNTIL means the end of that
+// day in loc; an "RRULE:" prefix is accepted.
+func ParseRecurrenceRule(s string, loc *time.Location) (RecurrenceRule, error) {
+    r := RecurrenceRule{Interval: 1}
+    s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+    for _, part := range strings.Split(s, ";") {
+        if part == "" {
+            continue
+        }
+        kv := strings.SplitN(part, "=", 2)
+        if len(kv) != 2 {
+            return r, fmt.Errorf("invalid rule part %q", part)
+        }
+        key, val := strings.ToUpper(strings.TrimSpace(kv[0])), strings.TrimSpace(kv[1])
+        switch key {
+        case "FREQ":
+            r.Freq = strings.ToUpper(val)
+        case "INTERVAL":
+            n, err := strconv.Atoi(val)
+            if err != nil || n < 1 || n > 12 {
+                return r, errors.New("INTERVAL must be between 1 and 12")
+            }
+            r.Interval = n
+        case "COUNT":
+            n, err := strconv.Atoi(val)
+            if err != nil || n < 1 {
+                return r, errors.New("COUNT must be a positive number")
+            }
+            r.Count = n
+        case "UNTIL":
+            t, err := parseUntil(val, loc)
+            if err != nil {
+                return r, err
+            }
+            r.Until = t
+        default:
+            return r, fmt.Errorf("unsupported rule part %s", key)
+        }
+    }
+    if r.Freq != FreqWeekly && r.Freq != FreqMonthly {
+        return r, errors.New("FREQ must be WEEKLY or MONTHLY")
+    }
+    if (r.Count == 0) == r.Until.IsZero() {
+        return r, errors.New("rule needs exactly one of COUNT or UNTIL")
+    }
+    return r, nil
+}
+
+func parseUntil(v string, loc *time.Location) (time.Time, error) {
+    if t, err := time.ParseInLocation("20060102", v, loc); err == nil {
+        return t.Add(24*time.Hour - time.Nanosecond), nil
+    }
+    if t, err := time.Parse("20060102T150405Z", v); err == nil {
+        return t, nil
+    }
+    if t, err := time.Parse(time.RFC3339, v); err == nil {
+        return t, nil
+    }
+    return time.Time{}, errors.New("UNTIL must be YYYYMMDD or YYYYMMDDTHHMMSSZ")
+}
+
+// Occurrences returns the starts the rule generates beginning with first,
+// keeping first's local wall-clock time, and at most max of them. As in RFC
+// 5545, monthly rules skip months that lack first's day of the month.
+func (r RecurrenceRule) Occurrences(first time.Time, max int) []time.Time {
+    var out []time.Time
+    // months without the day (e.g. the 31st) are skipped, so bound the scan
+    for i := 0; len(out) < max && i < 4*max; i++ {
+        var t time.Time
+        if r.Freq == FreqMonthly {
+            t = time.Date(first.Year(), first.Month()+time.Month(i*r.Interval), first.Day(), first.Hour(), first.Minute(), first.Second(), 0, first.Location())
+            if t.Day() != first.Day() {
+                continue
+            }
+        } else {
+            t = first.AddDate(0, 0, 7*i*r.Interval)
+        }
+        if !r.Until.IsZero() && t.After(r.Until) {
+            break
+        }
+        out = append(out, t)
+        if r.Count > 0 && len(out) >= r.Count {
+            break
+        }
+    }
+    return out
+}
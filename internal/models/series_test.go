@@ -0,0 +1,53 @@
+package models
+
+import (
+    "testing"
+    "time"
+)
+
+func TestRecurrenceOccurrences(t *testing.T) {
+    loc := time.UTC
+    day := func(m time.Month, d int) time.Time { return time.Date(2026, m, d, 19, 0, 0, 0, loc) }
+    cases := []struct {
+        rule  string
+        first time.Time
+        want  []time.Time
+    }{
+        {"FREQ=WEEKLY;COUNT=3", day(3, 3), []time.Time{day(3, 3), day(3, 10), day(3, 17)}},
+        {"RRULE:FREQ=WEEKLY;INTERVAL=2;UNTIL=20260331", day(3, 3), []time.Time{day(3, 3), day(3, 17), day(3, 31)}},
+        // April has no 31st, so the series skips it
+        {"FREQ=MONTHLY;COUNT=3", day(3, 31), []time.Time{day(3, 31), day(5, 31), day(7, 31)}},
+        {"FREQ=MONTHLY;UNTIL=20260615T000000Z", day(4, 15), []time.Time{day(4, 15), day(5, 15)}},
+    }
+    for _, c := range cases {
+        r, err := ParseRecurrenceRule(c.rule, loc)
+        if err != nil {
+            t.Fatalf("%s: %v", c.rule, err)
+        }
+        got := r.Occurrences(c.first, 52)
+        if len(got) != len(c.want) {
+            t.Fatalf("%s: got %v, want %v", c.rule, got, c.want)
+        }
+        for i := range got {
+            if !got[i].Equal(c.want[i]) {
+                t.Errorf("%s: occurrence %d is %v, want %v", c.rule, i, got[i], c.want[i])
+            }
+        }
+    }
+}
+
+func TestParseRecurrenceRuleErrors(t *testing.T) {
+    for _, rule := range []string{
+        "",
+        "FREQ=DAILY;COUNT=3",
+        "FREQ=WEEKLY",
+        "FREQ=WEEKLY;COUNT=3;UNTIL=20261231",
+        "FREQ=WEEKLY;INTERVAL=0;COUNT=3",
+        "FREQ=WEEKLY;BYDAY=MO;COUNT=3",
+        "FREQ=WEEKLY;UNTIL=tomorrow",
+    } {
+        if _, err := ParseRecurrenceRule(rule, time.UTC); err == nil {
+            t.Errorf("%q: expected an error", rule)
+        }
+    }
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+    "fmt"
+    "strings"
+)
+
+// The occasions, dietary restrictions and accessibility needs a guest can
+// pick; anything else goes into the free-text notes. Allergies end in
+// "_allergy".
+var (
+    Occasions           = []string{"birthday", "anniversary", "date", "business", "celebration"}
+    DietaryRestrictions = []string{"vegetarian", "vegan", "gluten_free", "dairy_free", "halal", "kosher", "nut_allergy", "shellfish_allergy", "egg_allergy", "soy_allergy", "other_allergy"}
+    AccessibilityNeeds  = []string{"wheelchair", "step_free", "high_chair", "stroller", "service_animal", "hearing", "visual"}
+)
+
+// MaxNotesLen bounds the free-text notes on a reservation.
+const MaxNotesLen = 500
+
+// SpecialRequests is what a party asks the restaurant for beyond a table.
+type SpecialRequests struct {
+    Occasion      string            `json:"occasion,omitempty"`      // one of Occasions
+    Dietary       []string          `json:"dietary,omitempty"`       // from DietaryRestrictions
+    Accessibility []string          `json:"accessibility,omitempty"` // from AccessibilityNeeds
+    Notes         string            `json:"notes,omitempty"`         // e.g. "window seat please"
+    Seating       *TablePreferences `json:"seating,omitempty"`       // soft table preferences
+}
+
+// Normalize trims and lower-cases the codes, drops duplicates and checks them
+// against the fixed lists.
+func (s *SpecialRequests) Normalize() error {
+    s.Occasion = strings.ToLower(strings.TrimSpace(s.Occasion))
+    if s.Occasion != "" && !contains(Occasions, s.Occasion) {
+        return fmt.Errorf("unknown occasion %q", s.Occasion)
+    }
+    var err error
+    if s.Dietary, err = normalizeCodes(s.Dietary, DietaryRestrictions, "dietary restriction"); err != nil {
+        return err
+    }
+    if s.Accessibility, err = normalizeCodes(s.Accessibility, AccessibilityNeeds, "accessibility need"); err != nil {
+        return err
+    }
+    s.Notes = strings.TrimSpace(s.Notes)
+    if len(s.Notes) > MaxNotesLen {
+        return fmt.Errorf("notes must be at most %d characters", MaxNotesLen)
+    }
+    if s.Seating != nil {
+        s.Seating.Section = strings.ToLower(strings.TrimSpace(s.Seating.Section))
+    }
+    return nil
+}
+
+// NeedsAccessibleTable reports whether the party can only be seated at
+// wheelchair-accessible tables. Unlike Seating this is a hard requirement.
+func (s SpecialRequests) NeedsAccessibleTable() bool {
+    return contains(s.Accessibility, "wheelchair") || contains(s.Accessibility, "step_free")
+}
+
+// HasAllergy reports whether any dietary restriction is an allergy.
+func (s SpecialRequests) HasAllergy() bool {
+    for _, d := range s.Dietary {
+        if strings.HasSuffix(d, "_allergy") {
+            return true
+        }
+    }
+    return false
+}
+
+// Empty reports whether nothing was requested.
+func (s SpecialRequests) Empty() bool {
+    return s.Occasion == "" && len(s.Dietary) == 0 && len(s.Accessibility) == 0 && s.Notes == "" && s.Seating == nil
+}
+
+func normalizeCodes(in, allowed []string, what string) ([]string, error) {
+    var out []string
+    for _, c := range in {
+        c = strings.ToLower(strings.TrimSpace(c))
+        if !contains(allowed, c) {
+            return nil, fmt.Errorf("unknown %s %q", what, c)
+        }
+        if !contains(out, c) {
+            out = append(out, c)
+        }
+    }
+    return out, nil
+}
+
+func contains(list []string, s string) bool {
+    for _, v := range list {
+        if v == s {
+            return true
+        }
+    }
+    return false
+}
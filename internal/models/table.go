@@ -1,12 +1,74 @@
 package models
 
-import "time"
+import (
+    "strings"
+    "time"
+)
 
 type Table struct {
+    ID           string     `json:"id"`
+    RestaurantID string     `json:"restaurantId"`
+    Name         string     `json:"name"`
+    Capacity     int        `json:"capacity"`
+    Section      string     `json:"section,omitempty"`   // area such as patio, bar or main
+    Accessible   bool       `json:"accessible"`          // wheelchair accessible
+    HighTop      bool       `json:"highTop"`
+    Booth        bool       `json:"booth"`
+    Smoking      bool       `json:"smoking"`
+    CreatedAt    time.Time  `json:"createdAt"`
+    DeletedAt    *time.Time `json:"deletedAt,omitempty"` // set once the table is taken out of use
+}
+
+// TableBlock takes a table out of service for a while, e.g. for a repair or
+// a private setup. Availability treats the table as occupied during the block.
+type TableBlock struct {
+    ID           string    `json:"id"`
+    RestaurantID string    `json:"restaurantId"`
+    TableID      string    `json:"tableId"`
+    Start        time.Time `json:"start"`
+    End          time.Time `json:"end"`
+    Reason       string    `json:"reason,omitempty"`
+    CreatedAt    time.Time `json:"createdAt"`
+}
+
+// TablePreferences are a party's soft wishes for where to sit. A nil flag
+// means no preference either way, so {"smoking": false} asks to avoid
+// smoking tables.
+type TablePreferences struct {
+    Section string `json:"section,omitempty"`
+    HighTop *bool  `json:"highTop,omitempty"`
+    Booth   *bool  `json:"booth,omitempty"`
+    Smoking *bool  `json:"smoking,omitempty"`
+}
+
+// Misses counts the preferences t does not meet; a nil p has none.
+func (p *TablePreferences) Misses(t *Table) int {
+    if p == nil {
+        return 0
+    }
+    n := 0
+    if p.Section != "" && !strings.EqualFold(p.Section, t.Section) {
+        n++
+    }
+    for _, f := range []struct {
+        want *bool
+        has  bool
+    }{{p.HighTop, t.HighTop}, {p.Booth, t.Booth}, {p.Smoking, t.Smoking}} {
+        if f.want != nil && *f.want != f.has {
+            n++
+        }
+    }
+    return n
+}
+
+// TableCombination is a set of tables that staff can join for one large
+// party. Capacity is the seats available when joined, which can be less than
+// the sum of the individual tables.
+type TableCombination struct {
     ID           string    `json:"id"`
     RestaurantID string    `json:"restaurantId"`
     Name         string    `json:"name"`
+    TableIDs     []string  `json:"tableIds"`
     Capacity     int       `json:"capacity"`
     CreatedAt    time.Time `json:"createdAt"`
 }
-
@@ -3,11 +3,12 @@ package models
 import "time"
 
 type User struct {
-    ID        string    `json:"id"`
-    Name      string    `json:"name"`
-    Email     string    `json:"email"`
-    PassHash  string    `json:"-"`
-    Role      string    `json:"role"` // user | admin
-    CreatedAt time.Time `json:"createdAt"`
+    ID          string    `json:"id"`
+    Name        string    `json:"name"`
+    Email       string    `json:"email"`
+    PassHash    string    `json:"-"`
+    Role        string    `json:"role"`                  // user | admin
+    LimitExempt bool      `json:"limitExempt,omitempty"` // trusted account not subject to booking limits
+    CreatedAt   time.Time `json:"createdAt"`
 }
 
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Waitlist entry statuses.
+const (
+    WaitlistWaiting = "waiting"
+    WaitlistOffered = "offered" // a freed table was offered; claim before OfferExpiresAt
+    WaitlistBooked  = "booked"
+    WaitlistLeft    = "left"
+    WaitlistExpired = "expired" // the offer was not claimed in time; the party is out of line
+)
+
+// WaitlistEntry is a party waiting for a table to free up at a restaurant.
+// Any start between WindowStart and WindowEnd suits the party.
+type WaitlistEntry struct {
+    ID              string     `json:"id"`
+    RestaurantID    string     `json:"restaurantId"`
+    UserID          string     `json:"userId"`
+    Date            string     `json:"date"` // YYYY-MM-DD of WindowStart in the restaurant's time zone
+    WindowStart     time.Time  `json:"windowStart"`
+    WindowEnd       time.Time  `json:"windowEnd"`
+    Guests          int        `json:"guests"`
+    DurationMinutes int        `json:"durationMinutes"`
+    AutoBook        bool       `json:"autoBook"` // book a freed table directly instead of offering it
+    Status          string     `json:"status"`   // see Waitlist* constants
+    OfferStart      *time.Time `json:"offerStart,omitempty"`
+    OfferExpiresAt  *time.Time `json:"offerExpiresAt,omitempty"`
+    ClaimToken      string     `json:"claimToken,omitempty"`
+    ReservationID   string     `json:"reservationId,omitempty"`
+    CreatedAt       time.Time  `json:"createdAt"`
+}
+
+// Waiting reports whether the entry can be offered a table.
+func (e *WaitlistEntry) Waiting() bool {
+    return e.Status == WaitlistWaiting
+}
+
+// OfferExpired reports whether the entry holds an offer that was not claimed
+// by now.
+func (e *WaitlistEntry) OfferExpired(now time.Time) bool {
+    return e.Status == WaitlistOffered && e.OfferExpiresAt != nil && now.After(*e.OfferExpiresAt)
+}
+
+// Duration is how long the party wants the table for.
+func (e *WaitlistEntry) Duration() time.Duration {
+    return time.Duration(e.DurationMinutes) * time.Minute
+}
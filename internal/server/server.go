@@ -5,9 +5,11 @@ import (
     "log"
     "net/http"
     "os"
+    "strconv"
     "time"
 
     "orderation/internal/auth"
+    "orderation/internal/models"
     "orderation/internal/store"
     mysqlstore "orderation/internal/store/mysql"
     memorystore "orderation/internal/store/memory"
@@ -28,6 +30,14 @@ func New() *Server {
     var restaurantStore store.RestaurantStore
     var tableStore store.TableStore
     var reservationStore store.ReservationStore
+    var hoursStore store.HoursStore
+    var exceptionStore store.ExceptionStore
+    var combinationStore store.TableCombinationStore
+    var tableBlockStore store.TableBlockStore
+    var floorPlanStore store.FloorPlanStore
+    var waitlistStore store.WaitlistStore
+    var seriesStore store.SeriesStore
+    var idempotencyStore store.IdempotencyStore
 
     // Try to initialize MySQL connection based on available configuration
     config := mysqlstore.NewConfigFromEnv()
@@ -36,7 +46,7 @@ func New() *Server {
         if err != nil {
             log.Printf("[warn] failed to connect to MySQL (%s:%d): %v", config.Host, config.Port, err)
             log.Println("[info] falling back to in-memory store")
-            initMemoryStores(&userStore, &restaurantStore, &tableStore, &reservationStore)
+            initMemoryStores(&userStore, &restaurantStore, &tableStore, &reservationStore, &hoursStore, &exceptionStore, &combinationStore, &tableBlockStore, &floorPlanStore, &waitlistStore, &seriesStore, &idempotencyStore)
         } else {
             ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
             defer cancel()
@@ -47,10 +57,18 @@ func New() *Server {
             restaurantStore = mysqlstore.NewRestaurantStore(db)
             tableStore = mysqlstore.NewTableStore(db)
             reservationStore = mysqlstore.NewReservationStore(db)
+            hoursStore = mysqlstore.NewHoursStore(db)
+            exceptionStore = mysqlstore.NewExceptionStore(db)
+            combinationStore = mysqlstore.NewTableCombinationStore(db)
+            tableBlockStore = mysqlstore.NewTableBlockStore(db)
+            floorPlanStore = mysqlstore.NewFloorPlanStore(db)
+            waitlistStore = mysqlstore.NewWaitlistStore(db)
+            seriesStore = mysqlstore.NewSeriesStore(db)
+            idempotencyStore = mysqlstore.NewIdempotencyStore(db)
             log.Printf("[info] using MySQL store (%s:%d)", config.Host, config.Port)
         }
     } else {
-        initMemoryStores(&userStore, &restaurantStore, &tableStore, &reservationStore)
+        initMemoryStores(&userStore, &restaurantStore, &tableStore, &reservationStore, &hoursStore, &exceptionStore, &combinationStore, &tableBlockStore, &floorPlanStore, &waitlistStore, &seriesStore, &idempotencyStore)
     }
 
     // Auth setup
@@ -68,14 +86,19 @@ func New() *Server {
 
     // Handlers
     ah := h.NewAuthHandler(userStore, pass, token)
-    rh := h.NewRestaurantHandler(restaurantStore, tableStore, reservationStore)
-    th := h.NewTableHandler(restaurantStore, tableStore)
-    resvh := h.NewReservationHandler(reservationStore, restaurantStore, tableStore, userStore)
+    rh := h.NewRestaurantHandler(restaurantStore, tableStore, reservationStore, hoursStore, exceptionStore, combinationStore, tableBlockStore, floorPlanStore, seriesStore, waitlistStore)
+    hh := h.NewHoursHandler(restaurantStore, hoursStore, exceptionStore)
+    resvh := h.NewReservationHandler(reservationStore, restaurantStore, tableStore, userStore, hoursStore, exceptionStore, combinationStore, waitlistStore, seriesStore, tableBlockStore, bookingLimits())
+    th := h.NewTableHandler(restaurantStore, tableStore, combinationStore, tableBlockStore, resvh)
+    uh := h.NewUserHandler(userStore)
+
+    go sweepExpired(reservationStore, idempotencyStore, resvh, time.Minute)
 
     // Static files first, before router
     mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("./web/"))))
     
     r := router.New(mux)
+    r.Use(middleware.Idempotency(token, idempotencyStore, idempotencyTTL()))
 
     // Health
     r.Handle("GET", "/healthz", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -97,28 +120,160 @@ func New() *Server {
     r.Handle("POST", "/api/v1/auth/register", http.HandlerFunc(ah.Register))
     r.Handle("POST", "/api/v1/auth/login", http.HandlerFunc(ah.Login))
 
+    // Users
+    r.Handle("PATCH", "/api/v1/users/:id/limits", middleware.RequireRole(token, "admin", http.HandlerFunc(uh.UpdateLimits)))
+
     // Restaurants
     r.Handle("GET", "/api/v1/restaurants", http.HandlerFunc(rh.List))
+    r.Handle("GET", "/api/v1/restaurants/archived", middleware.RequireRole(token, "admin", http.HandlerFunc(rh.ListArchived)))
     r.Handle("GET", "/api/v1/restaurants/:id", http.HandlerFunc(rh.GetByID))
     r.Handle("GET", "/api/v1/restaurants/:id/details", http.HandlerFunc(rh.GetDetails))
     r.Handle("POST", "/api/v1/restaurants", middleware.RequireRole(token, "admin", http.HandlerFunc(rh.Create)))
-    r.Handle("DELETE", "/api/v1/restaurants/:id", middleware.RequireRole(token, "admin", http.HandlerFunc(rh.Delete)))
+    r.Handle("PUT", "/api/v1/restaurants/:id", middleware.RequireRole(token, "admin", http.HandlerFunc(rh.Update)))
+    r.Handle("PATCH", "/api/v1/restaurants/:id", middleware.RequireRole(token, "admin", http.HandlerFunc(rh.Update)))
+    r.Handle("DELETE", "/api/v1/restaurants/:id", middleware.RequireRole(token, "admin", http.HandlerFunc(rh.Archive)))
+    r.Handle("POST", "/api/v1/restaurants/:id/restore", middleware.RequireRole(token, "admin", http.HandlerFunc(rh.Restore)))
+    r.Handle("GET", "/api/v1/restaurants/:id/purge", middleware.RequireRole(token, "admin", http.HandlerFunc(rh.PurgePreview)))
+    r.Handle("DELETE", "/api/v1/restaurants/:id/purge", middleware.RequireRole(token, "admin", http.HandlerFunc(rh.Purge)))
+    r.Handle("GET", "/api/v1/restaurants/:id/stats", middleware.RequireRole(token, "admin", http.HandlerFunc(rh.Stats)))
+    r.Handle("GET", "/api/v1/restaurants/:id/reports/occupancy", middleware.RequireRole(token, "admin", http.HandlerFunc(rh.OccupancyReport)))
+    r.Handle("GET", "/api/v1/restaurants/:id/settings", http.HandlerFunc(rh.Settings))
+    r.Handle("PATCH", "/api/v1/restaurants/:id/settings", middleware.RequireRole(token, "admin", http.HandlerFunc(rh.UpdateSettings)))
+
+    // Weekly opening hours
+    r.Handle("GET", "/api/v1/restaurants/:id/hours", http.HandlerFunc(hh.List))
+    r.Handle("POST", "/api/v1/restaurants/:id/hours", middleware.RequireRole(token, "admin", http.HandlerFunc(hh.Create)))
+    r.Handle("PUT", "/api/v1/restaurants/:id/hours/:periodId", middleware.RequireRole(token, "admin", http.HandlerFunc(hh.Update)))
+    r.Handle("DELETE", "/api/v1/restaurants/:id/hours/:periodId", middleware.RequireRole(token, "admin", http.HandlerFunc(hh.Delete)))
+    r.Handle("GET", "/api/v1/restaurants/:id/exceptions", http.HandlerFunc(hh.ListExceptions))
+    r.Handle("POST", "/api/v1/restaurants/:id/exceptions", middleware.RequireRole(token, "admin", http.HandlerFunc(hh.CreateException)))
+    r.Handle("DELETE", "/api/v1/restaurants/:id/exceptions/:exceptionId", middleware.RequireRole(token, "admin", http.HandlerFunc(hh.DeleteException)))
 
     // Tables
     r.Handle("GET", "/api/v1/restaurants/:id/tables", http.HandlerFunc(th.ListByRestaurant))
     r.Handle("POST", "/api/v1/restaurants/:id/tables", middleware.RequireRole(token, "admin", http.HandlerFunc(th.Create)))
+    r.Handle("PUT", "/api/v1/tables/:id", middleware.RequireRole(token, "admin", http.HandlerFunc(th.Update)))
+    r.Handle("DELETE", "/api/v1/tables/:id", middleware.RequireRole(token, "admin", http.HandlerFunc(th.Delete)))
+    r.Handle("POST", "/api/v1/tables/:id/blocks", middleware.RequireRole(token, "admin", http.HandlerFunc(th.CreateBlock)))
+    r.Handle("GET", "/api/v1/restaurants/:id/blocks", middleware.RequireRole(token, "admin", http.HandlerFunc(th.ListBlocks)))
+    r.Handle("DELETE", "/api/v1/blocks/:id", middleware.RequireRole(token, "admin", http.HandlerFunc(th.DeleteBlock)))
+    r.Handle("GET", "/api/v1/restaurants/:id/combinations", http.HandlerFunc(th.ListCombinations))
+    r.Handle("POST", "/api/v1/restaurants/:id/combinations", middleware.RequireRole(token, "admin", http.HandlerFunc(th.CreateCombination)))
+    r.Handle("DELETE", "/api/v1/restaurants/:id/combinations/:combinationId", middleware.RequireRole(token, "admin", http.HandlerFunc(th.DeleteCombination)))
+
+    // Floor plan
+    r.Handle("GET", "/api/v1/restaurants/:id/floor-plan", http.HandlerFunc(rh.GetFloorPlan))
+    r.Handle("PUT", "/api/v1/restaurants/:id/floor-plan", middleware.RequireRole(token, "admin", http.HandlerFunc(rh.SaveFloorPlan)))
+    r.Handle("GET", "/api/v1/restaurants/:id/floor-plan/state", middleware.RequireRole(token, "admin", http.HandlerFunc(rh.FloorState)))
+    r.Handle("POST", "/api/v1/restaurants/:id/floor-plan/sections", middleware.RequireRole(token, "admin", http.HandlerFunc(rh.CreateSection)))
+    r.Handle("PUT", "/api/v1/floor-sections/:id", middleware.RequireRole(token, "admin", http.HandlerFunc(rh.UpdateSection)))
+    r.Handle("DELETE", "/api/v1/floor-sections/:id", middleware.RequireRole(token, "admin", http.HandlerFunc(rh.DeleteSection)))
+    r.Handle("PUT", "/api/v1/tables/:id/position", middleware.RequireRole(token, "admin", http.HandlerFunc(rh.SetTablePosition)))
+    r.Handle("DELETE", "/api/v1/tables/:id/position", middleware.RequireRole(token, "admin", http.HandlerFunc(rh.DeleteTablePosition)))
 
     // Availability and reservations
     r.Handle("POST", "/api/v1/restaurants/:id/availability", http.HandlerFunc(resvh.Availability))
+    r.Handle("GET", "/api/v1/restaurants/:id/slots", http.HandlerFunc(resvh.Slots))
     r.Handle("POST", "/api/v1/restaurants/:id/reservations", middleware.RequireAuth(token, http.HandlerFunc(resvh.Create)))
+    r.Handle("POST", "/api/v1/restaurants/:id/guest-reservations", http.HandlerFunc(resvh.CreateGuest))
+    r.Handle("POST", "/api/v1/reservations/lookup", http.HandlerFunc(resvh.Lookup))
+    r.Handle("POST", "/api/v1/reservations/lookup/cancel", http.HandlerFunc(resvh.LookupCancel))
+    r.Handle("POST", "/api/v1/reservations/lookup/requests", http.HandlerFunc(resvh.LookupUpdateRequests))
+    r.Handle("POST", "/api/v1/restaurants/:id/holds", middleware.RequireAuth(token, http.HandlerFunc(resvh.CreateHold)))
+    r.Handle("DELETE", "/api/v1/holds/:id", middleware.RequireAuth(token, http.HandlerFunc(resvh.ReleaseHold)))
+    r.Handle("PATCH", "/api/v1/reservations/:id", middleware.RequireAuth(token, http.HandlerFunc(resvh.Modify)))
     r.Handle("DELETE", "/api/v1/reservations/:id", middleware.RequireAuth(token, http.HandlerFunc(resvh.Cancel)))
     r.Handle("GET", "/api/v1/me/reservations", middleware.RequireAuth(token, http.HandlerFunc(resvh.ListMine)))
+    r.Handle("GET", "/api/v1/restaurants/:id/reservations", middleware.RequireRole(token, "admin", http.HandlerFunc(resvh.ListRestaurantReservations)))
+    r.Handle("GET", "/api/v1/special-requests", http.HandlerFunc(resvh.SpecialRequestOptions))
+
+    // Recurring reservations
+    r.Handle("POST", "/api/v1/restaurants/:id/series", middleware.RequireAuth(token, http.HandlerFunc(resvh.CreateSeries)))
+    r.Handle("GET", "/api/v1/series/:id", middleware.RequireAuth(token, http.HandlerFunc(resvh.GetSeries)))
+    r.Handle("PATCH", "/api/v1/series/:id", middleware.RequireAuth(token, http.HandlerFunc(resvh.UpdateSeries)))
+    r.Handle("DELETE", "/api/v1/series/:id", middleware.RequireAuth(token, http.HandlerFunc(resvh.CancelSeries)))
+
+    // Waitlist for fully booked times
+    r.Handle("POST", "/api/v1/restaurants/:id/waitlist", middleware.RequireAuth(token, http.HandlerFunc(resvh.JoinWaitlist)))
+    r.Handle("GET", "/api/v1/restaurants/:id/waitlist", middleware.RequireRole(token, "admin", http.HandlerFunc(resvh.ListWaitlist)))
+    r.Handle("GET", "/api/v1/me/waitlist", middleware.RequireAuth(token, http.HandlerFunc(resvh.ListMyWaitlist)))
+    r.Handle("DELETE", "/api/v1/waitlist/:id", middleware.RequireAuth(token, http.HandlerFunc(resvh.LeaveWaitlist)))
+    r.Handle("POST", "/api/v1/waitlist/:id/claim", middleware.RequireAuth(token, http.HandlerFunc(resvh.ClaimWaitlistOffer)))
+
+    // Reservation lifecycle (floor staff)
+    r.Handle("POST", "/api/v1/reservations/:id/confirm", middleware.RequireRole(token, "admin", resvh.Transition(models.StatusConfirmed)))
+    r.Handle("POST", "/api/v1/reservations/:id/seat", middleware.RequireRole(token, "admin", resvh.Transition(models.StatusSeated)))
+    r.Handle("POST", "/api/v1/reservations/:id/complete", middleware.RequireRole(token, "admin", resvh.Transition(models.StatusCompleted)))
+    r.Handle("POST", "/api/v1/reservations/:id/no-show", middleware.RequireRole(token, "admin", resvh.Transition(models.StatusNoShow)))
 
     return &Server{mux: mux}
 }
 
 func (s *Server) Handler() http.Handler { return s.mux }
 
+// sweepExpired periodically deletes checkout holds that were never confirmed
+// and stored responses whose Idempotency-Key has expired. Both already stop
+// taking effect once expired; this keeps them from piling up in the store.
+// It also passes waitlist offers that were not claimed in time on to the next
+// party in line.
+func sweepExpired(reservations store.ReservationStore, idempotency store.IdempotencyStore, waitlist *h.ReservationHandler, every time.Duration) {
+    for range time.Tick(every) {
+        if n, err := reservations.DeleteExpiredHolds(time.Now()); err != nil {
+            log.Printf("[warn] sweeping expired holds: %v", err)
+        } else if n > 0 {
+            log.Printf("[info] removed %d expired holds", n)
+        }
+        if n, err := idempotency.DeleteExpired(time.Now()); err != nil {
+            log.Printf("[warn] sweeping expired idempotency keys: %v", err)
+        } else if n > 0 {
+            log.Printf("[info] removed %d expired idempotency keys", n)
+        }
+        if n, err := waitlist.ExpireWaitlistOffers(time.Now()); err != nil {
+            log.Printf("[warn] expiring waitlist offers: %v", err)
+        } else if n > 0 {
+            log.Printf("[info] passed on %d expired waitlist offers", n)
+        }
+    }
+}
+
+// idempotencyTTL is how long responses are kept for Idempotency-Key retries,
+// from IDEMPOTENCY_TTL (a Go duration such as "24h"); it defaults to 24 hours.
+func idempotencyTTL() time.Duration {
+    if v := os.Getenv("IDEMPOTENCY_TTL"); v != "" {
+        if d, err := time.ParseDuration(v); err == nil && d > 0 {
+            return d
+        }
+        log.Printf("[warn] invalid IDEMPOTENCY_TTL %q; using 24h", v)
+    }
+    return 24 * time.Hour
+}
+
+// bookingLimits reads the per-user booking limits from BOOKING_MAX_ACTIVE,
+// BOOKING_MAX_PER_DAY and BOOKING_ALLOW_OVERLAP, falling back to
+// h.DefaultBookingLimits for unset or invalid values.
+func bookingLimits() h.BookingLimits {
+    l := h.DefaultBookingLimits
+    readInt := func(name string, dst *int) {
+        if v := os.Getenv(name); v != "" {
+            if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+                *dst = n
+            } else {
+                log.Printf("[warn] invalid %s %q; using %d", name, v, *dst)
+            }
+        }
+    }
+    readInt("BOOKING_MAX_ACTIVE", &l.MaxActive)
+    readInt("BOOKING_MAX_PER_DAY", &l.MaxPerDay)
+    if v := os.Getenv("BOOKING_ALLOW_OVERLAP"); v != "" {
+        if b, err := strconv.ParseBool(v); err == nil {
+            l.AllowOverlap = b
+        } else {
+            log.Printf("[warn] invalid BOOKING_ALLOW_OVERLAP %q", v)
+        }
+    }
+    return l
+}
+
 func shouldUseMySQL(config *mysqlstore.Config) bool {
     if os.Getenv("MYSQL_DSN") != "" {
         return true
@@ -134,10 +289,23 @@ func shouldUseMySQL(config *mysqlstore.Config) bool {
 }
 
 func initMemoryStores(userStore *store.UserStore, restaurantStore *store.RestaurantStore, 
-                     tableStore *store.TableStore, reservationStore *store.ReservationStore) {
+                     tableStore *store.TableStore, reservationStore *store.ReservationStore,
+                     hoursStore *store.HoursStore, exceptionStore *store.ExceptionStore,
+                     combinationStore *store.TableCombinationStore, tableBlockStore *store.TableBlockStore,
+                     floorPlanStore *store.FloorPlanStore, waitlistStore *store.WaitlistStore,
+                     seriesStore *store.SeriesStore, idempotencyStore *store.IdempotencyStore) {
     *userStore = memorystore.NewUserStore()
     *restaurantStore = memorystore.NewRestaurantStore()
-    *tableStore = memorystore.NewTableStore()
-    *reservationStore = memorystore.NewReservationStore()
+    tables, blocks := memorystore.NewTableStore(), memorystore.NewTableBlockStore()
+    *tableStore = tables
+    *reservationStore = memorystore.NewReservationStore().UseTables(tables, blocks)
+    *hoursStore = memorystore.NewHoursStore()
+    *exceptionStore = memorystore.NewExceptionStore()
+    *combinationStore = memorystore.NewTableCombinationStore()
+    *tableBlockStore = blocks
+    *floorPlanStore = memorystore.NewFloorPlanStore()
+    *waitlistStore = memorystore.NewWaitlistStore()
+    *seriesStore = memorystore.NewSeriesStore()
+    *idempotencyStore = memorystore.NewIdempotencyStore()
     log.Println("[info] using in-memory store")
 }
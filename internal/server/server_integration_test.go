@@ -6,6 +6,7 @@ import (
     "net/http"
     "net/http/httptest"
     "os"
+    "strings"
     "testing"
     "time"
 
@@ -16,6 +17,9 @@ func TestEndToEndFlow(t *testing.T) {
     os.Setenv("ADMIN_EMAIL", "admin@test.local")
     os.Setenv("ADMIN_PASSWORD", "adminpwd")
     os.Setenv("SECRET", "it-is-a-test-secret")
+    os.Setenv("BOOKING_MAX_ACTIVE", "5")
+    os.Setenv("BOOKING_MAX_PER_DAY", "2")
+    os.Setenv("BOOKING_ALLOW_OVERLAP", "false")
 
     srv := server.New()
     ts := httptest.NewServer(srv.Handler())
@@ -25,11 +29,14 @@ func TestEndToEndFlow(t *testing.T) {
     adminTok := login(t, ts.URL, "admin@test.local", "adminpwd")
 
     // create restaurant
-    restBody := map[string]any{"name": "DemoR", "address": "Addr", "openTime": "10:00", "closeTime": "22:00"}
+    restBody := map[string]any{"name": "DemoR", "address": "Addr", "openTime": "10:00", "closeTime": "22:00", "timeZone": "Asia/Shanghai"}
     var restResp map[string]any
     doJSON(t, ts.URL+"/api/v1/restaurants", http.MethodPost, adminTok, restBody, &restResp, 201)
     restID := restResp["id"].(string)
 
+    // unknown time zones are rejected
+    doJSON(t, ts.URL+"/api/v1/restaurants", http.MethodPost, adminTok, map[string]any{"name": "BadTZ", "timeZone": "Mars/Olympus"}, nil, 400)
+
     // create table
     tblBody := map[string]any{"name": "A1", "capacity": 4}
     var tblResp map[string]any
@@ -42,7 +49,9 @@ func TestEndToEndFlow(t *testing.T) {
     userTok := reg["token"].(string)
 
     // availability
-    start := time.Now().Add(2 * time.Hour).Truncate(time.Minute)
+    loc, _ := time.LoadLocation("Asia/Shanghai")
+    tomorrow := time.Now().In(loc).AddDate(0, 0, 1)
+    start := time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), 12, 0, 0, 0, loc)
     end := start.Add(2 * time.Hour)
     var avail []map[string]any
     doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/availability", http.MethodPost, "", map[string]any{"start": start, "end": end, "guests": 2}, &avail, 200)
@@ -53,6 +62,24 @@ func TestEndToEndFlow(t *testing.T) {
     doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/reservations", http.MethodPost, userTok, map[string]any{"start": start, "end": end, "guests": 2, "tableId": tableID}, &res, 201)
     resID := res["id"].(string)
 
+    // double booking the same table is a conflict
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/reservations", http.MethodPost, userTok, map[string]any{"start": start, "end": end, "guests": 2, "tableId": tableID}, nil, 409)
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/reservations", http.MethodPost, userTok, map[string]any{"start": start, "end": start.Add(13 * time.Hour), "guests": 2}, nil, 400)
+
+    // day slots: 10:00-20:00 every 15 minutes, minus the starts that overlap 12:00-14:00
+    var slots []map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/slots?date="+start.Format("2006-01-02")+"&guests=2&duration=120", http.MethodGet, "", nil, &slots, 200)
+    if len(slots) != 26 { t.Fatalf("expected 26 slots, got %d", len(slots)) }
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/slots?date="+start.Format("2006-01-02")+"&guests=8", http.MethodGet, "", nil, &slots, 200)
+    if len(slots) != 0 { t.Fatalf("expected no slots for 8 guests, got %d", len(slots)) }
+
+    // move the booking an hour later; the duration is kept
+    var moved map[string]any
+    doJSON(t, ts.URL+"/api/v1/reservations/"+resID, http.MethodPatch, userTok, map[string]any{"start": start.Add(time.Hour)}, &moved, 200)
+    if got, _ := time.Parse(time.RFC3339, moved["endTime"].(string)); !got.Equal(end.Add(time.Hour)) { t.Fatalf("expected end to move with start, got %v", moved["endTime"]) }
+    // no table fits 10 guests, so the original booking stays as is
+    doJSON(t, ts.URL+"/api/v1/reservations/"+resID, http.MethodPatch, userTok, map[string]any{"guests": 10}, nil, 409)
+
     // list mine
     var my []map[string]any
     doJSON(t, ts.URL+"/api/v1/me/reservations", http.MethodGet, userTok, nil, &my, 200)
@@ -61,6 +88,334 @@ func TestEndToEndFlow(t *testing.T) {
     // cancel
     doJSON(t, ts.URL+"/api/v1/reservations/"+resID, http.MethodDelete, userTok, nil, &res, 200)
     if res["status"].(string) != "cancelled" { t.Fatalf("expected cancelled") }
+
+    // a checkout hold blocks the table until it is confirmed
+    var hold map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/holds", http.MethodPost, userTok, map[string]any{"start": start, "end": end, "guests": 2, "tableId": tableID}, &hold, 201)
+    if hold["status"] != "held" || hold["holdExpiresAt"] == nil { t.Fatalf("expected a hold with an expiry, got %v", hold) }
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/reservations", http.MethodPost, userTok, map[string]any{"start": start, "end": end, "guests": 2, "tableId": tableID}, nil, 409)
+    var confirmed map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/reservations", http.MethodPost, userTok, map[string]any{"holdId": hold["id"]}, &confirmed, 201)
+    if confirmed["id"] != hold["id"] || confirmed["status"] != "confirmed" { t.Fatalf("expected the hold to be confirmed, got %v", confirmed) }
+    doJSON(t, ts.URL+"/api/v1/reservations/"+hold["id"].(string), http.MethodDelete, userTok, nil, nil, 200)
+
+    // two joined tables seat a party no single table fits
+    var tbl2 map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/tables", http.MethodPost, adminTok, map[string]any{"name": "A2", "capacity": 4}, &tbl2, 201)
+    var combo map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/combinations", http.MethodPost, adminTok, map[string]any{"name": "A1+A2", "tableIds": []string{tableID, tbl2["id"].(string)}}, &combo, 201)
+    if combo["capacity"].(float64) != 8 { t.Fatalf("expected combined capacity 8, got %v", combo["capacity"]) }
+    var big map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/reservations", http.MethodPost, userTok, map[string]any{"start": start, "end": end, "guests": 8}, &big, 201)
+    if big["combinationId"] != combo["id"] { t.Fatalf("expected the combination to be allocated, got %v", big["combinationId"]) }
+    // both member tables are now taken (admins are not subject to per-user limits)
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/reservations", http.MethodPost, adminTok, map[string]any{"start": start, "end": end, "guests": 2}, nil, 400)
+    // the same account cannot hold two reservations at the same time
+    var overlap map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/reservations", http.MethodPost, userTok, map[string]any{"start": start, "end": end, "guests": 2}, &overlap, 409)
+    if overlap["code"] != "overlapping_reservation" { t.Fatalf("expected an overlapping reservation error, got %v", overlap) }
+    // nor move another one onto it
+    var elsewhen map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/reservations", http.MethodPost, userTok, map[string]any{"start": start.AddDate(0, 0, 5), "guests": 2}, &elsewhen, 201)
+    doJSON(t, ts.URL+"/api/v1/reservations/"+elsewhen["id"].(string), http.MethodPatch, userTok, map[string]any{"start": start}, &overlap, 409)
+    if overlap["code"] != "overlapping_reservation" { t.Fatalf("expected moving onto another reservation to be refused, got %v", overlap) }
+    doJSON(t, ts.URL+"/api/v1/reservations/"+elsewhen["id"].(string), http.MethodPatch, userTok, map[string]any{"start": start.AddDate(0, 0, 5).Add(time.Hour)}, nil, 200)
+    doJSON(t, ts.URL+"/api/v1/reservations/"+elsewhen["id"].(string), http.MethodDelete, userTok, nil, nil, 200)
+    // unless an admin exempts the account
+    userID := reg["user"].(map[string]any)["id"].(string)
+    doJSON(t, ts.URL+"/api/v1/users/"+userID+"/limits", http.MethodPatch, userTok, map[string]any{"limitExempt": true}, nil, 403)
+    doJSON(t, ts.URL+"/api/v1/users/"+userID+"/limits", http.MethodPatch, adminTok, map[string]any{"limitExempt": true}, nil, 200)
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/reservations", http.MethodPost, userTok, map[string]any{"start": start, "end": end, "guests": 2}, nil, 400)
+    doJSON(t, ts.URL+"/api/v1/users/"+userID+"/limits", http.MethodPatch, adminTok, map[string]any{"limitExempt": false}, nil, 200)
+
+    // a waitlisted party is offered the tables once the large booking is cancelled
+    var entry map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/waitlist", http.MethodPost, userTok, map[string]any{"windowStart": start, "windowEnd": start.Add(30 * time.Minute), "guests": 2}, &entry, 201)
+    entryID := entry["id"].(string)
+    doJSON(t, ts.URL+"/api/v1/reservations/"+big["id"].(string), http.MethodDelete, userTok, nil, nil, 200)
+    var waiting []map[string]any
+    doJSON(t, ts.URL+"/api/v1/me/waitlist", http.MethodGet, userTok, nil, &waiting, 200)
+    if len(waiting) != 1 || waiting[0]["status"] != "offered" { t.Fatalf("expected an offer, got %v", waiting) }
+    doJSON(t, ts.URL+"/api/v1/waitlist/"+entryID+"/claim", http.MethodPost, userTok, map[string]any{"claimToken": "wrong"}, nil, 403)
+    var claimed map[string]any
+    doJSON(t, ts.URL+"/api/v1/waitlist/"+entryID+"/claim", http.MethodPost, userTok, map[string]any{"claimToken": waiting[0]["claimToken"]}, &claimed, 201)
+    if got, _ := time.Parse(time.RFC3339, claimed["startTime"].(string)); !got.Equal(start) { t.Fatalf("expected claimed booking at %v, got %v", start, claimed["startTime"]) }
+
+    // guests without an account manage their booking with its code and token
+    var guest map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/guest-reservations", http.MethodPost, "", map[string]any{"start": start, "end": end, "guests": 2, "name": "Walk-in", "phone": "13800000000", "occasion": "party"}, nil, 400)
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/guest-reservations", http.MethodPost, "", map[string]any{"start": start, "end": end, "guests": 2, "name": "Walk-in", "phone": "13800000000", "occasion": "birthday"}, &guest, 201)
+    code, manage := guest["confirmationCode"].(string), guest["manageToken"].(string)
+    if code == "" || manage == "" || guest["userId"] != nil { t.Fatalf("expected a guest booking with code and token, got %v", guest) }
+    // guests are held to the booking limits by their contact details
+    var guestLimited map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/guest-reservations", http.MethodPost, "", map[string]any{"start": start, "end": end, "guests": 2, "name": "Walk-in", "phone": "138-0000-0000"}, &guestLimited, 409)
+    if guestLimited["code"] != "overlapping_reservation" { t.Fatalf("expected the guest's overlapping booking to be refused, got %v", guestLimited) }
+    doJSON(t, ts.URL+"/api/v1/reservations/lookup", http.MethodPost, "", map[string]any{"confirmationCode": code, "manageToken": "wrong"}, nil, 404)
+    var found map[string]any
+    doJSON(t, ts.URL+"/api/v1/reservations/lookup", http.MethodPost, "", map[string]any{"confirmationCode": code, "manageToken": manage}, &found, 200)
+    if found["id"] != guest["id"] { t.Fatalf("expected lookup to find the guest booking") }
+    // special requests are editable by the guest and staff, and filterable in the staff listing
+    var requested map[string]any
+    doJSON(t, ts.URL+"/api/v1/reservations/lookup/requests", http.MethodPost, "", map[string]any{"confirmationCode": code, "manageToken": manage, "occasion": "birthday", "dietary": []string{"Nut_Allergy", "nut_allergy"}, "notes": "window seat please"}, &requested, 200)
+    if dietary := requested["dietary"].([]any); len(dietary) != 1 || dietary[0] != "nut_allergy" || requested["notes"] != "window seat please" { t.Fatalf("expected normalized special requests, got %v", requested) }
+    var tonight []map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/reservations?date="+start.Format("2006-01-02")+"&dietary=allergy", http.MethodGet, userTok, nil, nil, 403)
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/reservations?date="+start.Format("2006-01-02")+"&dietary=allergy", http.MethodGet, adminTok, nil, &tonight, 200)
+    if len(tonight) != 1 || tonight[0]["id"] != guest["id"] { t.Fatalf("expected only the guest booking with an allergy, got %v", tonight) }
+    var withHighChair map[string]any
+    doJSON(t, ts.URL+"/api/v1/reservations/"+claimed["id"].(string), http.MethodPatch, userTok, map[string]any{"accessibility": []string{"high_chair"}}, &withHighChair, 200)
+    if withHighChair["startTime"] != claimed["startTime"] || withHighChair["accessibility"].([]any)[0] != "high_chair" { t.Fatalf("expected only the accessibility needs to change, got %v", withHighChair) }
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/reservations?date="+start.Format("2006-01-02")+"&accessibility=any", http.MethodGet, adminTok, nil, &tonight, 200)
+    if len(tonight) != 1 || tonight[0]["id"] != claimed["id"] { t.Fatalf("expected only the booking needing a high chair, got %v", tonight) }
+    doJSON(t, ts.URL+"/api/v1/reservations/"+guest["id"].(string), http.MethodDelete, userTok, nil, nil, 403)
+    doJSON(t, ts.URL+"/api/v1/reservations/lookup/cancel", http.MethodPost, "", map[string]any{"confirmationCode": code, "manageToken": manage}, nil, 200)
+    doJSON(t, ts.URL+"/api/v1/reservations/lookup/cancel", http.MethodPost, "", map[string]any{"confirmationCode": code, "manageToken": manage}, nil, 409)
+
+    // with settings, only a start is needed and the turnover is recorded
+    var settings map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/settings", http.MethodPatch, adminTok, map[string]any{"defaultDurationMinutes": 90, "turnoverMinutes": 30}, &settings, 200)
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/settings", http.MethodPatch, userTok, map[string]any{"turnoverMinutes": 10}, nil, 403)
+    var short map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/reservations", http.MethodPost, userTok, map[string]any{"start": end, "guests": 2}, &short, 201)
+    if got, _ := time.Parse(time.RFC3339, short["endTime"].(string)); !got.Equal(end.Add(90 * time.Minute)) { t.Fatalf("expected default 90 minute booking, got %v", short["endTime"]) }
+    if short["turnoverMinutes"].(float64) != 30 { t.Fatalf("expected turnover to be recorded, got %v", short["turnoverMinutes"]) }
+
+    // inside the cancellation deadline only an admin can cancel, giving a reason
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/settings", http.MethodPatch, adminTok, map[string]any{"cancellation": map[string]any{"deadlineHours": 72}}, nil, 200)
+    doJSON(t, ts.URL+"/api/v1/reservations/"+short["id"].(string), http.MethodDelete, userTok, nil, nil, 403)
+    doJSON(t, ts.URL+"/api/v1/reservations/"+short["id"].(string), http.MethodDelete, adminTok, nil, nil, 400)
+    var lateCancel map[string]any
+    doJSON(t, ts.URL+"/api/v1/reservations/"+short["id"].(string), http.MethodDelete, adminTok, map[string]any{"reason": "guest called the restaurant"}, &lateCancel, 200)
+    if lateCancel["lateCancel"] != true { t.Fatalf("expected a late cancellation, got %v", lateCancel) }
+
+    // booking rules are enforced with one violation per broken rule
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/settings", http.MethodPatch, adminTok, map[string]any{"bookingRules": map[string]any{"maxPartySize": 6, "startIntervalMinutes": 30, "maxAdvanceDays": 30}}, nil, 200)
+    var broken map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/availability", http.MethodPost, "", map[string]any{"start": start.Add(10 * time.Minute), "guests": 7}, &broken, 400)
+    if broken["code"] != "booking_rules_violated" || len(broken["violations"].([]any)) != 2 { t.Fatalf("expected two rule violations, got %v", broken) }
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/reservations", http.MethodPost, userTok, map[string]any{"start": start.AddDate(0, 2, 0), "guests": 2}, nil, 400)
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/slots?date="+start.Format("2006-01-02")+"&guests=7", http.MethodGet, "", nil, &broken, 400)
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/slots?date="+start.Format("2006-01-02")+"&guests=2&duration=120", http.MethodGet, "", nil, &slots, 200)
+    for _, s := range slots { if got, _ := time.Parse(time.RFC3339, s["start"].(string)); got.Minute()%30 != 0 { t.Fatalf("expected slots on the 30-minute grid, got %v", s["start"]) } }
+    var ruled map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/reservations", http.MethodPost, userTok, map[string]any{"start": start.AddDate(0, 0, 4), "guests": 2}, &ruled, 201)
+    doJSON(t, ts.URL+"/api/v1/reservations/"+ruled["id"].(string), http.MethodPatch, userTok, map[string]any{"guests": 7}, &broken, 400)
+    if broken["code"] != "booking_rules_violated" { t.Fatalf("expected a modification to follow the booking rules, got %v", broken) }
+    doJSON(t, ts.URL+"/api/v1/reservations/"+ruled["id"].(string), http.MethodPatch, userTok, map[string]any{"start": start.AddDate(0, 0, 4).Add(10 * time.Minute)}, nil, 400)
+    doJSON(t, ts.URL+"/api/v1/reservations/"+ruled["id"].(string), http.MethodPatch, userTok, map[string]any{"start": start.AddDate(0, 2, 0)}, nil, 400)
+    doJSON(t, ts.URL+"/api/v1/reservations/"+ruled["id"].(string), http.MethodDelete, userTok, nil, nil, 200)
+    var details map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/details", http.MethodGet, "", nil, &details, 200)
+    if window := details["booking"].(map[string]any); window["latestStart"] == nil || window["maxPartySize"].(float64) != 6 { t.Fatalf("expected the booking window in details, got %v", window) }
+
+    // a wheelchair user is only seated at accessible tables; preferences rank the rest
+    var patio map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/tables", http.MethodPost, adminTok, map[string]any{"name": "P1", "capacity": 6, "section": "Patio", "accessible": true}, &patio, 201)
+    later := start.AddDate(0, 0, 2)
+    var accessible []map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/availability", http.MethodPost, "", map[string]any{"start": later, "guests": 2, "accessible": true}, &accessible, 200)
+    if len(accessible) != 1 || accessible[0]["tableId"] != patio["id"] || accessible[0]["table"].(map[string]any)["section"] != "patio" { t.Fatalf("expected only the accessible patio table, got %v", accessible) }
+    var seated map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/reservations", http.MethodPost, userTok, map[string]any{"start": later, "guests": 2, "tableId": tableID, "accessibility": []string{"wheelchair"}}, nil, 400)
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/reservations", http.MethodPost, userTok, map[string]any{"start": later, "guests": 2, "accessibility": []string{"wheelchair"}}, &seated, 201)
+    if seated["tableId"] != patio["id"] { t.Fatalf("expected the accessible table, got %v", seated["tableId"]) }
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/reservations", http.MethodPost, adminTok, map[string]any{"start": later, "guests": 2, "seating": map[string]any{"section": "patio"}}, &seated, 201)
+    if seated["tableId"] == patio["id"] { t.Fatalf("expected another table once the patio is taken, got %v", seated["tableId"]) }
+    // a wheelchair need added at checkout moves the hold to an accessible table
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/holds", http.MethodPost, adminTok, map[string]any{"start": later.Add(3 * time.Hour), "guests": 2, "tableId": tableID}, &hold, 201)
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/reservations", http.MethodPost, adminTok, map[string]any{"holdId": hold["id"], "accessibility": []string{"wheelchair"}}, &seated, 201)
+    if seated["tableId"] != patio["id"] || seated["status"] != "confirmed" { t.Fatalf("expected the confirmed hold on the accessible table, got %v", seated) }
+    // seating preferences can be changed and cleared later
+    doJSON(t, ts.URL+"/api/v1/reservations/"+seated["id"].(string), http.MethodPatch, adminTok, map[string]any{"seating": map[string]any{"section": "Patio", "booth": true}}, &seated, 200)
+    if prefs, _ := seated["seating"].(map[string]any); prefs["section"] != "patio" || prefs["booth"] != true { t.Fatalf("expected the new seating preferences, got %v", seated["seating"]) }
+    var cleared map[string]any
+    doJSON(t, ts.URL+"/api/v1/reservations/"+seated["id"].(string), http.MethodPatch, adminTok, map[string]any{"seating": map[string]any{}}, &cleared, 200)
+    if cleared["seating"] != nil { t.Fatalf("expected the seating preferences to be cleared, got %v", cleared["seating"]) }
+    doJSON(t, ts.URL+"/api/v1/reservations/"+seated["id"].(string), http.MethodDelete, adminTok, map[string]any{"reason": "guest changed plans"}, nil, 200)
+
+    // the floor plan places tables on a grid and shows what is happening at each
+    patioID := patio["id"].(string)
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/floor-plan/sections", http.MethodPost, adminTok, map[string]any{"name": "Patio", "x": 0, "y": 0, "width": 10, "height": 8}, nil, 201)
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/floor-plan/sections", http.MethodPost, adminTok, map[string]any{"name": "patio", "x": 10, "y": 0, "width": 5, "height": 5}, nil, 409)
+    doJSON(t, ts.URL+"/api/v1/tables/"+patioID+"/position", http.MethodPut, adminTok, map[string]any{"x": 2, "y": 2, "width": 3, "height": 3, "shape": "round"}, nil, 200)
+    doJSON(t, ts.URL+"/api/v1/tables/"+tableID+"/position", http.MethodPut, adminTok, map[string]any{"x": 4, "y": 4, "width": 2, "height": 2}, nil, 409)
+    doJSON(t, ts.URL+"/api/v1/tables/"+tableID+"/position", http.MethodPut, adminTok, map[string]any{"x": 39, "y": 0, "width": 2, "height": 2}, nil, 400)
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/floor-plan", http.MethodPut, adminTok, map[string]any{"width": 4, "height": 4}, nil, 409)
+    var plan map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/floor-plan", http.MethodGet, "", nil, &plan, 200)
+    if len(plan["sections"].([]any)) != 1 || len(plan["positions"].([]any)) != 1 || plan["plan"].(map[string]any)["width"].(float64) != 40 { t.Fatalf("unexpected floor plan %v", plan) }
+    var floor map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/floor-plan/state?at="+later.Add(30*time.Minute).UTC().Format(time.RFC3339), http.MethodGet, adminTok, nil, &floor, 200)
+    for _, ft := range floor["tables"].([]any) {
+        ft := ft.(map[string]any)
+        if ft["table"].(map[string]any)["id"] == patioID && (ft["status"] != "reserved" || ft["position"] == nil || ft["reservation"] == nil) { t.Fatalf("expected the patio table to be reserved, got %v", ft) }
+    }
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/floor-plan/state?at="+later.Add(-time.Hour).UTC().Format(time.RFC3339), http.MethodGet, adminTok, nil, &floor, 200)
+    for _, ft := range floor["tables"].([]any) {
+        ft := ft.(map[string]any)
+        if ft["table"].(map[string]any)["id"] == patioID && (ft["status"] != "available" || ft["next"] == nil) { t.Fatalf("expected the patio table to be free until its next party, got %v", ft) }
+    }
+    // a past moment shows the floor as it stood then, before any of these bookings were made
+    var pastFloor map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/floor-plan/state?at="+time.Now().Add(-2*time.Hour).UTC().Format(time.RFC3339), http.MethodGet, adminTok, nil, &pastFloor, 200)
+    for _, ft := range pastFloor["tables"].([]any) {
+        ft := ft.(map[string]any)
+        if ft["table"].(map[string]any)["id"] == patioID && (ft["status"] != "available" || ft["reservation"] != nil || ft["next"] != nil) { t.Fatalf("expected the patio table to have been free and unbooked, got %v", ft) }
+    }
+
+    // a table blocked out of service drops out of availability; its bookings are moved first
+    var spare map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/tables", http.MethodPost, adminTok, map[string]any{"name": "S1", "capacity": 2}, &spare, 201)
+    spareID := spare["id"].(string)
+    doJSON(t, ts.URL+"/api/v1/tables/"+spareID, http.MethodPut, adminTok, map[string]any{"name": "S1", "capacity": 3, "section": "Bar"}, &spare, 200)
+    if spare["capacity"].(float64) != 3 || spare["section"] != "bar" { t.Fatalf("expected the table to be updated, got %v", spare) }
+    doJSON(t, ts.URL+"/api/v1/tables/"+spareID, http.MethodPut, userTok, map[string]any{"name": "S1", "capacity": 8}, nil, 403)
+    blockAt := later.Add(3 * time.Hour)
+    var onSpare map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/reservations", http.MethodPost, adminTok, map[string]any{"start": blockAt, "guests": 2, "tableId": spareID}, &onSpare, 201)
+    var inUse map[string]any
+    doJSON(t, ts.URL+"/api/v1/tables/"+spareID+"/blocks", http.MethodPost, adminTok, map[string]any{"start": blockAt.Add(-time.Hour), "end": blockAt.Add(3 * time.Hour), "reason": "repair"}, &inUse, 409)
+    if inUse["code"] != "table_in_use" || inUse["reservations"].([]any)[0] != onSpare["id"] { t.Fatalf("expected the booking to be in the way, got %v", inUse) }
+    var block map[string]any
+    doJSON(t, ts.URL+"/api/v1/tables/"+spareID+"/blocks?reassign=true", http.MethodPost, adminTok, map[string]any{"start": blockAt.Add(-time.Hour), "end": blockAt.Add(3 * time.Hour), "reason": "repair"}, &block, 201)
+    var mine []map[string]any
+    doJSON(t, ts.URL+"/api/v1/me/reservations", http.MethodGet, adminTok, nil, &mine, 200)
+    for _, m := range mine { if m["id"] == onSpare["id"] && (m["tableId"] == spareID || m["startTime"] != onSpare["startTime"]) { t.Fatalf("expected the booking to move to another table at the same time, got %v", m) } }
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/availability", http.MethodPost, "", map[string]any{"start": blockAt, "guests": 2}, &avail, 200)
+    for _, a := range avail { if a["tableId"] == spareID { t.Fatalf("expected the blocked table to be unavailable, got %v", avail) } }
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/reservations", http.MethodPost, adminTok, map[string]any{"start": blockAt, "guests": 2, "tableId": spareID}, nil, 409)
+    var blocks []map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/blocks", http.MethodGet, adminTok, nil, &blocks, 200)
+    if len(blocks) != 1 || blocks[0]["reason"] != "repair" { t.Fatalf("expected the block to be listed, got %v", blocks) }
+    doJSON(t, ts.URL+"/api/v1/blocks/"+block["id"].(string), http.MethodDelete, adminTok, nil, nil, 200)
+
+    // deleting a table with upcoming bookings needs ?reassign=true
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/reservations", http.MethodPost, adminTok, map[string]any{"start": blockAt, "guests": 2, "tableId": spareID}, &onSpare, 201)
+    doJSON(t, ts.URL+"/api/v1/tables/"+spareID, http.MethodDelete, adminTok, nil, &inUse, 409)
+    // if one booking cannot be moved, none is
+    busy := blockAt.AddDate(0, 0, 1)
+    var others, fillers []map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/tables", http.MethodGet, "", nil, &others, 200)
+    for _, tbl := range others {
+        if tbl["id"] == spareID { continue }
+        var filler map[string]any
+        doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/reservations", http.MethodPost, adminTok, map[string]any{"start": busy, "guests": 2, "tableId": tbl["id"]}, &filler, 201)
+        fillers = append(fillers, filler)
+    }
+    var stuck map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/reservations", http.MethodPost, adminTok, map[string]any{"start": busy, "guests": 2, "tableId": spareID}, &stuck, 201)
+    fillers = append(fillers, stuck)
+    doJSON(t, ts.URL+"/api/v1/tables/"+spareID+"?reassign=true", http.MethodDelete, adminTok, nil, &inUse, 409)
+    if inUse["code"] != "reassign_failed" { t.Fatalf("expected the reassignment to fail, got %v", inUse) }
+    var kept []map[string]any
+    doJSON(t, ts.URL+"/api/v1/me/reservations", http.MethodGet, adminTok, nil, &kept, 200)
+    for _, m := range kept { if m["id"] == onSpare["id"] && m["tableId"] != spareID { t.Fatalf("expected the movable booking to be put back, got %v", m) } }
+    for _, f := range fillers { doJSON(t, ts.URL+"/api/v1/reservations/"+f["id"].(string), http.MethodDelete, adminTok, map[string]any{"reason": "test setup"}, nil, 200) }
+    doJSON(t, ts.URL+"/api/v1/tables/"+spareID+"?reassign=true", http.MethodDelete, adminTok, nil, nil, 200)
+    var remaining []map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/tables", http.MethodGet, "", nil, &remaining, 200)
+    for _, tbl := range remaining { if tbl["id"] == spareID { t.Fatalf("expected the deleted table to be gone, got %v", remaining) } }
+    doJSON(t, ts.URL+"/api/v1/tables/"+spareID, http.MethodPut, adminTok, map[string]any{"name": "S1", "capacity": 3}, nil, 404)
+
+    // a weekly series books each week; the first clashes with the claimed booking
+    var series map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/series", http.MethodPost, userTok, map[string]any{"start": start, "guests": 2, "rule": "FREQ=WEEKLY;COUNT=3"}, &series, 201)
+    seriesID := series["series"].(map[string]any)["id"].(string)
+    conflicts := series["conflicts"].([]any)
+    if len(series["reservations"].([]any)) != 2 || len(conflicts) != 1 || conflicts[0].(map[string]any)["code"] != "overlapping_reservation" { t.Fatalf("expected two occurrences and one conflict, got %v", series) }
+    doJSON(t, ts.URL+"/api/v1/series/"+seriesID, http.MethodGet, "", nil, nil, 401)
+    var changed map[string]any
+    doJSON(t, ts.URL+"/api/v1/series/"+seriesID, http.MethodPatch, userTok, map[string]any{"time": "13:00"}, &changed, 200)
+    if got, _ := time.Parse(time.RFC3339, changed["reservations"].([]any)[1].(map[string]any)["startTime"].(string)); !got.Equal(start.AddDate(0, 0, 14).Add(time.Hour)) { t.Fatalf("expected the occurrences to move to 13:00, got %v", changed) }
+    var stopped map[string]any
+    doJSON(t, ts.URL+"/api/v1/series/"+seriesID+"?from="+start.AddDate(0, 0, 10).UTC().Format(time.RFC3339), http.MethodDelete, userTok, nil, &stopped, 200)
+    if len(stopped["cancelled"].([]any)) != 1 || stopped["series"].(map[string]any)["status"] != "active" { t.Fatalf("expected the last occurrence to be cancelled, got %v", stopped) }
+    doJSON(t, ts.URL+"/api/v1/series/"+seriesID, http.MethodDelete, userTok, nil, &stopped, 200)
+    if len(stopped["cancelled"].([]any)) != 1 || stopped["series"].(map[string]any)["status"] != "cancelled" { t.Fatalf("expected the series to be cancelled, got %v", stopped) }
+    // occurrences of a series do not count against each other: with two
+    // upcoming bookings all four fit under the limit of five
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/series", http.MethodPost, userTok, map[string]any{"start": start.AddDate(0, 0, 1).Add(7 * time.Hour), "guests": 2, "rule": "FREQ=WEEKLY;COUNT=4"}, &series, 201)
+    if len(series["reservations"].([]any)) != 4 || len(series["conflicts"].([]any)) != 0 { t.Fatalf("expected every occurrence to be booked, got %v", series) }
+    doJSON(t, ts.URL+"/api/v1/series/"+series["series"].(map[string]any)["id"].(string), http.MethodDelete, userTok, nil, &stopped, 200)
+
+    // a closure exception takes tomorrow out of the schedule
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/exceptions", http.MethodPost, adminTok, map[string]any{"date": start.Format("2006-01-02"), "closed": true, "note": "private event"}, nil, 201)
+    var exceptions []map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/exceptions", http.MethodGet, "", nil, &exceptions, 200)
+    if len(exceptions) != 1 { t.Fatalf("expected 1 upcoming exception, got %d", len(exceptions)) }
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+restID+"/availability", http.MethodPost, "", map[string]any{"start": start, "end": end, "guests": 2}, nil, 400)
+
+    // a restaurant can be edited, archived, restored and finally purged
+    var other map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants", http.MethodPost, adminTok, map[string]any{"name": "Other", "openTime": "10:00", "closeTime": "22:00", "timeZone": "Asia/Shanghai"}, &other, 201)
+    otherID := other["id"].(string)
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+otherID, http.MethodPatch, adminTok, map[string]any{"name": "Other Place", "openTime": "11:00"}, &other, 200)
+    if other["name"] != "Other Place" || other["openTime"] != "11:00" || other["closeTime"] != "22:00" { t.Fatalf("expected name and opening time to change, got %v", other) }
+    var hours []map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+otherID+"/hours", http.MethodGet, "", nil, &hours, 200)
+    if len(hours) != 7 || hours[0]["openTime"] != "11:00" { t.Fatalf("expected the weekly schedule to follow the new hours, got %v", hours) }
+    // once the schedule is customised it is no longer overwritten
+    var brunch map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+otherID+"/hours", http.MethodPost, adminTok, map[string]any{"weekday": 0, "name": "brunch", "openTime": "08:00", "closeTime": "10:30"}, &brunch, 201)
+    var custom map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+otherID, http.MethodPatch, adminTok, map[string]any{"openTime": "12:00"}, &custom, 409)
+    if custom["code"] != "custom_opening_hours" { t.Fatalf("expected customised hours to be kept, got %v", custom) }
+    var unchanged []map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+otherID+"/hours", http.MethodGet, "", nil, &unchanged, 200)
+    if len(unchanged) != 8 || unchanged[1]["openTime"] != "11:00" { t.Fatalf("expected the schedule to be unchanged, got %v", unchanged) }
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+otherID+"/hours/"+brunch["id"].(string), http.MethodDelete, adminTok, nil, nil, 204)
+    // a restaurant created without hours gets the daily schedule once they are set
+    var unscheduled map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants", http.MethodPost, adminTok, map[string]any{"name": "Unscheduled"}, &unscheduled, 201)
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+unscheduled["id"].(string), http.MethodPatch, adminTok, map[string]any{"openTime": "09:00", "closeTime": "17:00"}, nil, 200)
+    var seeded []map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+unscheduled["id"].(string)+"/hours", http.MethodGet, "", nil, &seeded, 200)
+    if len(seeded) != 7 || seeded[0]["openTime"] != "09:00" || seeded[0]["closeTime"] != "17:00" { t.Fatalf("expected the daily schedule to be seeded, got %v", seeded) }
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+otherID, http.MethodPut, adminTok, map[string]any{"timeZone": "Mars/Olympus"}, nil, 400)
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+otherID+"/tables", http.MethodPost, adminTok, map[string]any{"name": "O1", "capacity": 4}, nil, 201)
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+otherID+"/reservations", http.MethodPost, adminTok, map[string]any{"start": start, "guests": 2}, nil, 201)
+    var stats map[string]any
+    day := start.Format("2006-01-02")
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+otherID+"/stats?from="+day+"&to="+day, http.MethodGet, "", nil, nil, 401)
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+otherID+"/stats?from="+day+"&to="+day, http.MethodGet, adminTok, nil, &stats, 200)
+    if stats["totalReservations"].(float64) != 1 || stats["covers"].(float64) != 2 || stats["openTableMinutes"].(float64) != 660 || stats["utilization"].(float64) <= 0 || stats["upcomingReservations"].(float64) != 1 { t.Fatalf("unexpected stats %v", stats) }
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+otherID+"/stats?from="+day+"&to=2000-01-01", http.MethodGet, adminTok, nil, nil, 400)
+    var before map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+otherID+"/stats?from=2020-01-06&to=2020-01-06", http.MethodGet, adminTok, nil, &before, 200)
+    if before["openTableMinutes"].(float64) != 0 { t.Fatalf("expected no open table time before the tables were added, got %v", before) }
+    var report map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+otherID+"/reports/occupancy?from="+day+"&to="+day, http.MethodGet, userTok, nil, nil, 403)
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+otherID+"/reports/occupancy?from="+day+"&to="+day, http.MethodGet, adminTok, nil, &report, 200)
+    noon := report["byHour"].([]any)[12].(map[string]any)
+    if noon["bookedTableMinutes"].(float64) != 60 || noon["utilization"].(float64) != 100 { t.Fatalf("expected the noon hour to be fully booked, got %v", noon) }
+    if weekday := report["byWeekday"].([]any)[(int(start.Weekday())+6)%7].(map[string]any); weekday["weekday"] != strings.ToLower(start.Weekday().String()) || weekday["bookedTableMinutes"].(float64) == 0 { t.Fatalf("unexpected weekday usage %v", weekday) }
+    waste := report["capacityWaste"].(map[string]any)
+    if report["reservations"].(float64) != 1 || report["averagePartySize"].(float64) != 2 || report["averageLeadHours"].(float64) <= 0 || waste["emptySeats"].(float64) != 2 || waste["rate"].(float64) != 50 { t.Fatalf("unexpected occupancy report %v", report) }
+    var otherDetails map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+otherID+"/details", http.MethodGet, "", nil, &otherDetails, 200)
+    if st := otherDetails["stats"].(map[string]any); st["totalReservations"].(float64) != 1 || st["activeReservations"].(float64) != 1 { t.Fatalf("expected real reservation counts in the details, got %v", st) }
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+otherID+"/purge", http.MethodDelete, adminTok, nil, nil, 409)
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+otherID, http.MethodDelete, adminTok, nil, &other, 200)
+    if other["archivedAt"] == nil { t.Fatalf("expected the restaurant to be archived, got %v", other) }
+    var listed []map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants", http.MethodGet, "", nil, &listed, 200)
+    for _, l := range listed { if l["id"] == otherID { t.Fatalf("expected the archived restaurant to be hidden, got %v", listed) } }
+    doJSON(t, ts.URL+"/api/v1/restaurants/archived", http.MethodGet, adminTok, nil, &listed, 200)
+    if len(listed) != 1 || listed[0]["id"] != otherID { t.Fatalf("expected the archived restaurant, got %v", listed) }
+    var refused map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+otherID+"/reservations", http.MethodPost, adminTok, map[string]any{"start": start.Add(3 * time.Hour), "guests": 2}, &refused, 409)
+    if refused["code"] != "restaurant_archived" { t.Fatalf("expected bookings to be refused, got %v", refused) }
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+otherID, http.MethodGet, "", nil, nil, 200)
+    var preview map[string]any
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+otherID+"/purge", http.MethodGet, adminTok, nil, &preview, 200)
+    if preview["tables"].(float64) != 1 || preview["reservations"].(float64) != 1 || preview["upcomingReservations"].(float64) != 1 || preview["servicePeriods"].(float64) != 7 { t.Fatalf("unexpected purge preview %v", preview) }
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+otherID+"/restore", http.MethodPost, adminTok, nil, nil, 200)
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+otherID+"/restore", http.MethodPost, adminTok, nil, nil, 409)
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+otherID, http.MethodDelete, adminTok, nil, nil, 200)
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+otherID+"/purge", http.MethodDelete, adminTok, nil, &preview, 200)
+    if preview["reservations"].(float64) != 1 || preview["tables"].(float64) != 1 { t.Fatalf("unexpected purge result %v", preview) }
+    doJSON(t, ts.URL+"/api/v1/restaurants/"+otherID, http.MethodGet, "", nil, nil, 404)
 }
 
 func login(t *testing.T, base, email, password string) string {
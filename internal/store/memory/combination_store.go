@@ -0,0 +1,68 @@
+package memory
+
+import (
+    "errors"
+    "sort"
+    "sync"
+    "time"
+
+    "orderation/internal/models"
+)
+
+type TableCombinationStore struct {
+    mu           sync.RWMutex
+    byID         map[string]*models.TableCombination
+    byRestaurant map[string][]string
+}
+
+func NewTableCombinationStore() *TableCombinationStore {
+    return &TableCombinationStore{byID: map[string]*models.TableCombination{}, byRestaurant: map[string][]string{}}
+}
+
+func (s *TableCombinationStore) Create(c *models.TableCombination) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if c.ID == "" {
+        c.ID = newID()
+    }
+    c.CreatedAt = time.Now()
+    s.byID[c.ID] = c
+    s.byRestaurant[c.RestaurantID] = append(s.byRestaurant[c.RestaurantID], c.ID)
+    return nil
+}
+
+func (s *TableCombinationStore) ListByRestaurant(restaurantID string) ([]*models.TableCombination, error) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    ids := s.byRestaurant[restaurantID]
+    out := make([]*models.TableCombination, 0, len(ids))
+    for _, id := range ids {
+        if c := s.byID[id]; c != nil {
+            out = append(out, c)
+        }
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].Capacity < out[j].Capacity })
+    return out, nil
+}
+
+func (s *TableCombinationStore) ByID(id string) (*models.TableCombination, error) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    c := s.byID[id]
+    if c == nil {
+        return nil, errors.New("not found")
+    }
+    return c, nil
+}
+
+func (s *TableCombinationStore) Delete(id string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    c := s.byID[id]
+    if c == nil {
+        return errors.New("not found")
+    }
+    delete(s.byID, id)
+    s.byRestaurant[c.RestaurantID] = removeID(s.byRestaurant[c.RestaurantID], id)
+    return nil
+}
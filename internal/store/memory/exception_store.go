@@ -0,0 +1,73 @@
+package memory
+
+import (
+    "errors"
+    "sort"
+    "sync"
+    "time"
+
+    "orderation/internal/models"
+)
+
+type ExceptionStore struct {
+    mu   sync.RWMutex
+    byID map[string]*models.HoursException
+}
+
+func NewExceptionStore() *ExceptionStore {
+    return &ExceptionStore{byID: map[string]*models.HoursException{}}
+}
+
+func (s *ExceptionStore) Create(e *models.HoursException) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    for _, other := range s.byID {
+        if other.RestaurantID == e.RestaurantID && other.Date == e.Date {
+            return errors.New("exception already exists for date")
+        }
+    }
+    if e.ID == "" {
+        e.ID = newID()
+    }
+    e.CreatedAt = time.Now()
+    s.byID[e.ID] = e
+    return nil
+}
+
+func (s *ExceptionStore) ListByRestaurant(restaurantID, from, to string) ([]*models.HoursException, error) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    out := []*models.HoursException{}
+    for _, e := range s.byID {
+        if e.RestaurantID != restaurantID {
+            continue
+        }
+        // YYYY-MM-DD strings order the same way as the dates they name
+        if (from != "" && e.Date < from) || (to != "" && e.Date > to) {
+            continue
+        }
+        out = append(out, e)
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].Date < out[j].Date })
+    return out, nil
+}
+
+func (s *ExceptionStore) ByID(id string) (*models.HoursException, error) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    e := s.byID[id]
+    if e == nil {
+        return nil, errors.New("not found")
+    }
+    return e, nil
+}
+
+func (s *ExceptionStore) Delete(id string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if s.byID[id] == nil {
+        return errors.New("not found")
+    }
+    delete(s.byID, id)
+    return nil
+}
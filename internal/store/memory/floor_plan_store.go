@@ -0,0 +1,144 @@
+package memory
+
+import (
+    "errors"
+    "sort"
+    "sync"
+    "time"
+
+    "orderation/internal/models"
+)
+
+type FloorPlanStore struct {
+    mu        sync.RWMutex
+    plans     map[string]*models.FloorPlan     // by restaurant
+    sections  map[string]*models.FloorSection  // by ID
+    positions map[string]*models.TablePosition // by table
+}
+
+func NewFloorPlanStore() *FloorPlanStore {
+    return &FloorPlanStore{plans: map[string]*models.FloorPlan{}, sections: map[string]*models.FloorSection{}, positions: map[string]*models.TablePosition{}}
+}
+
+func (s *FloorPlanStore) Plan(restaurantID string) (*models.FloorPlan, error) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    p := s.plans[restaurantID]
+    if p == nil {
+        return nil, errors.New("not found")
+    }
+    return p, nil
+}
+
+func (s *FloorPlanStore) SavePlan(p *models.FloorPlan) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    p.UpdatedAt = time.Now()
+    cp := *p
+    s.plans[p.RestaurantID] = &cp
+    return nil
+}
+
+func (s *FloorPlanStore) CreateSection(sec *models.FloorSection) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if sec.ID == "" {
+        sec.ID = newID()
+    }
+    sec.CreatedAt = time.Now()
+    s.sections[sec.ID] = sec
+    return nil
+}
+
+func (s *FloorPlanStore) SectionByID(id string) (*models.FloorSection, error) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    sec := s.sections[id]
+    if sec == nil {
+        return nil, errors.New("not found")
+    }
+    return sec, nil
+}
+
+func (s *FloorPlanStore) UpdateSection(sec *models.FloorSection) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if s.sections[sec.ID] == nil {
+        return errors.New("not found")
+    }
+    cp := *sec
+    s.sections[sec.ID] = &cp
+    return nil
+}
+
+func (s *FloorPlanStore) DeleteSection(id string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if s.sections[id] == nil {
+        return errors.New("not found")
+    }
+    delete(s.sections, id)
+    return nil
+}
+
+func (s *FloorPlanStore) ListSections(restaurantID string) ([]*models.FloorSection, error) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    out := []*models.FloorSection{}
+    for _, sec := range s.sections {
+        if sec.RestaurantID == restaurantID {
+            out = append(out, sec)
+        }
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+    return out, nil
+}
+
+func (s *FloorPlanStore) SetPosition(p *models.TablePosition) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    p.UpdatedAt = time.Now()
+    cp := *p
+    s.positions[p.TableID] = &cp
+    return nil
+}
+
+func (s *FloorPlanStore) DeletePosition(tableID string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if s.positions[tableID] == nil {
+        return errors.New("not found")
+    }
+    delete(s.positions, tableID)
+    return nil
+}
+
+func (s *FloorPlanStore) ListPositions(restaurantID string) ([]*models.TablePosition, error) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    out := []*models.TablePosition{}
+    for _, p := range s.positions {
+        if p.RestaurantID == restaurantID {
+            out = append(out, p)
+        }
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].TableID < out[j].TableID })
+    return out, nil
+}
+
+func (s *FloorPlanStore) DeleteByRestaurant(restaurantID string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    delete(s.plans, restaurantID)
+    for id, sec := range s.sections {
+        if sec.RestaurantID == restaurantID {
+            delete(s.sections, id)
+        }
+    }
+    for id, p := range s.positions {
+        if p.RestaurantID == restaurantID {
+            delete(s.positions, id)
+        }
+    }
+    return nil
+}
@@ -0,0 +1,87 @@
+package memory
+
+import (
+    "errors"
+    "sort"
+    "sync"
+    "time"
+
+    "orderation/internal/models"
+)
+
+type HoursStore struct {
+    mu           sync.RWMutex
+    byID         map[string]*models.ServicePeriod
+    byRestaurant map[string][]string
+}
+
+func NewHoursStore() *HoursStore {
+    return &HoursStore{byID: map[string]*models.ServicePeriod{}, byRestaurant: map[string][]string{}}
+}
+
+func (s *HoursStore) Create(p *models.ServicePeriod) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if p.ID == "" {
+        p.ID = newID()
+    }
+    p.CreatedAt = time.Now()
+    s.byID[p.ID] = p
+    s.byRestaurant[p.RestaurantID] = append(s.byRestaurant[p.RestaurantID], p.ID)
+    return nil
+}
+
+func (s *HoursStore) ListByRestaurant(restaurantID string) ([]*models.ServicePeriod, error) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    ids := s.byRestaurant[restaurantID]
+    out := make([]*models.ServicePeriod, 0, len(ids))
+    for _, id := range ids {
+        if p := s.byID[id]; p != nil {
+            out = append(out, p)
+        }
+    }
+    sort.Slice(out, func(i, j int) bool {
+        if out[i].Weekday != out[j].Weekday {
+            return out[i].Weekday < out[j].Weekday
+        }
+        return out[i].OpenTime < out[j].OpenTime
+    })
+    return out, nil
+}
+
+func (s *HoursStore) ByID(id string) (*models.ServicePeriod, error) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    p := s.byID[id]
+    if p == nil {
+        return nil, errors.New("not found")
+    }
+    return p, nil
+}
+
+func (s *HoursStore) Update(p *models.ServicePeriod) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    cur := s.byID[p.ID]
+    if cur == nil {
+        return errors.New("not found")
+    }
+    cur.Weekday = p.Weekday
+    cur.Name = p.Name
+    cur.OpenTime = p.OpenTime
+    cur.CloseTime = p.CloseTime
+    return nil
+}
+
+func (s *HoursStore) Delete(id string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    p := s.byID[id]
+    if p == nil {
+        return errors.New("not found")
+    }
+    delete(s.byID, id)
+    s.byRestaurant[p.RestaurantID] = removeID(s.byRestaurant[p.RestaurantID], id)
+    return nil
+}
@@ -0,0 +1,68 @@
+package memory
+
+import (
+    "errors"
+    "sync"
+    "time"
+
+    "orderation/internal/models"
+)
+
+type IdempotencyStore struct {
+    mu    sync.Mutex
+    byKey map[string]*models.IdempotencyRecord
+}
+
+func NewIdempotencyStore() *IdempotencyStore {
+    return &IdempotencyStore{byKey: map[string]*models.IdempotencyRecord{}}
+}
+
+func (s *IdempotencyStore) Begin(rec *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    now := time.Now()
+    if cur := s.byKey[rec.Key]; cur != nil && !cur.Expired(now) {
+        cp := *cur
+        return &cp, nil
+    }
+    if rec.CreatedAt.IsZero() {
+        rec.CreatedAt = now
+    }
+    cp := *rec
+    s.byKey[rec.Key] = &cp
+    return nil, nil
+}
+
+func (s *IdempotencyStore) Complete(rec *models.IdempotencyRecord) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    cur := s.byKey[rec.Key]
+    if cur == nil {
+        return errors.New("not found")
+    }
+    cur.Completed = true
+    cur.StatusCode = rec.StatusCode
+    cur.ContentType = rec.ContentType
+    cur.Body = append([]byte(nil), rec.Body...)
+    return nil
+}
+
+func (s *IdempotencyStore) Abandon(key string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    delete(s.byKey, key)
+    return nil
+}
+
+func (s *IdempotencyStore) DeleteExpired(now time.Time) (int, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    n := 0
+    for k, rec := range s.byKey {
+        if rec.Expired(now) {
+            delete(s.byKey, k)
+            n++
+        }
+    }
+    return n, nil
+}
@@ -15,26 +15,129 @@ type ReservationStore struct {
     byID   map[string]*models.Reservation
     byUser map[string][]string
     byTab  map[string][]string
+    tables *TableStore      // optional, see UseTables
+    blocks *TableBlockStore
 }
 
 func NewReservationStore() *ReservationStore {
     return &ReservationStore{byID: map[string]*models.Reservation{}, byUser: map[string][]string{}, byTab: map[string][]string{}}
 }
 
+// UseTables makes CreateIfFree and UpdateIfFree also refuse tables that are
+// deleted in tables or blocked in blocks, and returns s.
+func (s *ReservationStore) UseTables(tables *TableStore, blocks *TableBlockStore) *ReservationStore {
+    s.tables, s.blocks = tables, blocks
+    return s
+}
+
 func (s *ReservationStore) Create(r *models.Reservation) error {
     s.mu.Lock()
     defer s.mu.Unlock()
+    s.insertLocked(r)
+    return nil
+}
+
+func (s *ReservationStore) CreateIfFree(r *models.Reservation) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if tid := s.conflictLocked(r); tid != "" {
+        return &store.ConflictError{TableID: tid}
+    }
+    s.insertLocked(r)
+    return nil
+}
+
+func (s *ReservationStore) UpdateIfFree(r *models.Reservation) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    cur := s.byID[r.ID]
+    if cur == nil {
+        return errors.New("not found")
+    }
+    if !models.HoldsTable(cur.Status) {
+        return errors.New("reservation can no longer be changed")
+    }
+    if tid := s.conflictLocked(r); tid != "" {
+        return &store.ConflictError{TableID: tid}
+    }
+    for _, tid := range cur.Tables() {
+        s.byTab[tid] = removeID(s.byTab[tid], cur.ID)
+    }
+    cur.TableID = r.TableID
+    cur.TableIDs = append([]string(nil), r.Tables()...)
+    cur.CombinationID = r.CombinationID
+    for _, tid := range cur.TableIDs {
+        s.byTab[tid] = append(s.byTab[tid], cur.ID)
+    }
+    cur.StartTime = r.StartTime
+    cur.EndTime = r.EndTime
+    cur.Guests = r.Guests
+    cur.TurnoverMinutes = r.TurnoverMinutes
+    return nil
+}
+
+// conflictLocked returns the first of r's tables that is out of use or that
+// another reservation holds during r's time and turnover, or ""; the caller
+// must hold s.mu.
+func (s *ReservationStore) conflictLocked(r *models.Reservation) string {
+    now := time.Now()
+    for _, tid := range r.Tables() {
+        if s.outOfUse(r.RestaurantID, tid, r.StartTime, r.ClearAt(now)) {
+            return tid
+        }
+        for _, id := range s.byTab[tid] {
+            if id == r.ID {
+                continue
+            }
+            if other := s.byID[id]; other != nil && other.Occupies(r.StartTime, r.ClearAt(now), now) {
+                return tid
+            }
+        }
+    }
+    return ""
+}
+
+// outOfUse reports whether the table is deleted or blocked during
+// [start, end), as far as the stores given to UseTables know.
+func (s *ReservationStore) outOfUse(restaurantID, tableID string, start, end time.Time) bool {
+    if s.tables != nil {
+        if t, err := s.tables.ByID(tableID); err == nil && t.DeletedAt != nil {
+            return true
+        }
+    }
+    if s.blocks != nil {
+        blocks, _ := s.blocks.ListByRestaurant(restaurantID, start, end)
+        for _, b := range blocks {
+            if b.TableID == tableID {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+// insertLocked stores r; the caller must hold s.mu for writing.
+func (s *ReservationStore) insertLocked(r *models.Reservation) {
     if r.ID == "" {
         r.ID = newID()
     }
     r.CreatedAt = time.Now()
     if r.Status == "" {
-        r.Status = "confirmed"
+        r.Status = models.StatusConfirmed
+    }
+    if r.Status == models.StatusConfirmed && r.ConfirmedAt == nil {
+        r.SetStatus(models.StatusConfirmed, r.CreatedAt)
     }
     s.byID[r.ID] = r
-    s.byUser[r.UserID] = append(s.byUser[r.UserID], r.ID)
-    s.byTab[r.TableID] = append(s.byTab[r.TableID], r.ID)
-    return nil
+    if r.UserID != "" {
+        s.byUser[r.UserID] = append(s.byUser[r.UserID], r.ID)
+    }
+    if len(r.TableIDs) == 0 {
+        r.TableIDs = []string{r.TableID}
+    }
+    for _, tid := range r.TableIDs {
+        s.byTab[tid] = append(s.byTab[tid], r.ID)
+    }
 }
 
 func (s *ReservationStore) ByID(id string) (*models.Reservation, error) {
@@ -47,17 +150,119 @@ func (s *ReservationStore) ByID(id string) (*models.Reservation, error) {
     return r, nil
 }
 
+func (s *ReservationStore) ByConfirmationCode(code string) (*models.Reservation, error) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    for _, r := range s.byID {
+        if code != "" && r.ConfirmationCode == code {
+            return r, nil
+        }
+    }
+    return nil, errors.New("not found")
+}
+
 func (s *ReservationStore) Cancel(id string) error {
+    return s.UpdateStatus(id, models.StatusCancelled, time.Now())
+}
+
+func (s *ReservationStore) CancelWithReason(id, reason string, late bool, at time.Time) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if err := s.updateStatusLocked(id, models.StatusCancelled, at); err != nil {
+        return err
+    }
+    r := s.byID[id]
+    r.CancelReason = reason
+    r.LateCancel = late
+    return nil
+}
+
+func (s *ReservationStore) UpdateStatus(id, status string, at time.Time) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return s.updateStatusLocked(id, status, at)
+}
+
+// updateStatusLocked implements UpdateStatus; the caller must hold s.mu.
+func (s *ReservationStore) updateStatusLocked(id, status string, at time.Time) error {
+    r := s.byID[id]
+    if r == nil {
+        return errors.New("not found")
+    }
+    if !models.CanTransition(r.Status, status) {
+        return &store.TransitionError{From: r.Status, To: status}
+    }
+    if status == models.StatusConfirmed && r.HoldExpired(at) {
+        return store.ErrHoldExpired
+    }
+    r.SetStatus(status, at)
+    r.HoldExpiresAt = nil
+    return nil
+}
+
+func (s *ReservationStore) UpdateSpecialRequests(id string, sr models.SpecialRequests) error {
     s.mu.Lock()
     defer s.mu.Unlock()
     r := s.byID[id]
     if r == nil {
         return errors.New("not found")
     }
-    r.Status = "cancelled"
+    sr.Dietary = append([]string(nil), sr.Dietary...)
+    sr.Accessibility = append([]string(nil), sr.Accessibility...)
+    if sr.Seating != nil {
+        seating := *sr.Seating
+        sr.Seating = &seating
+    }
+    r.SpecialRequests = sr
     return nil
 }
 
+func (s *ReservationStore) DeleteHold(id string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    r := s.byID[id]
+    if r == nil || r.Status != models.StatusHeld {
+        return errors.New("not found")
+    }
+    s.deleteLocked(r)
+    return nil
+}
+
+func (s *ReservationStore) DeleteExpiredHolds(now time.Time) (int, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    n := 0
+    for _, r := range s.byID {
+        if r.HoldExpired(now) {
+            s.deleteLocked(r)
+            n++
+        }
+    }
+    return n, nil
+}
+
+func (s *ReservationStore) DeleteByRestaurant(restaurantID string) (int, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    n := 0
+    for _, r := range s.byID {
+        if r.RestaurantID == restaurantID {
+            s.deleteLocked(r)
+            n++
+        }
+    }
+    return n, nil
+}
+
+// deleteLocked removes r and its index entries; the caller must hold s.mu.
+func (s *ReservationStore) deleteLocked(r *models.Reservation) {
+    delete(s.byID, r.ID)
+    s.byUser[r.UserID] = removeID(s.byUser[r.UserID], r.ID)
+    for _, tid := range r.Tables() {
+        s.byTab[tid] = removeID(s.byTab[tid], r.ID)
+    }
+}
+
 func (s *ReservationStore) ListByUser(userID string) ([]*models.Reservation, error) {
     s.mu.RLock()
     defer s.mu.RUnlock()
@@ -72,27 +277,242 @@ func (s *ReservationStore) ListByUser(userID string) ([]*models.Reservation, err
     return out, nil
 }
 
+func (s *ReservationStore) ListByRestaurant(restaurantID string, from, to time.Time) ([]*models.Reservation, error) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    out := []*models.Reservation{}
+    for _, r := range s.byID {
+        if r.RestaurantID == restaurantID && !r.StartTime.Before(from) && r.StartTime.Before(to) {
+            out = append(out, r)
+        }
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].StartTime.Before(out[j].StartTime) })
+    return out, nil
+}
+
+func (s *ReservationStore) ListBySeries(seriesID string) ([]*models.Reservation, error) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    out := []*models.Reservation{}
+    for _, r := range s.byID {
+        if seriesID != "" && r.SeriesID == seriesID {
+            out = append(out, r)
+        }
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].StartTime.Before(out[j].StartTime) })
+    return out, nil
+}
+
+func (s *ReservationStore) UpcomingByUser(userID string, from time.Time) ([]*models.Reservation, error) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    now := time.Now()
+    var out []*models.Reservation
+    for _, id := range s.byUser[userID] {
+        r := s.byID[id]
+        if r != nil && models.HoldsTable(r.Status) && !r.HoldExpired(now) && r.EndTime.After(from) {
+            out = append(out, r)
+        }
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].StartTime.Before(out[j].StartTime) })
+    return out, nil
+}
+
+func (s *ReservationStore) UpcomingByGuest(email, phone string, from time.Time) ([]*models.Reservation, error) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    now := time.Now()
+    var out []*models.Reservation
+    for _, r := range s.byID {
+        if r.UserID != "" || !((email != "" && r.GuestEmail == email) || (phone != "" && r.GuestPhone == phone)) {
+            continue
+        }
+        if models.HoldsTable(r.Status) && !r.HoldExpired(now) && r.EndTime.After(from) {
+            out = append(out, r)
+        }
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].StartTime.Before(out[j].StartTime) })
+    return out, nil
+}
+
+func (s *ReservationStore) CountStarts(restaurantID string, from, to time.Time, excludeID string) ([]store.StartCount, error) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    now := time.Now()
+    byStart := map[int64]*store.StartCount{}
+    for _, r := range s.byID {
+        if r.RestaurantID != restaurantID || r.ID == excludeID || !models.HoldsTable(r.Status) || r.HoldExpired(now) {
+            continue
+        }
+        if r.StartTime.Before(from) || !r.StartTime.Before(to) {
+            continue
+        }
+        c := byStart[r.StartTime.UnixNano()]
+        if c == nil {
+            c = &store.StartCount{Start: r.StartTime}
+            byStart[r.StartTime.UnixNano()] = c
+        }
+        c.Parties++
+        c.Guests += r.Guests
+    }
+    out := make([]store.StartCount, 0, len(byStart))
+    for _, c := range byStart {
+        out = append(out, *c)
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].Start.Before(out[j].Start) })
+    return out, nil
+}
+
+func (s *ReservationStore) CountByStatus(restaurantID string, from, to time.Time) ([]store.StatusCount, error) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    now := time.Now()
+    byStatus := map[string]*store.StatusCount{}
+    for _, r := range s.byID {
+        if r.RestaurantID != restaurantID || r.HoldExpired(now) || r.StartTime.Before(from) || !r.StartTime.Before(to) {
+            continue
+        }
+        c := byStatus[r.Status]
+        if c == nil {
+            c = &store.StatusCount{Status: r.Status}
+            byStatus[r.Status] = c
+        }
+        c.Parties++
+        c.Guests += r.Guests
+    }
+    out := make([]store.StatusCount, 0, len(byStatus))
+    for _, c := range byStatus {
+        out = append(out, *c)
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].Status < out[j].Status })
+    return out, nil
+}
+
+func (s *ReservationStore) TableMinutes(restaurantID string, from, to time.Time) (int, error) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    total := 0
+    for _, r := range s.byID {
+        if r.RestaurantID != restaurantID || !models.Booked(r.Status) || !r.StartTime.Before(to) || !r.EndTime.After(from) {
+            continue
+        }
+        start, end := r.StartTime, r.EndTime
+        if start.Before(from) {
+            start = from
+        }
+        if end.After(to) {
+            end = to
+        }
+        total += int(end.Sub(start)/time.Minute) * len(r.Tables())
+    }
+    return total, nil
+}
+
+func (s *ReservationStore) HourlyTableMinutes(restaurantID string, from, to time.Time, loc *time.Location) ([]store.HourCount, error) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    byHour := map[int64]*store.HourCount{}
+    for _, r := range s.byID {
+        if r.RestaurantID != restaurantID || !models.Booked(r.Status) || !r.StartTime.Before(to) || !r.EndTime.After(from) {
+            continue
+        }
+        start, end := r.StartTime, r.EndTime
+        if start.Before(from) {
+            start = from
+        }
+        if end.After(to) {
+            end = to
+        }
+        for start = start.In(loc); start.Before(end); {
+            hour := store.HourStart(start)
+            next := hour.Add(time.Hour)
+            if next.After(end) {
+                next = end
+            }
+            c := byHour[hour.Unix()]
+            if c == nil {
+                c = &store.HourCount{Hour: hour}
+                byHour[hour.Unix()] = c
+            }
+            c.TableMinutes += int(next.Sub(start)/time.Minute) * len(r.Tables())
+            start = next.In(loc)
+        }
+    }
+    out := make([]store.HourCount, 0, len(byHour))
+    for _, c := range byHour {
+        out = append(out, *c)
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].Hour.Before(out[j].Hour) })
+    return out, nil
+}
+
+func (s *ReservationStore) CountBySeating(restaurantID string, from, to time.Time) ([]store.SeatingCount, error) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    bySeating := map[[2]string]*store.SeatingCount{}
+    for _, r := range s.byID {
+        if r.RestaurantID != restaurantID || !models.Booked(r.Status) || r.StartTime.Before(from) || !r.StartTime.Before(to) {
+            continue
+        }
+        key := [2]string{r.TableID, r.CombinationID}
+        c := bySeating[key]
+        if c == nil {
+            c = &store.SeatingCount{TableID: r.TableID, CombinationID: r.CombinationID}
+            bySeating[key] = c
+        }
+        c.Parties++
+        c.Guests += r.Guests
+    }
+    out := make([]store.SeatingCount, 0, len(bySeating))
+    for _, c := range bySeating {
+        out = append(out, *c)
+    }
+    sort.Slice(out, func(i, j int) bool {
+        if out[i].TableID != out[j].TableID {
+            return out[i].TableID < out[j].TableID
+        }
+        return out[i].CombinationID < out[j].CombinationID
+    })
+    return out, nil
+}
+
+func (s *ReservationStore) LeadTime(restaurantID string, from, to time.Time) (store.LeadTime, error) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    var lt store.LeadTime
+    for _, r := range s.byID {
+        if r.RestaurantID != restaurantID || !models.Booked(r.Status) || r.StartTime.Before(from) || !r.StartTime.Before(to) {
+            continue
+        }
+        lt.Parties++
+        if lead := r.StartTime.Sub(r.CreatedAt); lead > 0 {
+            lt.Minutes += int(lead / time.Minute)
+        }
+    }
+    return lt, nil
+}
+
 func (s *ReservationStore) ListOverlap(f store.ReservationFilter) ([]*models.Reservation, error) {
     s.mu.RLock()
     defer s.mu.RUnlock()
+    now := time.Now()
     var out []*models.Reservation
     for _, r := range s.byID {
         if f.RestaurantID != "" && r.RestaurantID != f.RestaurantID {
             continue
         }
-        if f.TableID != "" && r.TableID != f.TableID {
+        if f.TableID != "" && !r.UsesTable(f.TableID) {
             continue
         }
         if f.UserID != "" && r.UserID != f.UserID {
             continue
         }
-        if r.Status == "cancelled" {
+        if f.ExcludeID != "" && r.ID == f.ExcludeID {
             continue
         }
         // overlap if (r.Start < f.EndAfter) && (r.End > f.StartBefore)
-        if r.StartTime.Before(f.EndAfter) && r.EndTime.After(f.StartBefore) {
+        if r.Occupies(f.StartBefore, f.EndAfter, now) {
             out = append(out, r)
-            continue
         }
     }
     sort.Slice(out, func(i, j int) bool { return out[i].StartTime.Before(out[j].StartTime) })
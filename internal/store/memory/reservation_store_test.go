@@ -1,6 +1,8 @@
 package memory
 
 import (
+    "errors"
+    "sync"
     "testing"
     "time"
 
@@ -29,3 +31,347 @@ func TestListOverlap(t *testing.T) {
     }
 }
 
+
+func TestCreateIfFreeConcurrent(t *testing.T) {
+    s := NewReservationStore()
+    base := time.Now().Truncate(time.Hour)
+    var wg sync.WaitGroup
+    var mu sync.Mutex
+    created, conflicts := 0, 0
+    for i := 0; i < 20; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            err := s.CreateIfFree(&models.Reservation{RestaurantID: "r1", TableID: "t1", UserID: "u1", StartTime: base.Add(time.Hour), EndTime: base.Add(2 * time.Hour)})
+            var ce *store.ConflictError
+            mu.Lock()
+            defer mu.Unlock()
+            switch {
+            case err == nil:
+                created++
+            case errors.As(err, &ce):
+                conflicts++
+            default:
+                t.Errorf("unexpected error: %v", err)
+            }
+        }()
+    }
+    wg.Wait()
+    if created != 1 || conflicts != 19 {
+        t.Fatalf("expected 1 created and 19 conflicts, got %d and %d", created, conflicts)
+    }
+
+    // adjacent slot on the same table is still free
+    if err := s.CreateIfFree(&models.Reservation{RestaurantID: "r1", TableID: "t1", UserID: "u2", StartTime: base.Add(2 * time.Hour), EndTime: base.Add(3 * time.Hour)}); err != nil {
+        t.Fatalf("expected adjacent slot to be free, got %v", err)
+    }
+}
+
+func TestIfFreeRefusesTablesOutOfUse(t *testing.T) {
+    tables, blocks := NewTableStore(), NewTableBlockStore()
+    s := NewReservationStore().UseTables(tables, blocks)
+    base := time.Now().Truncate(time.Hour).Add(24 * time.Hour)
+    t1 := &models.Table{RestaurantID: "r1", Name: "T1", Capacity: 4}
+    t2 := &models.Table{RestaurantID: "r1", Name: "T2", Capacity: 4}
+    _ = tables.Create(t1)
+    _ = tables.Create(t2)
+    _ = blocks.Create(&models.TableBlock{RestaurantID: "r1", TableID: t1.ID, Start: base.Add(2 * time.Hour), End: base.Add(3 * time.Hour)})
+
+    var ce *store.ConflictError
+    r := &models.Reservation{RestaurantID: "r1", TableID: t1.ID, UserID: "u1", StartTime: base.Add(90 * time.Minute), EndTime: base.Add(150 * time.Minute)}
+    if err := s.CreateIfFree(r); !errors.As(err, &ce) || ce.TableID != t1.ID {
+        t.Fatalf("expected the blocked table to conflict, got %v", err)
+    }
+    r = &models.Reservation{RestaurantID: "r1", TableID: t1.ID, UserID: "u1", StartTime: base, EndTime: base.Add(time.Hour)}
+    if err := s.CreateIfFree(r); err != nil {
+        t.Fatalf("expected the table to be free before the block, got %v", err)
+    }
+
+    // a deleted table can neither be booked nor moved to
+    _ = tables.Delete(t2.ID, time.Now())
+    if err := s.CreateIfFree(&models.Reservation{RestaurantID: "r1", TableID: t2.ID, UserID: "u2", StartTime: base, EndTime: base.Add(time.Hour)}); !errors.As(err, &ce) {
+        t.Fatalf("expected the deleted table to conflict, got %v", err)
+    }
+    moved := *r
+    moved.TableID, moved.TableIDs = t2.ID, nil
+    if err := s.UpdateIfFree(&moved); !errors.As(err, &ce) {
+        t.Fatalf("expected moving to the deleted table to conflict, got %v", err)
+    }
+    if got, _ := s.ByID(r.ID); got.TableID != t1.ID {
+        t.Fatalf("expected the reservation to stay on %s, got %s", t1.ID, got.TableID)
+    }
+}
+
+func TestUpdateStatusLifecycle(t *testing.T) {
+    s := NewReservationStore()
+    now := time.Now()
+    r := &models.Reservation{RestaurantID: "r1", TableID: "t1", UserID: "u1", StartTime: now.Add(-2 * time.Hour), EndTime: now.Add(-time.Hour)}
+    _ = s.Create(r)
+    if r.Status != models.StatusConfirmed || r.ConfirmedAt == nil {
+        t.Fatalf("expected new reservation to be confirmed with a timestamp, got %q", r.Status)
+    }
+
+    // a confirmed reservation whose time has passed no longer holds the table
+    window := store.ReservationFilter{TableID: "t1", StartBefore: now, EndAfter: now.Add(time.Hour)}
+    if list, _ := s.ListOverlap(window); len(list) != 0 {
+        t.Fatalf("expected past confirmed reservation not to overlap, got %d", len(list))
+    }
+
+    // a seated party that overstays keeps the table until completed
+    if err := s.UpdateStatus(r.ID, models.StatusSeated, now.Add(-2*time.Hour)); err != nil {
+        t.Fatalf("seat: %v", err)
+    }
+    if list, _ := s.ListOverlap(window); len(list) != 1 {
+        t.Fatalf("expected seated overstay to overlap, got %d", len(list))
+    }
+
+    var te *store.TransitionError
+    if err := s.UpdateStatus(r.ID, models.StatusCancelled, now); !errors.As(err, &te) {
+        t.Fatalf("expected transition error cancelling a seated party, got %v", err)
+    }
+    if err := s.UpdateStatus(r.ID, models.StatusCompleted, now); err != nil {
+        t.Fatalf("complete: %v", err)
+    }
+    if r.SeatedAt == nil || r.CompletedAt == nil {
+        t.Fatalf("expected seated and completed timestamps")
+    }
+    if list, _ := s.ListOverlap(window); len(list) != 0 {
+        t.Fatalf("expected completed reservation to free the table, got %d", len(list))
+    }
+}
+
+func TestUpdateIfFree(t *testing.T) {
+    s := NewReservationStore()
+    base := time.Now().Truncate(time.Hour).Add(24 * time.Hour)
+    r1 := &models.Reservation{RestaurantID: "r1", TableID: "t1", UserID: "u1", StartTime: base, EndTime: base.Add(2 * time.Hour), Guests: 2}
+    r2 := &models.Reservation{RestaurantID: "r1", TableID: "t1", UserID: "u2", StartTime: base.Add(3 * time.Hour), EndTime: base.Add(5 * time.Hour), Guests: 2}
+    _ = s.Create(r1)
+    _ = s.Create(r2)
+
+    // shifting within its own slot does not conflict with itself
+    moved := *r1
+    moved.StartTime, moved.EndTime = base.Add(time.Hour), base.Add(3*time.Hour)
+    if err := s.UpdateIfFree(&moved); err != nil {
+        t.Fatalf("expected move to succeed, got %v", err)
+    }
+
+    // overlapping r2 is refused and r1 keeps its current slot
+    clash := *r1
+    clash.StartTime, clash.EndTime = base.Add(2*time.Hour), base.Add(4*time.Hour)
+    var ce *store.ConflictError
+    if err := s.UpdateIfFree(&clash); !errors.As(err, &ce) {
+        t.Fatalf("expected conflict, got %v", err)
+    }
+    got, _ := s.ByID(r1.ID)
+    if !got.StartTime.Equal(base.Add(time.Hour)) {
+        t.Fatalf("expected original slot to be kept, got %v", got.StartTime)
+    }
+
+    // moving to another table updates the table index
+    other := *r1
+    other.TableID = "t2"
+    other.StartTime, other.EndTime = base.Add(3*time.Hour), base.Add(5*time.Hour)
+    if err := s.UpdateIfFree(&other); err != nil {
+        t.Fatalf("expected move to t2 to succeed, got %v", err)
+    }
+    if list, _ := s.ListOverlap(store.ReservationFilter{TableID: "t2", StartBefore: base, EndAfter: base.Add(6 * time.Hour)}); len(list) != 1 {
+        t.Fatalf("expected 1 reservation on t2, got %d", len(list))
+    }
+}
+
+func TestCombinationHoldsEveryTable(t *testing.T) {
+    s := NewReservationStore()
+    base := time.Now().Truncate(time.Hour).Add(24 * time.Hour)
+    big := &models.Reservation{RestaurantID: "r1", TableID: "t1", TableIDs: []string{"t1", "t2"}, CombinationID: "c1", UserID: "u1", StartTime: base, EndTime: base.Add(2 * time.Hour), Guests: 8}
+    if err := s.CreateIfFree(big); err != nil {
+        t.Fatalf("expected combination booking to succeed, got %v", err)
+    }
+
+    // the second member table is blocked as well
+    var ce *store.ConflictError
+    small := &models.Reservation{RestaurantID: "r1", TableID: "t2", UserID: "u2", StartTime: base.Add(time.Hour), EndTime: base.Add(3 * time.Hour), Guests: 2}
+    if err := s.CreateIfFree(small); !errors.As(err, &ce) || ce.TableID != "t2" {
+        t.Fatalf("expected conflict on t2, got %v", err)
+    }
+    if list, _ := s.ListOverlap(store.ReservationFilter{TableID: "t2", StartBefore: base, EndAfter: base.Add(3 * time.Hour)}); len(list) != 1 {
+        t.Fatalf("expected 1 reservation on t2, got %d", len(list))
+    }
+}
+
+func TestTurnoverPadsBookings(t *testing.T) {
+    s := NewReservationStore()
+    base := time.Now().Truncate(time.Hour).Add(24 * time.Hour)
+    first := &models.Reservation{RestaurantID: "r1", TableID: "t1", UserID: "u1", StartTime: base, EndTime: base.Add(2 * time.Hour), Guests: 2, TurnoverMinutes: 30}
+    _ = s.Create(first)
+
+    // starting right at the end leaves no time to reset the table
+    var ce *store.ConflictError
+    next := &models.Reservation{RestaurantID: "r1", TableID: "t1", UserID: "u2", StartTime: base.Add(2 * time.Hour), EndTime: base.Add(4 * time.Hour), Guests: 2, TurnoverMinutes: 30}
+    if err := s.CreateIfFree(next); !errors.As(err, &ce) {
+        t.Fatalf("expected conflict within turnover, got %v", err)
+    }
+    // the new booking's own turnover counts before a later reservation too
+    before := &models.Reservation{RestaurantID: "r1", TableID: "t1", UserID: "u2", StartTime: base.Add(-2 * time.Hour), EndTime: base.Add(-15 * time.Minute), Guests: 2, TurnoverMinutes: 30}
+    if err := s.CreateIfFree(before); !errors.As(err, &ce) {
+        t.Fatalf("expected conflict with own turnover, got %v", err)
+    }
+    next.StartTime, next.EndTime = base.Add(150*time.Minute), base.Add(4*time.Hour)
+    if err := s.CreateIfFree(next); err != nil {
+        t.Fatalf("expected booking after turnover to succeed, got %v", err)
+    }
+}
+
+func TestExpiredHoldsReleaseTables(t *testing.T) {
+    s := NewReservationStore()
+    base := time.Now().Truncate(time.Hour).Add(24 * time.Hour)
+    lapsed := time.Now().Add(-time.Second)
+    hold := &models.Reservation{RestaurantID: "r1", TableID: "t1", UserID: "u1", StartTime: base, EndTime: base.Add(2 * time.Hour), Guests: 2, Status: models.StatusHeld, HoldExpiresAt: &lapsed}
+    _ = s.Create(hold)
+
+    // a lapsed hold no longer blocks the table and cannot be confirmed
+    if err := s.UpdateStatus(hold.ID, models.StatusConfirmed, time.Now()); !errors.Is(err, store.ErrHoldExpired) {
+        t.Fatalf("expected ErrHoldExpired, got %v", err)
+    }
+    other := &models.Reservation{RestaurantID: "r1", TableID: "t1", UserID: "u2", StartTime: base, EndTime: base.Add(2 * time.Hour), Guests: 2}
+    if err := s.CreateIfFree(other); err != nil {
+        t.Fatalf("expected lapsed hold to free the table, got %v", err)
+    }
+
+    if n, _ := s.DeleteExpiredHolds(time.Now()); n != 1 {
+        t.Fatalf("expected 1 expired hold removed, got %d", n)
+    }
+    if _, err := s.ByID(hold.ID); err == nil {
+        t.Fatalf("expected expired hold to be deleted")
+    }
+}
+
+func TestUpcomingByUser(t *testing.T) {
+    s := NewReservationStore()
+    now := time.Now()
+    past := &models.Reservation{RestaurantID: "r1", TableID: "t1", UserID: "u1", StartTime: now.Add(-3 * time.Hour), EndTime: now.Add(-time.Hour)}
+    later := &models.Reservation{RestaurantID: "r1", TableID: "t1", UserID: "u1", StartTime: now.Add(5 * time.Hour), EndTime: now.Add(6 * time.Hour)}
+    soon := &models.Reservation{RestaurantID: "r2", TableID: "t2", UserID: "u1", StartTime: now.Add(time.Hour), EndTime: now.Add(2 * time.Hour)}
+    cancelled := &models.Reservation{RestaurantID: "r1", TableID: "t1", UserID: "u1", StartTime: now.Add(3 * time.Hour), EndTime: now.Add(4 * time.Hour)}
+    other := &models.Reservation{RestaurantID: "r1", TableID: "t3", UserID: "u2", StartTime: now.Add(time.Hour), EndTime: now.Add(2 * time.Hour)}
+    for _, r := range []*models.Reservation{past, later, soon, cancelled, other} {
+        _ = s.Create(r)
+    }
+    _ = s.Cancel(cancelled.ID)
+
+    list, err := s.UpcomingByUser("u1", now)
+    if err != nil {
+        t.Fatalf("upcoming: %v", err)
+    }
+    if len(list) != 2 || list[0].ID != soon.ID || list[1].ID != later.ID {
+        t.Fatalf("expected soon then later across restaurants, got %d reservations", len(list))
+    }
+}
+
+func TestUpcomingByGuest(t *testing.T) {
+    s := NewReservationStore()
+    now := time.Now()
+    byEmail := &models.Reservation{RestaurantID: "r1", TableID: "t1", GuestEmail: "a@example.com", StartTime: now.Add(time.Hour), EndTime: now.Add(2 * time.Hour)}
+    byPhone := &models.Reservation{RestaurantID: "r2", TableID: "t2", GuestPhone: "+8613800000000", StartTime: now.Add(3 * time.Hour), EndTime: now.Add(4 * time.Hour)}
+    account := &models.Reservation{RestaurantID: "r1", TableID: "t3", UserID: "u1", GuestEmail: "a@example.com", StartTime: now.Add(time.Hour), EndTime: now.Add(2 * time.Hour)}
+    for _, r := range []*models.Reservation{byEmail, byPhone, account} {
+        _ = s.Create(r)
+    }
+
+    list, err := s.UpcomingByGuest("a@example.com", "+8613800000000", now)
+    if err != nil {
+        t.Fatalf("upcoming: %v", err)
+    }
+    if len(list) != 2 || list[0].ID != byEmail.ID || list[1].ID != byPhone.ID {
+        t.Fatalf("expected the guest bookings by email and phone, got %d reservations", len(list))
+    }
+    if list, _ := s.UpcomingByGuest("", "", now); len(list) != 0 {
+        t.Fatalf("expected empty contacts to match nothing, got %d reservations", len(list))
+    }
+}
+
+func TestDeleteByRestaurant(t *testing.T) {
+    s := NewReservationStore()
+    base := time.Now().Truncate(time.Hour)
+    keep := &models.Reservation{RestaurantID: "r2", TableID: "t2", UserID: "u1", StartTime: base.Add(time.Hour), EndTime: base.Add(2 * time.Hour)}
+    _ = s.Create(&models.Reservation{RestaurantID: "r1", TableID: "t1", UserID: "u1", StartTime: base.Add(time.Hour), EndTime: base.Add(2 * time.Hour)})
+    _ = s.Create(&models.Reservation{RestaurantID: "r1", TableIDs: []string{"t1", "t3"}, TableID: "t1", UserID: "u2", StartTime: base.Add(3 * time.Hour), EndTime: base.Add(4 * time.Hour)})
+    _ = s.Create(keep)
+
+    if n, err := s.DeleteByRestaurant("r1"); err != nil || n != 2 {
+        t.Fatalf("expected 2 reservations removed, got %d (%v)", n, err)
+    }
+    // the user and table indexes forget them too
+    if list, _ := s.ListByUser("u1"); len(list) != 1 || list[0].ID != keep.ID {
+        t.Fatalf("expected only the other restaurant's booking to remain, got %v", list)
+    }
+    if list, _ := s.ListOverlap(store.ReservationFilter{TableID: "t3", StartBefore: base, EndAfter: base.Add(5 * time.Hour)}); len(list) != 0 {
+        t.Fatalf("expected no bookings left on t3, got %d", len(list))
+    }
+}
+
+func TestCountByStatusAndTableMinutes(t *testing.T) {
+    s := NewReservationStore()
+    base := time.Now().Truncate(time.Hour).Add(24 * time.Hour)
+    lapsed := time.Now().Add(-time.Minute)
+    _ = s.Create(&models.Reservation{RestaurantID: "r1", TableID: "t1", Guests: 2, Status: models.StatusConfirmed, StartTime: base, EndTime: base.Add(2 * time.Hour)})
+    _ = s.Create(&models.Reservation{RestaurantID: "r1", TableIDs: []string{"t2", "t3"}, TableID: "t2", Guests: 6, Status: models.StatusConfirmed, StartTime: base.Add(time.Hour), EndTime: base.Add(3 * time.Hour)})
+    _ = s.Create(&models.Reservation{RestaurantID: "r1", TableID: "t1", Guests: 4, Status: models.StatusCancelled, StartTime: base.Add(3 * time.Hour), EndTime: base.Add(4 * time.Hour)})
+    _ = s.Create(&models.Reservation{RestaurantID: "r1", TableID: "t4", Guests: 2, Status: models.StatusHeld, HoldExpiresAt: &lapsed, StartTime: base, EndTime: base.Add(time.Hour)})
+    _ = s.Create(&models.Reservation{RestaurantID: "r2", TableID: "t9", Guests: 2, Status: models.StatusConfirmed, StartTime: base, EndTime: base.Add(time.Hour)})
+
+    counts, err := s.CountByStatus("r1", base, base.Add(24*time.Hour))
+    if err != nil || len(counts) != 2 {
+        t.Fatalf("expected two statuses without the lapsed hold, got %v (%v)", counts, err)
+    }
+    if c := counts[1]; c.Status != models.StatusConfirmed || c.Parties != 2 || c.Guests != 8 {
+        t.Fatalf("unexpected confirmed count %+v", c)
+    }
+    // the combination counts for both tables; the window cuts the first booking in half
+    if n, _ := s.TableMinutes("r1", base.Add(time.Hour), base.Add(4*time.Hour)); n != 60+2*120 {
+        t.Fatalf("expected 300 booked table minutes, got %d", n)
+    }
+}
+
+func TestReportAggregates(t *testing.T) {
+    s := NewReservationStore()
+    now := time.Now()
+    base := now.Truncate(time.Hour).Add(48*time.Hour + 30*time.Minute)
+    _ = s.Create(&models.Reservation{RestaurantID: "r1", TableID: "t1", Guests: 2, Status: models.StatusConfirmed, StartTime: base, EndTime: base.Add(90 * time.Minute)})
+    _ = s.Create(&models.Reservation{RestaurantID: "r1", TableIDs: []string{"t1", "t2"}, TableID: "t1", CombinationID: "c1", Guests: 7, Status: models.StatusConfirmed, StartTime: base.Add(2 * time.Hour), EndTime: base.Add(3 * time.Hour)})
+    _ = s.Create(&models.Reservation{RestaurantID: "r1", TableID: "t1", Guests: 3, Status: models.StatusCancelled, StartTime: base, EndTime: base.Add(time.Hour)})
+    // recorded after it started, e.g. a walk-in
+    _ = s.Create(&models.Reservation{RestaurantID: "r1", TableID: "t2", Guests: 2, Status: models.StatusSeated, StartTime: now.Add(-time.Hour), EndTime: now.Add(time.Hour)})
+
+    hours, _ := s.HourlyTableMinutes("r1", base.Add(-time.Hour), base.Add(4*time.Hour), time.UTC)
+    // the combination's half hours count for both of its tables
+    want := []int{30, 60, 60, 60}
+    if len(hours) != len(want) || !hours[0].Hour.Equal(base.Truncate(time.Hour)) {
+        t.Fatalf("expected four booked hours from %v, got %v", base.Truncate(time.Hour), hours)
+    }
+    for i, c := range hours {
+        if c.TableMinutes != want[i] {
+            t.Fatalf("hour %d: expected %d table minutes, got %d", i, want[i], c.TableMinutes)
+        }
+    }
+    // half an hour off UTC, the local hours start on the bookings' half hours
+    if loc, err := time.LoadLocation("Asia/Kolkata"); err == nil {
+        hours, _ = s.HourlyTableMinutes("r1", base.Add(-time.Hour), base.Add(4*time.Hour), loc)
+        want = []int{60, 30, 120}
+        if len(hours) != len(want) || !hours[0].Hour.Equal(base) {
+            t.Fatalf("expected three booked local hours from %v, got %v", base, hours)
+        }
+        for i, c := range hours {
+            if c.TableMinutes != want[i] {
+                t.Fatalf("local hour %d: expected %d table minutes, got %d", i, want[i], c.TableMinutes)
+            }
+        }
+    }
+    seatings, _ := s.CountBySeating("r1", base, base.Add(24*time.Hour))
+    if len(seatings) != 2 || seatings[1].CombinationID != "c1" || seatings[1].Guests != 7 {
+        t.Fatalf("expected the table and the combination apart, got %v", seatings)
+    }
+    lead := int(base.Sub(now)/time.Minute)*2 + 120
+    if lt, _ := s.LeadTime("r1", now.Add(-2*time.Hour), base.Add(24*time.Hour)); lt.Parties != 3 || lt.Minutes > lead || lt.Minutes < lead-2 {
+        t.Fatalf("expected %d lead minutes over 3 parties, got %+v", lead, lt)
+    }
+}
@@ -25,6 +25,9 @@ func (s *RestaurantStore) Create(r *models.Restaurant) error {
         r.ID = newID()
     }
     r.CreatedAt = time.Now()
+    if r.TimeZone == "" {
+        r.TimeZone = models.DefaultTimeZone
+    }
     s.byID[r.ID] = r
     return nil
 }
@@ -50,6 +53,17 @@ func (s *RestaurantStore) ByID(id string) (*models.Restaurant, error) {
     return r, nil
 }
 
+func (s *RestaurantStore) Update(r *models.Restaurant) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if s.byID[r.ID] == nil {
+        return errors.New("not found")
+    }
+    cp := *r
+    s.byID[r.ID] = &cp
+    return nil
+}
+
 func (s *RestaurantStore) Delete(id string) error {
     s.mu.Lock()
     defer s.mu.Unlock()
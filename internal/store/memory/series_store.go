@@ -0,0 +1,63 @@
+package memory
+
+import (
+    "errors"
+    "sync"
+    "time"
+
+    "orderation/internal/models"
+)
+
+type SeriesStore struct {
+    mu   sync.RWMutex
+    byID map[string]*models.ReservationSeries
+}
+
+func NewSeriesStore() *SeriesStore {
+    return &SeriesStore{byID: map[string]*models.ReservationSeries{}}
+}
+
+func (s *SeriesStore) Create(sr *models.ReservationSeries) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if sr.ID == "" {
+        sr.ID = newID()
+    }
+    sr.CreatedAt = time.Now()
+    if sr.Status == "" {
+        sr.Status = models.SeriesActive
+    }
+    s.byID[sr.ID] = sr
+    return nil
+}
+
+func (s *SeriesStore) ByID(id string) (*models.ReservationSeries, error) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    sr := s.byID[id]
+    if sr == nil {
+        return nil, errors.New("not found")
+    }
+    return sr, nil
+}
+
+func (s *SeriesStore) Update(sr *models.ReservationSeries) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if s.byID[sr.ID] == nil {
+        return errors.New("not found")
+    }
+    cp := *sr
+    s.byID[sr.ID] = &cp
+    return nil
+}
+
+func (s *SeriesStore) Delete(id string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if s.byID[id] == nil {
+        return errors.New("not found")
+    }
+    delete(s.byID, id)
+    return nil
+}
@@ -0,0 +1,63 @@
+package memory
+
+import (
+    "errors"
+    "sort"
+    "sync"
+    "time"
+
+    "orderation/internal/models"
+)
+
+type TableBlockStore struct {
+    mu   sync.RWMutex
+    byID map[string]*models.TableBlock
+}
+
+func NewTableBlockStore() *TableBlockStore {
+    return &TableBlockStore{byID: map[string]*models.TableBlock{}}
+}
+
+func (s *TableBlockStore) Create(b *models.TableBlock) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if b.ID == "" {
+        b.ID = newID()
+    }
+    b.CreatedAt = time.Now()
+    s.byID[b.ID] = b
+    return nil
+}
+
+func (s *TableBlockStore) ByID(id string) (*models.TableBlock, error) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    b := s.byID[id]
+    if b == nil {
+        return nil, errors.New("not found")
+    }
+    return b, nil
+}
+
+func (s *TableBlockStore) Delete(id string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if s.byID[id] == nil {
+        return errors.New("not found")
+    }
+    delete(s.byID, id)
+    return nil
+}
+
+func (s *TableBlockStore) ListByRestaurant(restaurantID string, from, to time.Time) ([]*models.TableBlock, error) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    out := []*models.TableBlock{}
+    for _, b := range s.byID {
+        if b.RestaurantID == restaurantID && b.Start.Before(to) && b.End.After(from) {
+            out = append(out, b)
+        }
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].Start.Before(out[j].Start) })
+    return out, nil
+}
@@ -32,12 +32,20 @@ func (s *TableStore) Create(t *models.Table) error {
 }
 
 func (s *TableStore) ListByRestaurant(restaurantID string) ([]*models.Table, error) {
+    return s.list(restaurantID, false)
+}
+
+func (s *TableStore) ListAllByRestaurant(restaurantID string) ([]*models.Table, error) {
+    return s.list(restaurantID, true)
+}
+
+func (s *TableStore) list(restaurantID string, deleted bool) ([]*models.Table, error) {
     s.mu.RLock()
     defer s.mu.RUnlock()
     ids := s.byRestaurant[restaurantID]
     out := make([]*models.Table, 0, len(ids))
     for _, id := range ids {
-        if t := s.byID[id]; t != nil {
+        if t := s.byID[id]; t != nil && (deleted || t.DeletedAt == nil) {
             out = append(out, t)
         }
     }
@@ -55,3 +63,38 @@ func (s *TableStore) ByID(id string) (*models.Table, error) {
     return t, nil
 }
 
+
+func (s *TableStore) Update(t *models.Table) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if s.byID[t.ID] == nil {
+        return errors.New("not found")
+    }
+    cp := *t
+    s.byID[t.ID] = &cp
+    return nil
+}
+
+func (s *TableStore) Delete(id string, at time.Time) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    t := s.byID[id]
+    if t == nil || t.DeletedAt != nil {
+        return errors.New("not found")
+    }
+    cp := *t
+    cp.DeletedAt = &at
+    s.byID[id] = &cp
+    return nil
+}
+
+func (s *TableStore) DeleteByRestaurant(restaurantID string) (int, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    ids := s.byRestaurant[restaurantID]
+    for _, id := range ids {
+        delete(s.byID, id)
+    }
+    delete(s.byRestaurant, restaurantID)
+    return len(ids), nil
+}
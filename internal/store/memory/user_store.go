@@ -45,6 +45,17 @@ func (s *UserStore) ByEmail(email string) (*models.User, error) {
     return s.byID[id], nil
 }
 
+func (s *UserStore) SetLimitExempt(id string, exempt bool) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    u := s.byID[id]
+    if u == nil {
+        return errors.New("not found")
+    }
+    u.LimitExempt = exempt
+    return nil
+}
+
 func (s *UserStore) ByID(id string) (*models.User, error) {
     s.mu.RLock()
     defer s.mu.RUnlock()
@@ -29,3 +29,13 @@ func newID() string {
 
 // NewIDForExternal exposes ID generation for other stores.
 func NewIDForExternal() string { return newID() }
+
+// removeID returns ids without id, reusing the backing array.
+func removeID(ids []string, id string) []string {
+    for i, v := range ids {
+        if v == id {
+            return append(ids[:i], ids[i+1:]...)
+        }
+    }
+    return ids
+}
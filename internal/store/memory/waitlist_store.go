@@ -0,0 +1,100 @@
+package memory
+
+import (
+    "errors"
+    "sort"
+    "sync"
+    "time"
+
+    "orderation/internal/models"
+)
+
+type WaitlistStore struct {
+    mu   sync.RWMutex
+    byID map[string]*models.WaitlistEntry
+}
+
+func NewWaitlistStore() *WaitlistStore {
+    return &WaitlistStore{byID: map[string]*models.WaitlistEntry{}}
+}
+
+func (s *WaitlistStore) Create(e *models.WaitlistEntry) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if e.ID == "" {
+        e.ID = newID()
+    }
+    e.CreatedAt = time.Now()
+    if e.Status == "" {
+        e.Status = models.WaitlistWaiting
+    }
+    s.byID[e.ID] = e
+    return nil
+}
+
+func (s *WaitlistStore) ByID(id string) (*models.WaitlistEntry, error) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    e := s.byID[id]
+    if e == nil {
+        return nil, errors.New("not found")
+    }
+    return e, nil
+}
+
+func (s *WaitlistStore) Update(e *models.WaitlistEntry) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if s.byID[e.ID] == nil {
+        return errors.New("not found")
+    }
+    cp := *e
+    s.byID[e.ID] = &cp
+    return nil
+}
+
+func (s *WaitlistStore) DeleteByRestaurant(restaurantID string) (int, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    n := 0
+    for id, e := range s.byID {
+        if e.RestaurantID == restaurantID {
+            delete(s.byID, id)
+            n++
+        }
+    }
+    return n, nil
+}
+
+func (s *WaitlistStore) ListByRestaurant(restaurantID, date string) ([]*models.WaitlistEntry, error) {
+    return s.list(func(e *models.WaitlistEntry) bool {
+        return e.RestaurantID == restaurantID && (date == "" || e.Date == date)
+    })
+}
+
+func (s *WaitlistStore) ListByUser(userID string) ([]*models.WaitlistEntry, error) {
+    return s.list(func(e *models.WaitlistEntry) bool { return e.UserID == userID })
+}
+
+func (s *WaitlistStore) ListExpiredOffers(now time.Time) ([]*models.WaitlistEntry, error) {
+    return s.list(func(e *models.WaitlistEntry) bool { return e.OfferExpired(now) })
+}
+
+// list returns the entries matching keep, first come first served.
+func (s *WaitlistStore) list(keep func(*models.WaitlistEntry) bool) ([]*models.WaitlistEntry, error) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    out := []*models.WaitlistEntry{}
+    for _, e := range s.byID {
+        if keep(e) {
+            out = append(out, e)
+        }
+    }
+    sort.Slice(out, func(i, j int) bool {
+        if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+            return out[i].CreatedAt.Before(out[j].CreatedAt)
+        }
+        return out[i].ID < out[j].ID
+    })
+    return out, nil
+}
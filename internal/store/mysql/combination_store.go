@@ -0,0 +1,78 @@
+package mysql
+
+import (
+    "database/sql"
+    "errors"
+    "strings"
+    "time"
+
+    "orderation/internal/models"
+    mem "orderation/internal/store/memory"
+)
+
+type TableCombinationStore struct { db *sql.DB }
+
+func NewTableCombinationStore(db *sql.DB) *TableCombinationStore { return &TableCombinationStore{db: db} }
+
+const combinationSelect = `SELECT c.id,c.restaurant_id,c.name,c.capacity,c.created_at,
+    (SELECT GROUP_CONCAT(m.table_id ORDER BY m.table_id) FROM table_combination_members m WHERE m.combination_id = c.id)
+    FROM table_combinations c`
+
+func scanCombination(row scanner) (*models.TableCombination, error) {
+    var c models.TableCombination
+    var members sql.NullString
+    if err := row.Scan(&c.ID,&c.RestaurantID,&c.Name,&c.Capacity,&c.CreatedAt,&members); err != nil { return nil, err }
+    c.TableIDs = splitIDs(members)
+    return &c, nil
+}
+
+// splitIDs turns a GROUP_CONCAT result into a slice of IDs.
+func splitIDs(s sql.NullString) []string {
+    if !s.Valid || s.String == "" { return []string{} }
+    return strings.Split(s.String, ",")
+}
+
+func (s *TableCombinationStore) Create(c *models.TableCombination) error {
+    if c.ID == "" { c.ID = mem.NewIDForExternal() }
+    if c.CreatedAt.IsZero() { c.CreatedAt = time.Now() }
+    tx, err := s.db.Begin()
+    if err != nil { return err }
+    defer tx.Rollback()
+    if _, err := tx.Exec(`INSERT INTO table_combinations (id,restaurant_id,name,capacity,created_at) VALUES (?,?,?,?,?)`,
+        c.ID, c.RestaurantID, c.Name, c.Capacity, c.CreatedAt); err != nil { return err }
+    for _, tid := range c.TableIDs {
+        if _, err := tx.Exec(`INSERT INTO table_combination_members (combination_id,table_id) VALUES (?,?)`, c.ID, tid); err != nil { return err }
+    }
+    return tx.Commit()
+}
+
+func (s *TableCombinationStore) ListByRestaurant(restaurantID string) ([]*models.TableCombination, error) {
+    rows, err := s.db.Query(combinationSelect+` WHERE c.restaurant_id=? ORDER BY c.capacity ASC, c.created_at ASC`, restaurantID)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    out := []*models.TableCombination{}
+    for rows.Next() {
+        c, err := scanCombination(rows)
+        if err != nil { return nil, err }
+        out = append(out, c)
+    }
+    return out, rows.Err()
+}
+
+func (s *TableCombinationStore) ByID(id string) (*models.TableCombination, error) {
+    c, err := scanCombination(s.db.QueryRow(combinationSelect+` WHERE c.id=?`, id))
+    if err != nil {
+        if errors.Is(err, sql.ErrNoRows) { return nil, errors.New("not found") }
+        return nil, err
+    }
+    return c, nil
+}
+
+func (s *TableCombinationStore) Delete(id string) error {
+    result, err := s.db.Exec(`DELETE FROM table_combinations WHERE id=?`, id)
+    if err != nil { return err }
+    n, err := result.RowsAffected()
+    if err != nil { return err }
+    if n == 0 { return errors.New("not found") }
+    return nil
+}
@@ -0,0 +1,59 @@
+package mysql
+
+import (
+    "database/sql"
+    "errors"
+    "time"
+
+    "orderation/internal/models"
+    mem "orderation/internal/store/memory"
+)
+
+type ExceptionStore struct { db *sql.DB }
+
+func NewExceptionStore(db *sql.DB) *ExceptionStore { return &ExceptionStore{db: db} }
+
+func (s *ExceptionStore) Create(e *models.HoursException) error {
+    if e.ID == "" { e.ID = mem.NewIDForExternal() }
+    if e.CreatedAt.IsZero() { e.CreatedAt = time.Now() }
+    _, err := s.db.Exec(`INSERT INTO hours_exceptions (id,restaurant_id,date,closed,open_time,close_time,note,created_at) VALUES (?,?,?,?,?,?,?,?)`,
+        e.ID, e.RestaurantID, e.Date, e.Closed, e.OpenTime, e.CloseTime, e.Note, e.CreatedAt)
+    return err
+}
+
+func (s *ExceptionStore) ListByRestaurant(restaurantID, from, to string) ([]*models.HoursException, error) {
+    q := `SELECT id,restaurant_id,date,closed,open_time,close_time,note,created_at FROM hours_exceptions WHERE restaurant_id=?`
+    args := []any{restaurantID}
+    if from != "" { q += " AND date >= ?"; args = append(args, from) }
+    if to != "" { q += " AND date <= ?"; args = append(args, to) }
+    q += " ORDER BY date ASC"
+    rows, err := s.db.Query(q, args...)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    out := []*models.HoursException{}
+    for rows.Next() {
+        var e models.HoursException
+        if err := rows.Scan(&e.ID,&e.RestaurantID,&e.Date,&e.Closed,&e.OpenTime,&e.CloseTime,&e.Note,&e.CreatedAt); err != nil { return nil, err }
+        out = append(out, &e)
+    }
+    return out, rows.Err()
+}
+
+func (s *ExceptionStore) ByID(id string) (*models.HoursException, error) {
+    row := s.db.QueryRow(`SELECT id,restaurant_id,date,closed,open_time,close_time,note,created_at FROM hours_exceptions WHERE id=?`, id)
+    var e models.HoursException
+    if err := row.Scan(&e.ID,&e.RestaurantID,&e.Date,&e.Closed,&e.OpenTime,&e.CloseTime,&e.Note,&e.CreatedAt); err != nil {
+        if errors.Is(err, sql.ErrNoRows) { return nil, errors.New("not found") }
+        return nil, err
+    }
+    return &e, nil
+}
+
+func (s *ExceptionStore) Delete(id string) error {
+    result, err := s.db.Exec(`DELETE FROM hours_exceptions WHERE id=?`, id)
+    if err != nil { return err }
+    n, err := result.RowsAffected()
+    if err != nil { return err }
+    if n == 0 { return errors.New("not found") }
+    return nil
+}
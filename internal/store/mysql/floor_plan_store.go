@@ -0,0 +1,132 @@
+package mysql
+
+import (
+    "database/sql"
+    "errors"
+    "time"
+
+    "orderation/internal/models"
+    mem "orderation/internal/store/memory"
+)
+
+type FloorPlanStore struct { db *sql.DB }
+
+func NewFloorPlanStore(db *sql.DB) *FloorPlanStore { return &FloorPlanStore{db: db} }
+
+const (
+    floorSectionColumns  = `id,restaurant_id,name,x,y,width,height,created_at`
+    tablePositionColumns = `table_id,restaurant_id,x,y,width,height,shape,rotation,updated_at`
+)
+
+func (s *FloorPlanStore) Plan(restaurantID string) (*models.FloorPlan, error) {
+    var p models.FloorPlan
+    err := s.db.QueryRow(`SELECT restaurant_id,width,height,updated_at FROM floor_plans WHERE restaurant_id=?`, restaurantID).Scan(&p.RestaurantID, &p.Width, &p.Height, &p.UpdatedAt)
+    if err != nil {
+        if errors.Is(err, sql.ErrNoRows) { return nil, errors.New("not found") }
+        return nil, err
+    }
+    return &p, nil
+}
+
+func (s *FloorPlanStore) SavePlan(p *models.FloorPlan) error {
+    p.UpdatedAt = time.Now()
+    _, err := s.db.Exec(`INSERT INTO floor_plans (restaurant_id,width,height,updated_at) VALUES (?,?,?,?)
+        ON DUPLICATE KEY UPDATE width=VALUES(width), height=VALUES(height), updated_at=VALUES(updated_at)`,
+        p.RestaurantID, p.Width, p.Height, p.UpdatedAt)
+    return err
+}
+
+func scanFloorSection(row scanner) (*models.FloorSection, error) {
+    var sec models.FloorSection
+    if err := row.Scan(&sec.ID,&sec.RestaurantID,&sec.Name,&sec.X,&sec.Y,&sec.Width,&sec.Height,&sec.CreatedAt); err != nil { return nil, err }
+    return &sec, nil
+}
+
+func (s *FloorPlanStore) CreateSection(sec *models.FloorSection) error {
+    if sec.ID == "" { sec.ID = mem.NewIDForExternal() }
+    if sec.CreatedAt.IsZero() { sec.CreatedAt = time.Now() }
+    _, err := s.db.Exec(`INSERT INTO floor_sections (`+floorSectionColumns+`) VALUES (?,?,?,?,?,?,?,?)`,
+        sec.ID, sec.RestaurantID, sec.Name, sec.X, sec.Y, sec.Width, sec.Height, sec.CreatedAt)
+    return err
+}
+
+func (s *FloorPlanStore) SectionByID(id string) (*models.FloorSection, error) {
+    sec, err := scanFloorSection(s.db.QueryRow(`SELECT `+floorSectionColumns+` FROM floor_sections WHERE id=?`, id))
+    if err != nil {
+        if errors.Is(err, sql.ErrNoRows) { return nil, errors.New("not found") }
+        return nil, err
+    }
+    return sec, nil
+}
+
+func (s *FloorPlanStore) UpdateSection(sec *models.FloorSection) error {
+    result, err := s.db.Exec(`UPDATE floor_sections SET name=?, x=?, y=?, width=?, height=? WHERE id=?`,
+        sec.Name, sec.X, sec.Y, sec.Width, sec.Height, sec.ID)
+    if err != nil { return err }
+    if n, err := result.RowsAffected(); err != nil || n > 0 { return err }
+    _, err = s.SectionByID(sec.ID)
+    return err
+}
+
+func (s *FloorPlanStore) DeleteSection(id string) error {
+    result, err := s.db.Exec(`DELETE FROM floor_sections WHERE id=?`, id)
+    if err != nil { return err }
+    n, err := result.RowsAffected()
+    if err != nil { return err }
+    if n == 0 { return errors.New("not found") }
+    return nil
+}
+
+func (s *FloorPlanStore) ListSections(restaurantID string) ([]*models.FloorSection, error) {
+    rows, err := s.db.Query(`SELECT `+floorSectionColumns+` FROM floor_sections WHERE restaurant_id=? ORDER BY name ASC`, restaurantID)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    out := []*models.FloorSection{}
+    for rows.Next() {
+        sec, err := scanFloorSection(rows)
+        if err != nil { return nil, err }
+        out = append(out, sec)
+    }
+    return out, rows.Err()
+}
+
+func (s *FloorPlanStore) SetPosition(p *models.TablePosition) error {
+    p.UpdatedAt = time.Now()
+    _, err := s.db.Exec(`INSERT INTO table_positions (`+tablePositionColumns+`) VALUES (?,?,?,?,?,?,?,?,?)
+        ON DUPLICATE KEY UPDATE x=VALUES(x), y=VALUES(y), width=VALUES(width), height=VALUES(height), shape=VALUES(shape), rotation=VALUES(rotation), updated_at=VALUES(updated_at)`,
+        p.TableID, p.RestaurantID, p.X, p.Y, p.Width, p.Height, p.Shape, p.Rotation, p.UpdatedAt)
+    return err
+}
+
+func (s *FloorPlanStore) DeletePosition(tableID string) error {
+    result, err := s.db.Exec(`DELETE FROM table_positions WHERE table_id=?`, tableID)
+    if err != nil { return err }
+    n, err := result.RowsAffected()
+    if err != nil { return err }
+    if n == 0 { return errors.New("not found") }
+    return nil
+}
+
+func (s *FloorPlanStore) ListPositions(restaurantID string) ([]*models.TablePosition, error) {
+    rows, err := s.db.Query(`SELECT `+tablePositionColumns+` FROM table_positions WHERE restaurant_id=? ORDER BY table_id ASC`, restaurantID)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    out := []*models.TablePosition{}
+    for rows.Next() {
+        var p models.TablePosition
+        if err := rows.Scan(&p.TableID,&p.RestaurantID,&p.X,&p.Y,&p.Width,&p.Height,&p.Shape,&p.Rotation,&p.UpdatedAt); err != nil { return nil, err }
+        out = append(out, &p)
+    }
+    return out, rows.Err()
+}
+
+func (s *FloorPlanStore) DeleteByRestaurant(restaurantID string) error {
+    for _, q := range []string{
+        `DELETE FROM table_positions WHERE restaurant_id=?`,
+        `DELETE FROM floor_sections WHERE restaurant_id=?`,
+        `DELETE FROM floor_plans WHERE restaurant_id=?`,
+    } {
+        if _, err := s.db.Exec(q, restaurantID); err != nil { return err }
+    }
+    return nil
+}
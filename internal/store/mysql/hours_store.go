@@ -0,0 +1,96 @@
+package mysql
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "time"
+
+    "orderation/internal/models"
+    mem "orderation/internal/store/memory"
+)
+
+type HoursStore struct { db *sql.DB }
+
+func NewHoursStore(db *sql.DB) *HoursStore { return &HoursStore{db: db} }
+
+func (s *HoursStore) Create(p *models.ServicePeriod) error {
+    if p.ID == "" { p.ID = mem.NewIDForExternal() }
+    if p.CreatedAt.IsZero() { p.CreatedAt = time.Now() }
+    _, err := s.db.Exec(`INSERT INTO service_periods (id,restaurant_id,weekday,name,open_time,close_time,created_at) VALUES (?,?,?,?,?,?,?)`,
+        p.ID, p.RestaurantID, int(p.Weekday), p.Name, p.OpenTime, p.CloseTime, p.CreatedAt)
+    return err
+}
+
+func (s *HoursStore) ListByRestaurant(restaurantID string) ([]*models.ServicePeriod, error) {
+    rows, err := s.db.Query(`SELECT id,restaurant_id,weekday,name,open_time,close_time,created_at FROM service_periods WHERE restaurant_id=? ORDER BY weekday ASC, open_time ASC`, restaurantID)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    out := []*models.ServicePeriod{}
+    for rows.Next() {
+        var p models.ServicePeriod
+        var wd int
+        if err := rows.Scan(&p.ID,&p.RestaurantID,&wd,&p.Name,&p.OpenTime,&p.CloseTime,&p.CreatedAt); err != nil { return nil, err }
+        p.Weekday = time.Weekday(wd)
+        out = append(out, &p)
+    }
+    return out, rows.Err()
+}
+
+func (s *HoursStore) ByID(id string) (*models.ServicePeriod, error) {
+    row := s.db.QueryRow(`SELECT id,restaurant_id,weekday,name,open_time,close_time,created_at FROM service_periods WHERE id=?`, id)
+    var p models.ServicePeriod
+    var wd int
+    if err := row.Scan(&p.ID,&p.RestaurantID,&wd,&p.Name,&p.OpenTime,&p.CloseTime,&p.CreatedAt); err != nil {
+        if errors.Is(err, sql.ErrNoRows) { return nil, errors.New("not found") }
+        return nil, err
+    }
+    p.Weekday = time.Weekday(wd)
+    return &p, nil
+}
+
+func (s *HoursStore) Update(p *models.ServicePeriod) error {
+    result, err := s.db.Exec(`UPDATE service_periods SET weekday=?, name=?, open_time=?, close_time=? WHERE id=?`, int(p.Weekday), p.Name, p.OpenTime, p.CloseTime, p.ID)
+    if err != nil { return err }
+    if n, err := result.RowsAffected(); err == nil && n == 0 {
+        // MySQL reports 0 for unchanged rows too, so confirm the row exists.
+        if _, err := s.ByID(p.ID); err != nil { return err }
+    }
+    return nil
+}
+
+func (s *HoursStore) Delete(id string) error {
+    result, err := s.db.Exec(`DELETE FROM service_periods WHERE id=?`, id)
+    if err != nil { return err }
+    n, err := result.RowsAffected()
+    if err != nil { return err }
+    if n == 0 { return errors.New("not found") }
+    return nil
+}
+
+// migrateServicePeriods seeds every weekday of the weekly schedule from the
+// legacy single open_time/close_time window of restaurants created before
+// service periods existed.
+func migrateServicePeriods(ctx context.Context, tx *sql.Tx) error {
+    rows, err := tx.QueryContext(ctx, `SELECT r.id, r.open_time, r.close_time FROM restaurants r
+        WHERE r.open_time <> '' AND r.close_time <> ''
+        AND NOT EXISTS (SELECT 1 FROM service_periods p WHERE p.restaurant_id = r.id)`)
+    if err != nil { return err }
+    type legacy struct{ id, open, close string }
+    var list []legacy
+    for rows.Next() {
+        var l legacy
+        if err := rows.Scan(&l.id, &l.open, &l.close); err != nil { rows.Close(); return err }
+        list = append(list, l)
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil { return err }
+    now := time.Now()
+    for _, l := range list {
+        for wd := time.Sunday; wd <= time.Saturday; wd++ {
+            if _, err := tx.ExecContext(ctx, `INSERT INTO service_periods (id,restaurant_id,weekday,name,open_time,close_time,created_at) VALUES (?,?,?,?,?,?,?)`,
+                mem.NewIDForExternal(), l.id, int(wd), "", l.open, l.close, now); err != nil { return err }
+        }
+    }
+    return nil
+}
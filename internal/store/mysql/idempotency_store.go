@@ -0,0 +1,52 @@
+package mysql
+
+import (
+    "database/sql"
+    "errors"
+    "time"
+
+    "orderation/internal/models"
+)
+
+type IdempotencyStore struct { db *sql.DB }
+
+func NewIdempotencyStore(db *sql.DB) *IdempotencyStore { return &IdempotencyStore{db: db} }
+
+const idempotencyColumns = `idempotency_key,request_hash,completed,status_code,content_type,body,created_at,expires_at`
+
+func (s *IdempotencyStore) Begin(rec *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+    now := time.Now()
+    if rec.CreatedAt.IsZero() { rec.CreatedAt = now }
+    // Drop an expired record for the key first so the insert below can claim it.
+    if _, err := s.db.Exec(`DELETE FROM idempotency_keys WHERE idempotency_key=? AND expires_at<=?`, rec.Key, now); err != nil { return nil, err }
+    result, err := s.db.Exec(`INSERT IGNORE INTO idempotency_keys (`+idempotencyColumns+`) VALUES (?,?,?,?,?,?,?,?)`,
+        rec.Key, rec.RequestHash, false, 0, "", []byte{}, rec.CreatedAt, rec.ExpiresAt)
+    if err != nil { return nil, err }
+    if n, err := result.RowsAffected(); err != nil || n > 0 { return nil, err }
+    var cur models.IdempotencyRecord
+    err = s.db.QueryRow(`SELECT `+idempotencyColumns+` FROM idempotency_keys WHERE idempotency_key=?`, rec.Key).
+        Scan(&cur.Key, &cur.RequestHash, &cur.Completed, &cur.StatusCode, &cur.ContentType, &cur.Body, &cur.CreatedAt, &cur.ExpiresAt)
+    if err != nil {
+        if errors.Is(err, sql.ErrNoRows) { return nil, errors.New("not found") }
+        return nil, err
+    }
+    return &cur, nil
+}
+
+func (s *IdempotencyStore) Complete(rec *models.IdempotencyRecord) error {
+    _, err := s.db.Exec(`UPDATE idempotency_keys SET completed=TRUE, status_code=?, content_type=?, body=? WHERE idempotency_key=?`,
+        rec.StatusCode, rec.ContentType, rec.Body, rec.Key)
+    return err
+}
+
+func (s *IdempotencyStore) Abandon(key string) error {
+    _, err := s.db.Exec(`DELETE FROM idempotency_keys WHERE idempotency_key=?`, key)
+    return err
+}
+
+func (s *IdempotencyStore) DeleteExpired(now time.Time) (int, error) {
+    result, err := s.db.Exec(`DELETE FROM idempotency_keys WHERE expires_at<=?`, now)
+    if err != nil { return 0, err }
+    n, err := result.RowsAffected()
+    return int(n), err
+}
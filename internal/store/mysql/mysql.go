@@ -125,6 +125,7 @@ func EnsureSchema(ctx context.Context, db *sql.DB) error {
             email VARCHAR(255) NOT NULL UNIQUE,
             pass_hash TEXT NOT NULL,
             role VARCHAR(32) NOT NULL,
+            limit_exempt BOOLEAN NOT NULL DEFAULT FALSE,
             created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
         ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
         `CREATE TABLE IF NOT EXISTS restaurants (
@@ -133,28 +134,103 @@ func EnsureSchema(ctx context.Context, db *sql.DB) error {
             address VARCHAR(512) NOT NULL,
             open_time VARCHAR(16) NOT NULL,
             close_time VARCHAR(16) NOT NULL,
-            created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+            time_zone VARCHAR(64) NOT NULL DEFAULT 'Asia/Shanghai',
+            settings TEXT NULL,
+            created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+            archived_at DATETIME NULL
         ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
         `CREATE TABLE IF NOT EXISTS tables (
             id VARCHAR(32) PRIMARY KEY,
             restaurant_id VARCHAR(32) NOT NULL,
             name VARCHAR(255) NOT NULL,
             capacity INT NOT NULL,
+            section VARCHAR(64) NOT NULL DEFAULT '',
+            accessible BOOLEAN NOT NULL DEFAULT FALSE,
+            high_top BOOLEAN NOT NULL DEFAULT FALSE,
+            booth BOOLEAN NOT NULL DEFAULT FALSE,
+            smoking BOOLEAN NOT NULL DEFAULT FALSE,
             created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+            deleted_at DATETIME NULL,
             INDEX idx_tables_restaurant (restaurant_id),
             CONSTRAINT fk_tables_restaurant FOREIGN KEY (restaurant_id) REFERENCES restaurants(id) ON DELETE CASCADE
         ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
+        `CREATE TABLE IF NOT EXISTS table_blocks (
+            id VARCHAR(32) PRIMARY KEY,
+            restaurant_id VARCHAR(32) NOT NULL,
+            table_id VARCHAR(32) NOT NULL,
+            start_time DATETIME NOT NULL,
+            end_time DATETIME NOT NULL,
+            reason VARCHAR(255) NOT NULL DEFAULT '',
+            created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+            INDEX idx_table_blocks_time (restaurant_id, start_time, end_time),
+            CONSTRAINT fk_table_blocks_table FOREIGN KEY (table_id) REFERENCES tables(id) ON DELETE CASCADE
+        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
+        `CREATE TABLE IF NOT EXISTS floor_plans (
+            restaurant_id VARCHAR(32) PRIMARY KEY,
+            width INT NOT NULL,
+            height INT NOT NULL,
+            updated_at DATETIME NOT NULL,
+            CONSTRAINT fk_floor_plans_restaurant FOREIGN KEY (restaurant_id) REFERENCES restaurants(id) ON DELETE CASCADE
+        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
+        `CREATE TABLE IF NOT EXISTS floor_sections (
+            id VARCHAR(32) PRIMARY KEY,
+            restaurant_id VARCHAR(32) NOT NULL,
+            name VARCHAR(64) NOT NULL,
+            x INT NOT NULL,
+            y INT NOT NULL,
+            width INT NOT NULL,
+            height INT NOT NULL,
+            created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+            INDEX idx_floor_sections_restaurant (restaurant_id),
+            CONSTRAINT fk_floor_sections_restaurant FOREIGN KEY (restaurant_id) REFERENCES restaurants(id) ON DELETE CASCADE
+        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
+        `CREATE TABLE IF NOT EXISTS table_positions (
+            table_id VARCHAR(32) PRIMARY KEY,
+            restaurant_id VARCHAR(32) NOT NULL,
+            x INT NOT NULL,
+            y INT NOT NULL,
+            width INT NOT NULL,
+            height INT NOT NULL,
+            shape VARCHAR(16) NOT NULL DEFAULT 'rect',
+            rotation INT NOT NULL DEFAULT 0,
+            updated_at DATETIME NOT NULL,
+            INDEX idx_table_positions_restaurant (restaurant_id),
+            CONSTRAINT fk_table_positions_table FOREIGN KEY (table_id) REFERENCES tables(id) ON DELETE CASCADE
+        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
         `CREATE TABLE IF NOT EXISTS reservations (
             id VARCHAR(32) PRIMARY KEY,
             restaurant_id VARCHAR(32) NOT NULL,
             table_id VARCHAR(32) NOT NULL,
-            user_id VARCHAR(32) NOT NULL,
+            combination_id VARCHAR(32) NOT NULL DEFAULT '',
+            series_id VARCHAR(32) NOT NULL DEFAULT '',
+            user_id VARCHAR(32) NULL,
+            guest_name VARCHAR(255) NOT NULL DEFAULT '',
+            guest_phone VARCHAR(64) NOT NULL DEFAULT '',
+            guest_email VARCHAR(255) NOT NULL DEFAULT '',
+            confirmation_code VARCHAR(16) NULL,
+            manage_token_hash CHAR(64) NOT NULL DEFAULT '',
             start_time DATETIME NOT NULL,
             end_time DATETIME NOT NULL,
             guests INT NOT NULL,
+            turnover_minutes INT NOT NULL DEFAULT 0,
             status VARCHAR(32) NOT NULL,
             created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+            confirmed_at DATETIME NULL,
+            seated_at DATETIME NULL,
+            completed_at DATETIME NULL,
+            no_show_at DATETIME NULL,
+            cancelled_at DATETIME NULL,
+            hold_expires_at DATETIME NULL,
+            late_cancel BOOLEAN NOT NULL DEFAULT FALSE,
+            cancel_reason VARCHAR(500) NOT NULL DEFAULT '',
+            occasion VARCHAR(32) NOT NULL DEFAULT '',
+            dietary VARCHAR(255) NOT NULL DEFAULT '',
+            accessibility VARCHAR(255) NOT NULL DEFAULT '',
+            notes VARCHAR(500) NOT NULL DEFAULT '',
+            seating VARCHAR(255) NOT NULL DEFAULT '',
             INDEX idx_resv_user (user_id),
+            INDEX idx_resv_series (series_id),
+            UNIQUE KEY uniq_resv_confirmation (confirmation_code),
             INDEX idx_resv_table (table_id),
             INDEX idx_resv_rest (restaurant_id),
             INDEX idx_resv_time (start_time, end_time),
@@ -162,10 +238,201 @@ func EnsureSchema(ctx context.Context, db *sql.DB) error {
             CONSTRAINT fk_resv_table FOREIGN KEY (table_id) REFERENCES tables(id) ON DELETE CASCADE,
             CONSTRAINT fk_resv_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
         ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
+        `CREATE TABLE IF NOT EXISTS reservation_tables (
+            reservation_id VARCHAR(32) NOT NULL,
+            table_id VARCHAR(32) NOT NULL,
+            PRIMARY KEY (reservation_id, table_id),
+            INDEX idx_resv_tables_table (table_id),
+            CONSTRAINT fk_resv_tables_resv FOREIGN KEY (reservation_id) REFERENCES reservations(id) ON DELETE CASCADE,
+            CONSTRAINT fk_resv_tables_table FOREIGN KEY (table_id) REFERENCES tables(id) ON DELETE CASCADE
+        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
+        `CREATE TABLE IF NOT EXISTS table_combinations (
+            id VARCHAR(32) PRIMARY KEY,
+            restaurant_id VARCHAR(32) NOT NULL,
+            name VARCHAR(255) NOT NULL,
+            capacity INT NOT NULL,
+            created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+            INDEX idx_combinations_restaurant (restaurant_id),
+            CONSTRAINT fk_combinations_restaurant FOREIGN KEY (restaurant_id) REFERENCES restaurants(id) ON DELETE CASCADE
+        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
+        `CREATE TABLE IF NOT EXISTS table_combination_members (
+            combination_id VARCHAR(32) NOT NULL,
+            table_id VARCHAR(32) NOT NULL,
+            PRIMARY KEY (combination_id, table_id),
+            CONSTRAINT fk_members_combination FOREIGN KEY (combination_id) REFERENCES table_combinations(id) ON DELETE CASCADE,
+            CONSTRAINT fk_members_table FOREIGN KEY (table_id) REFERENCES tables(id) ON DELETE CASCADE
+        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
+        `CREATE TABLE IF NOT EXISTS service_periods (
+            id VARCHAR(32) PRIMARY KEY,
+            restaurant_id VARCHAR(32) NOT NULL,
+            weekday TINYINT NOT NULL,
+            name VARCHAR(64) NOT NULL DEFAULT '',
+            open_time VARCHAR(16) NOT NULL,
+            close_time VARCHAR(16) NOT NULL,
+            created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+            INDEX idx_periods_restaurant (restaurant_id, weekday),
+            CONSTRAINT fk_periods_restaurant FOREIGN KEY (restaurant_id) REFERENCES restaurants(id) ON DELETE CASCADE
+        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
+        `CREATE TABLE IF NOT EXISTS hours_exceptions (
+            id VARCHAR(32) PRIMARY KEY,
+            restaurant_id VARCHAR(32) NOT NULL,
+            date CHAR(10) NOT NULL,
+            closed BOOLEAN NOT NULL DEFAULT FALSE,
+            open_time VARCHAR(16) NOT NULL DEFAULT '',
+            close_time VARCHAR(16) NOT NULL DEFAULT '',
+            note VARCHAR(255) NOT NULL DEFAULT '',
+            created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+            UNIQUE KEY uniq_exception_date (restaurant_id, date),
+            CONSTRAINT fk_exceptions_restaurant FOREIGN KEY (restaurant_id) REFERENCES restaurants(id) ON DELETE CASCADE
+        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
+        `CREATE TABLE IF NOT EXISTS reservation_series (
+            id VARCHAR(32) PRIMARY KEY,
+            restaurant_id VARCHAR(32) NOT NULL,
+            user_id VARCHAR(32) NOT NULL,
+            rule VARCHAR(255) NOT NULL,
+            first_start DATETIME NOT NULL,
+            duration_minutes INT NOT NULL,
+            guests INT NOT NULL,
+            table_id VARCHAR(32) NOT NULL DEFAULT '',
+            combination_id VARCHAR(32) NOT NULL DEFAULT '',
+            status VARCHAR(32) NOT NULL,
+            created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+            INDEX idx_series_user (user_id),
+            CONSTRAINT fk_series_restaurant FOREIGN KEY (restaurant_id) REFERENCES restaurants(id) ON DELETE CASCADE,
+            CONSTRAINT fk_series_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
+        `CREATE TABLE IF NOT EXISTS waitlist_entries (
+            id VARCHAR(32) PRIMARY KEY,
+            restaurant_id VARCHAR(32) NOT NULL,
+            user_id VARCHAR(32) NOT NULL,
+            date CHAR(10) NOT NULL,
+            window_start DATETIME NOT NULL,
+            window_end DATETIME NOT NULL,
+            guests INT NOT NULL,
+            duration_minutes INT NOT NULL,
+            auto_book BOOLEAN NOT NULL DEFAULT FALSE,
+            status VARCHAR(32) NOT NULL,
+            offer_start DATETIME NULL,
+            offer_expires_at DATETIME NULL,
+            claim_token VARCHAR(64) NOT NULL DEFAULT '',
+            reservation_id VARCHAR(32) NOT NULL DEFAULT '',
+            created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+            INDEX idx_waitlist_restaurant (restaurant_id, date),
+            INDEX idx_waitlist_user (user_id),
+            CONSTRAINT fk_waitlist_restaurant FOREIGN KEY (restaurant_id) REFERENCES restaurants(id) ON DELETE CASCADE,
+            CONSTRAINT fk_waitlist_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
+        `CREATE TABLE IF NOT EXISTS idempotency_keys (
+            idempotency_key VARCHAR(300) PRIMARY KEY,
+            request_hash CHAR(64) NOT NULL,
+            completed BOOLEAN NOT NULL DEFAULT FALSE,
+            status_code INT NOT NULL DEFAULT 0,
+            content_type VARCHAR(128) NOT NULL DEFAULT '',
+            body MEDIUMBLOB NOT NULL,
+            created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+            expires_at DATETIME NOT NULL,
+            INDEX idx_idempotency_expires (expires_at)
+        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
+        `CREATE TABLE IF NOT EXISTS schema_migrations (
+            name VARCHAR(128) PRIMARY KEY,
+            applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;`,
     }
     for _, s := range stmts {
         if _, err := db.ExecContext(ctx, s); err != nil { return err }
     }
+    // Columns added after the initial schema; CREATE TABLE IF NOT EXISTS does
+    // not touch existing tables, so add them to older databases here.
+    columns := []struct{ table, column, ddl string }{
+        {"restaurants", "time_zone", `ALTER TABLE restaurants ADD COLUMN time_zone VARCHAR(64) NOT NULL DEFAULT 'Asia/Shanghai' AFTER close_time`},
+        {"reservations", "confirmed_at", `ALTER TABLE reservations ADD COLUMN confirmed_at DATETIME NULL`},
+        {"reservations", "seated_at", `ALTER TABLE reservations ADD COLUMN seated_at DATETIME NULL`},
+        {"reservations", "completed_at", `ALTER TABLE reservations ADD COLUMN completed_at DATETIME NULL`},
+        {"reservations", "no_show_at", `ALTER TABLE reservations ADD COLUMN no_show_at DATETIME NULL`},
+        {"reservations", "cancelled_at", `ALTER TABLE reservations ADD COLUMN cancelled_at DATETIME NULL`},
+        {"reservations", "combination_id", `ALTER TABLE reservations ADD COLUMN combination_id VARCHAR(32) NOT NULL DEFAULT '' AFTER table_id`},
+        {"restaurants", "settings", `ALTER TABLE restaurants ADD COLUMN settings TEXT NULL AFTER time_zone`},
+        {"restaurants", "archived_at", `ALTER TABLE restaurants ADD COLUMN archived_at DATETIME NULL AFTER created_at`},
+        {"reservations", "hold_expires_at", `ALTER TABLE reservations ADD COLUMN hold_expires_at DATETIME NULL AFTER cancelled_at`},
+        {"reservations", "guest_name", `ALTER TABLE reservations ADD COLUMN guest_name VARCHAR(255) NOT NULL DEFAULT '' AFTER user_id`},
+        {"reservations", "guest_phone", `ALTER TABLE reservations ADD COLUMN guest_phone VARCHAR(64) NOT NULL DEFAULT '' AFTER guest_name`},
+        {"reservations", "guest_email", `ALTER TABLE reservations ADD COLUMN guest_email VARCHAR(255) NOT NULL DEFAULT '' AFTER guest_phone`},
+        {"reservations", "confirmation_code", `ALTER TABLE reservations ADD COLUMN confirmation_code VARCHAR(16) NULL AFTER guest_email, ADD UNIQUE KEY uniq_resv_confirmation (confirmation_code)`},
+        {"reservations", "manage_token_hash", `ALTER TABLE reservations ADD COLUMN manage_token_hash CHAR(64) NOT NULL DEFAULT '' AFTER confirmation_code`},
+        {"reservations", "turnover_minutes", `ALTER TABLE reservations ADD COLUMN turnover_minutes INT NOT NULL DEFAULT 0 AFTER guests`},
+        {"reservations", "late_cancel", `ALTER TABLE reservations ADD COLUMN late_cancel BOOLEAN NOT NULL DEFAULT FALSE AFTER hold_expires_at`},
+        {"reservations", "cancel_reason", `ALTER TABLE reservations ADD COLUMN cancel_reason VARCHAR(500) NOT NULL DEFAULT '' AFTER late_cancel`},
+        {"reservations", "series_id", `ALTER TABLE reservations ADD COLUMN series_id VARCHAR(32) NOT NULL DEFAULT '' AFTER combination_id, ADD INDEX idx_resv_series (series_id)`},
+        {"reservations", "occasion", `ALTER TABLE reservations ADD COLUMN occasion VARCHAR(32) NOT NULL DEFAULT '' AFTER cancel_reason`},
+        {"reservations", "dietary", `ALTER TABLE reservations ADD COLUMN dietary VARCHAR(255) NOT NULL DEFAULT '' AFTER occasion`},
+        {"reservations", "accessibility", `ALTER TABLE reservations ADD COLUMN accessibility VARCHAR(255) NOT NULL DEFAULT '' AFTER dietary`},
+        {"reservations", "notes", `ALTER TABLE reservations ADD COLUMN notes VARCHAR(500) NOT NULL DEFAULT '' AFTER accessibility`},
+        {"reservations", "seating", `ALTER TABLE reservations ADD COLUMN seating VARCHAR(255) NOT NULL DEFAULT '' AFTER notes`},
+        {"tables", "section", `ALTER TABLE tables ADD COLUMN section VARCHAR(64) NOT NULL DEFAULT '' AFTER capacity`},
+        {"tables", "accessible", `ALTER TABLE tables ADD COLUMN accessible BOOLEAN NOT NULL DEFAULT FALSE AFTER section`},
+        {"tables", "high_top", `ALTER TABLE tables ADD COLUMN high_top BOOLEAN NOT NULL DEFAULT FALSE AFTER accessible`},
+        {"tables", "booth", `ALTER TABLE tables ADD COLUMN booth BOOLEAN NOT NULL DEFAULT FALSE AFTER high_top`},
+        {"tables", "smoking", `ALTER TABLE tables ADD COLUMN smoking BOOLEAN NOT NULL DEFAULT FALSE AFTER booth`},
+        {"tables", "deleted_at", `ALTER TABLE tables ADD COLUMN deleted_at DATETIME NULL AFTER created_at`},
+        {"users", "limit_exempt", `ALTER TABLE users ADD COLUMN limit_exempt BOOLEAN NOT NULL DEFAULT FALSE AFTER role`},
+    }
+    for _, c := range columns {
+        if err := ensureColumn(ctx, db, c.table, c.column, c.ddl); err != nil { return err }
+    }
+    // Guest bookings exist without an account.
+    if err := ensureNullable(ctx, db, "reservations", "user_id", `ALTER TABLE reservations MODIFY COLUMN user_id VARCHAR(32) NULL`); err != nil { return err }
+    // One-off data migrations, each recorded in schema_migrations so it runs once.
+    migrations := []struct {
+        name string
+        run  func(context.Context, *sql.Tx) error
+    }{
+        {"seed_service_periods", migrateServicePeriods},
+        {"seed_reservation_tables", migrateReservationTables},
+    }
+    for _, m := range migrations {
+        if err := runMigration(ctx, db, m.name, m.run); err != nil { return fmt.Errorf("migration %s: %w", m.name, err) }
+    }
     return nil
 }
 
+// migrateReservationTables records the single table of reservations created
+// before table combinations in the reservation_tables relation.
+func migrateReservationTables(ctx context.Context, tx *sql.Tx) error {
+    _, err := tx.ExecContext(ctx, `INSERT IGNORE INTO reservation_tables (reservation_id, table_id) SELECT id, table_id FROM reservations`)
+    return err
+}
+
+func runMigration(ctx context.Context, db *sql.DB, name string, run func(context.Context, *sql.Tx) error) error {
+    tx, err := db.BeginTx(ctx, nil)
+    if err != nil { return err }
+    defer tx.Rollback()
+    // The primary key makes concurrent starts apply each migration only once.
+    res, err := tx.ExecContext(ctx, `INSERT IGNORE INTO schema_migrations (name) VALUES (?)`, name)
+    if err != nil { return err }
+    if n, _ := res.RowsAffected(); n == 0 { return nil }
+    if err := run(ctx, tx); err != nil { return err }
+    return tx.Commit()
+}
+
+// ensureColumn runs ddl when table.column does not exist yet.
+func ensureColumn(ctx context.Context, db *sql.DB, table, column, ddl string) error {
+    var n int
+    err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?`, table, column).Scan(&n)
+    if err != nil { return err }
+    if n > 0 { return nil }
+    _, err = db.ExecContext(ctx, ddl)
+    return err
+}
+
+// ensureNullable runs ddl while table.column is still NOT NULL. DDL commits
+// implicitly in MySQL, so it is checked like ensureColumn rather than run as
+// a recorded migration.
+func ensureNullable(ctx context.Context, db *sql.DB, table, column, ddl string) error {
+    var nullable string
+    err := db.QueryRowContext(ctx, `SELECT IS_NULLABLE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?`, table, column).Scan(&nullable)
+    if err != nil { return err }
+    if nullable == "YES" { return nil }
+    _, err = db.ExecContext(ctx, ddl)
+    return err
+}
+
@@ -2,7 +2,11 @@ package mysql
 
 import (
     "database/sql"
+    "encoding/json"
     "errors"
+    "fmt"
+    "sort"
+    "strings"
     "time"
 
     "orderation/internal/models"
@@ -14,66 +18,459 @@ type ReservationStore struct { db *sql.DB }
 
 func NewReservationStore(db *sql.DB) *ReservationStore { return &ReservationStore{db: db} }
 
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+    Exec(query string, args ...any) (sql.Result, error)
+}
+
+// scanner is satisfied by both *sql.Row and *sql.Rows.
+type scanner interface {
+    Scan(dest ...any) error
+}
+
+const reservationColumns = `id,restaurant_id,table_id,combination_id,series_id,user_id,guest_name,guest_phone,guest_email,confirmation_code,manage_token_hash,start_time,end_time,guests,turnover_minutes,status,created_at,
+    confirmed_at,seated_at,completed_at,no_show_at,cancelled_at,hold_expires_at,late_cancel,cancel_reason,occasion,dietary,accessibility,notes,seating`
+
+// reservationSelect reads reservationColumns plus the reservation's tables.
+const reservationSelect = `SELECT ` + reservationColumns + `,
+    (SELECT GROUP_CONCAT(rt.table_id ORDER BY rt.table_id) FROM reservation_tables rt WHERE rt.reservation_id = reservations.id)
+    FROM reservations`
+
+func scanReservation(row scanner) (*models.Reservation, error) {
+    var r models.Reservation
+    var confirmed, seated, completed, noShow, cancelled, holdExpires sql.NullTime
+    var userID, code, tableIDs, dietary, accessibility, seating sql.NullString
+    if err := row.Scan(&r.ID,&r.RestaurantID,&r.TableID,&r.CombinationID,&r.SeriesID,&userID,&r.GuestName,&r.GuestPhone,&r.GuestEmail,&code,&r.ManageTokenHash,&r.StartTime,&r.EndTime,&r.Guests,&r.TurnoverMinutes,&r.Status,&r.CreatedAt,
+        &confirmed,&seated,&completed,&noShow,&cancelled,&holdExpires,&r.LateCancel,&r.CancelReason,&r.Occasion,&dietary,&accessibility,&r.Notes,&seating,&tableIDs); err != nil { return nil, err }
+    r.UserID = userID.String
+    r.ConfirmationCode = code.String
+    r.TableIDs = splitIDs(tableIDs)
+    r.Dietary = splitCodes(dietary)
+    r.Accessibility = splitCodes(accessibility)
+    if seating.Valid && seating.String != "" {
+        if err := json.Unmarshal([]byte(seating.String), &r.Seating); err != nil { return nil, err }
+    }
+    if len(r.TableIDs) == 0 { r.TableIDs = []string{r.TableID} }
+    r.ConfirmedAt = nullTimePtr(confirmed)
+    r.SeatedAt = nullTimePtr(seated)
+    r.CompletedAt = nullTimePtr(completed)
+    r.NoShowAt = nullTimePtr(noShow)
+    r.CancelledAt = nullTimePtr(cancelled)
+    r.HoldExpiresAt = nullTimePtr(holdExpires)
+    return &r, nil
+}
+
+func scanReservations(rows *sql.Rows) ([]*models.Reservation, error) {
+    defer rows.Close()
+    out := []*models.Reservation{}
+    for rows.Next() {
+        r, err := scanReservation(rows)
+        if err != nil { return nil, err }
+        out = append(out, r)
+    }
+    return out, rows.Err()
+}
+
+// splitCodes reads a comma-separated list of special request codes; unlike
+// splitIDs it returns nil for an empty list so it is left out of JSON.
+func splitCodes(s sql.NullString) []string {
+    if !s.Valid || s.String == "" { return nil }
+    return strings.Split(s.String, ",")
+}
+
+// seatingJSON encodes table preferences for the seating column, "" for none.
+func seatingJSON(p *models.TablePreferences) string {
+    if p == nil { return "" }
+    b, _ := json.Marshal(p)
+    return string(b)
+}
+
+// nullString stores "" as NULL, for optional columns with a foreign key or
+// unique index.
+func nullString(s string) sql.NullString {
+    return sql.NullString{String: s, Valid: s != ""}
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+    if !t.Valid { return nil }
+    v := t.Time
+    return &v
+}
+
+// statusTimeColumn maps a status to the column recording when it was entered.
+var statusTimeColumn = map[string]string{
+    models.StatusConfirmed: "confirmed_at",
+    models.StatusSeated:    "seated_at",
+    models.StatusCompleted: "completed_at",
+    models.StatusNoShow:    "no_show_at",
+    models.StatusCancelled: "cancelled_at",
+}
+
+// holdingStatuses is the SQL list of statuses for which models.HoldsTable is true.
+const holdingStatuses = `('held','pending','confirmed','seated')`
+
+// bookedStatuses is the SQL list of statuses for which models.Booked is true.
+const bookedStatuses = `('pending','confirmed','seated','completed')`
+
+// lockTables locks the rows of the given tables in a stable order, so
+// concurrent bookings touching any of them serialize instead of deadlocking.
+func lockTables(tx *sql.Tx, tableIDs []string) error {
+    ids := append([]string(nil), tableIDs...)
+    sort.Strings(ids)
+    for _, id := range ids {
+        var found string
+        if err := tx.QueryRow(`SELECT id FROM tables WHERE id=? FOR UPDATE`, id).Scan(&found); err != nil {
+            if errors.Is(err, sql.ErrNoRows) { return errors.New("table not found") }
+            return err
+        }
+    }
+    return nil
+}
+
+// firstOutOfUse returns one of tableIDs that is deleted or blocked during
+// [start, end), or "" if they are all in service.
+func firstOutOfUse(tx *sql.Tx, tableIDs []string, start, end time.Time) (string, error) {
+    in := `(?` + strings.Repeat(",?", len(tableIDs)-1) + `)`
+    q := `SELECT id FROM tables WHERE id IN ` + in + ` AND deleted_at IS NOT NULL
+        UNION ALL SELECT table_id FROM table_blocks WHERE table_id IN ` + in + ` AND start_time < ? AND end_time > ? LIMIT 1`
+    args := []any{}
+    for _, id := range tableIDs { args = append(args, id) }
+    for _, id := range tableIDs { args = append(args, id) }
+    args = append(args, end, start)
+    var tid string
+    err := tx.QueryRow(q, args...).Scan(&tid)
+    if errors.Is(err, sql.ErrNoRows) { return "", nil }
+    return tid, err
+}
+
+// firstConflict returns one of tableIDs that another holding reservation
+// occupies during [start, end), or "" if they are all free.
+func firstConflict(tx *sql.Tx, tableIDs []string, start, end time.Time, excludeID string) (string, error) {
+    q := `SELECT rt.table_id FROM reservation_tables rt JOIN reservations ON reservations.id = rt.reservation_id
+        WHERE rt.table_id IN (?` + strings.Repeat(",?", len(tableIDs)-1) + `) AND reservations.id <> ? AND ` + overlapCond + ` LIMIT 1`
+    args := []any{}
+    for _, id := range tableIDs { args = append(args, id) }
+    now := time.Now()
+    args = append(args, excludeID, end, start, now, start, now)
+    var tid string
+    err := tx.QueryRow(q, args...).Scan(&tid)
+    if errors.Is(err, sql.ErrNoRows) { return "", nil }
+    return tid, err
+}
+
+// overlapCond selects reservations holding their table during [start, end),
+// including each one's turnover time; args are end, start, now, start, now.
+// Seated parties past their end time count as occupying the table until now
+// and lapsed holds are ignored, matching models.Reservation.Occupies.
+const overlapCond = `reservations.status IN ` + holdingStatuses + ` AND reservations.start_time < ?
+    AND (DATE_ADD(reservations.end_time, INTERVAL reservations.turnover_minutes MINUTE) > ?
+        OR (reservations.status = 'seated' AND DATE_ADD(?, INTERVAL reservations.turnover_minutes MINUTE) > ?))
+    AND (reservations.status <> 'held' OR reservations.hold_expires_at > ?)`
+
 func (s *ReservationStore) Create(r *models.Reservation) error {
+    tx, err := s.db.Begin()
+    if err != nil { return err }
+    defer tx.Rollback()
+    if err := insertReservation(tx, r); err != nil { return err }
+    return tx.Commit()
+}
+
+func (s *ReservationStore) CreateIfFree(r *models.Reservation) error {
+    tx, err := s.db.Begin()
+    if err != nil { return err }
+    defer tx.Rollback()
+    if err := lockTables(tx, r.Tables()); err != nil { return err }
+    if err := checkFree(tx, r, ""); err != nil { return err }
+    if err := insertReservation(tx, r); err != nil { return err }
+    return tx.Commit()
+}
+
+func (s *ReservationStore) UpdateIfFree(r *models.Reservation) error {
+    tx, err := s.db.Begin()
+    if err != nil { return err }
+    defer tx.Rollback()
+    if err := lockTables(tx, r.Tables()); err != nil { return err }
+    if err := checkFree(tx, r, r.ID); err != nil { return err }
+    result, err := tx.Exec(`UPDATE reservations SET table_id=?, combination_id=?, start_time=?, end_time=?, guests=?, turnover_minutes=? WHERE id=? AND status IN `+holdingStatuses,
+        r.TableID, r.CombinationID, r.StartTime, r.EndTime, r.Guests, r.TurnoverMinutes, r.ID)
+    if err != nil { return err }
+    if n, err := result.RowsAffected(); err != nil {
+        return err
+    } else if n == 0 {
+        // MySQL reports 0 affected rows when nothing changed; that is fine
+        // as long as the reservation exists and is still active.
+        var status string
+        if err := tx.QueryRow(`SELECT status FROM reservations WHERE id=?`, r.ID).Scan(&status); err != nil {
+            if errors.Is(err, sql.ErrNoRows) { return errors.New("not found") }
+            return err
+        }
+        if !models.HoldsTable(status) { return errors.New("reservation can no longer be changed") }
+    }
+    if _, err := tx.Exec(`DELETE FROM reservation_tables WHERE reservation_id=?`, r.ID); err != nil { return err }
+    if err := insertReservationTables(tx, r); err != nil { return err }
+    return tx.Commit()
+}
+
+// checkFree returns *ConflictError if one of r's tables is deleted, blocked
+// or held by another reservation than excludeID during r's time and turnover.
+// The tables must be locked by lockTables.
+func checkFree(tx *sql.Tx, r *models.Reservation, excludeID string) error {
+    end := r.ClearAt(time.Now())
+    tid, err := firstOutOfUse(tx, r.Tables(), r.StartTime, end)
+    if err != nil { return err }
+    if tid == "" {
+        if tid, err = firstConflict(tx, r.Tables(), r.StartTime, end, excludeID); err != nil { return err }
+    }
+    if tid != "" { return &store.ConflictError{TableID: tid} }
+    return nil
+}
+
+func insertReservationTables(db execer, r *models.Reservation) error {
+    for _, tid := range r.Tables() {
+        if _, err := db.Exec(`INSERT INTO reservation_tables (reservation_id,table_id) VALUES (?,?)`, r.ID, tid); err != nil { return err }
+    }
+    return nil
+}
+
+func insertReservation(db execer, r *models.Reservation) error {
     if r.ID == "" { r.ID = mem.NewIDForExternal() }
     if r.CreatedAt.IsZero() { r.CreatedAt = time.Now() }
-    if r.Status == "" { r.Status = "confirmed" }
-    _, err := s.db.Exec(`INSERT INTO reservations (id,restaurant_id,table_id,user_id,start_time,end_time,guests,status,created_at) VALUES (?,?,?,?,?,?,?,?,?)`,
-        r.ID, r.RestaurantID, r.TableID, r.UserID, r.StartTime, r.EndTime, r.Guests, r.Status, r.CreatedAt)
-    return err
+    if r.Status == "" { r.Status = models.StatusConfirmed }
+    if r.Status == models.StatusConfirmed && r.ConfirmedAt == nil { r.SetStatus(models.StatusConfirmed, r.CreatedAt) }
+    if len(r.TableIDs) == 0 { r.TableIDs = []string{r.TableID} }
+    _, err := db.Exec(`INSERT INTO reservations (`+reservationColumns+`) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`,
+        r.ID, r.RestaurantID, r.TableID, r.CombinationID, r.SeriesID, nullString(r.UserID), r.GuestName, r.GuestPhone, r.GuestEmail, nullString(r.ConfirmationCode), r.ManageTokenHash, r.StartTime, r.EndTime, r.Guests, r.TurnoverMinutes, r.Status, r.CreatedAt,
+        r.ConfirmedAt, r.SeatedAt, r.CompletedAt, r.NoShowAt, r.CancelledAt, r.HoldExpiresAt, r.LateCancel, r.CancelReason,
+        r.Occasion, strings.Join(r.Dietary, ","), strings.Join(r.Accessibility, ","), r.Notes, seatingJSON(r.Seating))
+    if err != nil { return err }
+    return insertReservationTables(db, r)
 }
 
 func (s *ReservationStore) ByID(id string) (*models.Reservation, error) {
-    row := s.db.QueryRow(`SELECT id,restaurant_id,table_id,user_id,start_time,end_time,guests,status,created_at FROM reservations WHERE id=?`, id)
-    var r models.Reservation
-    if err := row.Scan(&r.ID,&r.RestaurantID,&r.TableID,&r.UserID,&r.StartTime,&r.EndTime,&r.Guests,&r.Status,&r.CreatedAt); err != nil {
+    r, err := scanReservation(s.db.QueryRow(reservationSelect+` WHERE id=?`, id))
+    if err != nil {
         if errors.Is(err, sql.ErrNoRows) { return nil, errors.New("not found") }
         return nil, err
     }
-    return &r, nil
+    return r, nil
+}
+
+func (s *ReservationStore) ByConfirmationCode(code string) (*models.Reservation, error) {
+    r, err := scanReservation(s.db.QueryRow(reservationSelect+` WHERE confirmation_code=?`, code))
+    if err != nil {
+        if errors.Is(err, sql.ErrNoRows) { return nil, errors.New("not found") }
+        return nil, err
+    }
+    return r, nil
 }
 
 func (s *ReservationStore) Cancel(id string) error {
-    _, err := s.db.Exec(`UPDATE reservations SET status='cancelled' WHERE id=?`, id)
+    return s.UpdateStatus(id, models.StatusCancelled, time.Now())
+}
+
+func (s *ReservationStore) CancelWithReason(id, reason string, late bool, at time.Time) error {
+    return s.setStatus(id, models.StatusCancelled, at, `, late_cancel=?, cancel_reason=?`, late, reason)
+}
+
+func (s *ReservationStore) UpdateStatus(id, status string, at time.Time) error {
+    return s.setStatus(id, status, at, "")
+}
+
+// setStatus is UpdateStatus that also sets the extra columns in set (e.g.
+// ", notes=?") to setArgs in the same statement.
+func (s *ReservationStore) setStatus(id, status string, at time.Time, set string, setArgs ...any) error {
+    col, ok := statusTimeColumn[status]
+    from := models.TransitionsTo(status)
+    if !ok || len(from) == 0 { return fmt.Errorf("unknown status %q", status) }
+    // Guard on the current status in the WHERE clause so concurrent changes
+    // cannot both apply; a lapsed hold can no longer be confirmed.
+    q := fmt.Sprintf(`UPDATE reservations SET status=?, %s=?, hold_expires_at=NULL%s WHERE id=? AND status IN (?%s)`, col, set, strings.Repeat(",?", len(from)-1))
+    args := append([]any{status, at}, setArgs...)
+    args = append(args, id)
+    for _, f := range from { args = append(args, f) }
+    if status == models.StatusConfirmed {
+        q += ` AND (status <> 'held' OR hold_expires_at > ?)`
+        args = append(args, at)
+    }
+    result, err := s.db.Exec(q, args...)
+    if err != nil { return err }
+    if n, err := result.RowsAffected(); err != nil || n > 0 { return err }
+    cur, err := s.ByID(id)
+    if err != nil { return err }
+    if status == models.StatusConfirmed && cur.HoldExpired(at) { return store.ErrHoldExpired }
+    return &store.TransitionError{From: cur.Status, To: status}
+}
+
+func (s *ReservationStore) UpdateSpecialRequests(id string, sr models.SpecialRequests) error {
+    result, err := s.db.Exec(`UPDATE reservations SET occasion=?, dietary=?, accessibility=?, notes=?, seating=? WHERE id=?`,
+        sr.Occasion, strings.Join(sr.Dietary, ","), strings.Join(sr.Accessibility, ","), sr.Notes, seatingJSON(sr.Seating), id)
+    if err != nil { return err }
+    if n, err := result.RowsAffected(); err != nil || n > 0 { return err }
+    _, err = s.ByID(id)
     return err
 }
 
+func (s *ReservationStore) DeleteHold(id string) error {
+    result, err := s.db.Exec(`DELETE FROM reservations WHERE id=? AND status='held'`, id)
+    if err != nil { return err }
+    n, err := result.RowsAffected()
+    if err != nil { return err }
+    if n == 0 { return errors.New("not found") }
+    return nil
+}
+
+func (s *ReservationStore) DeleteExpiredHolds(now time.Time) (int, error) {
+    result, err := s.db.Exec(`DELETE FROM reservations WHERE status='held' AND hold_expires_at <= ?`, now)
+    if err != nil { return 0, err }
+    n, err := result.RowsAffected()
+    return int(n), err
+}
+
+func (s *ReservationStore) DeleteByRestaurant(restaurantID string) (int, error) {
+    result, err := s.db.Exec(`DELETE FROM reservations WHERE restaurant_id=?`, restaurantID)
+    if err != nil { return 0, err }
+    n, err := result.RowsAffected()
+    return int(n), err
+}
+
 func (s *ReservationStore) ListByUser(userID string) ([]*models.Reservation, error) {
-    rows, err := s.db.Query(`SELECT id,restaurant_id,table_id,user_id,start_time,end_time,guests,status,created_at FROM reservations WHERE user_id=? ORDER BY start_time ASC, id ASC`, userID)
+    rows, err := s.db.Query(reservationSelect+` WHERE user_id=? ORDER BY start_time ASC, id ASC`, userID)
+    if err != nil { return nil, err }
+    return scanReservations(rows)
+}
+
+func (s *ReservationStore) ListByRestaurant(restaurantID string, from, to time.Time) ([]*models.Reservation, error) {
+    rows, err := s.db.Query(reservationSelect+` WHERE restaurant_id=? AND start_time >= ? AND start_time < ? ORDER BY start_time ASC, id ASC`, restaurantID, from, to)
+    if err != nil { return nil, err }
+    return scanReservations(rows)
+}
+
+func (s *ReservationStore) ListBySeries(seriesID string) ([]*models.Reservation, error) {
+    rows, err := s.db.Query(reservationSelect+` WHERE series_id=? AND series_id <> '' ORDER BY start_time ASC, id ASC`, seriesID)
+    if err != nil { return nil, err }
+    return scanReservations(rows)
+}
+
+func (s *ReservationStore) UpcomingByUser(userID string, from time.Time) ([]*models.Reservation, error) {
+    rows, err := s.db.Query(reservationSelect+` WHERE user_id=? AND status IN `+holdingStatuses+` AND end_time > ?
+        AND (status <> 'held' OR hold_expires_at > ?) ORDER BY start_time ASC, id ASC`, userID, from, time.Now())
+    if err != nil { return nil, err }
+    return scanReservations(rows)
+}
+
+func (s *ReservationStore) UpcomingByGuest(email, phone string, from time.Time) ([]*models.Reservation, error) {
+    if email == "" && phone == "" { return nil, nil }
+    rows, err := s.db.Query(reservationSelect+` WHERE user_id IS NULL AND ((? <> '' AND guest_email=?) OR (? <> '' AND guest_phone=?))
+        AND status IN `+holdingStatuses+` AND end_time > ? AND (status <> 'held' OR hold_expires_at > ?) ORDER BY start_time ASC, id ASC`,
+        email, email, phone, phone, from, time.Now())
+    if err != nil { return nil, err }
+    return scanReservations(rows)
+}
+
+func (s *ReservationStore) CountStarts(restaurantID string, from, to time.Time, excludeID string) ([]store.StartCount, error) {
+    rows, err := s.db.Query(`SELECT start_time, COUNT(*), COALESCE(SUM(guests),0) FROM reservations
+        WHERE restaurant_id=? AND id <> ? AND status IN `+holdingStatuses+` AND (status <> 'held' OR hold_expires_at > ?)
+        AND start_time >= ? AND start_time < ? GROUP BY start_time ORDER BY start_time ASC`, restaurantID, excludeID, time.Now(), from, to)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    out := []store.StartCount{}
+    for rows.Next() {
+        var c store.StartCount
+        if err := rows.Scan(&c.Start, &c.Parties, &c.Guests); err != nil { return nil, err }
+        out = append(out, c)
+    }
+    return out, rows.Err()
+}
+
+func (s *ReservationStore) CountByStatus(restaurantID string, from, to time.Time) ([]store.StatusCount, error) {
+    rows, err := s.db.Query(`SELECT status, COUNT(*), COALESCE(SUM(guests),0) FROM reservations
+        WHERE restaurant_id=? AND (status <> 'held' OR hold_expires_at > ?)
+        AND start_time >= ? AND start_time < ? GROUP BY status ORDER BY status ASC`, restaurantID, time.Now(), from, to)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    out := []store.StatusCount{}
+    for rows.Next() {
+        var c store.StatusCount
+        if err := rows.Scan(&c.Status, &c.Parties, &c.Guests); err != nil { return nil, err }
+        out = append(out, c)
+    }
+    return out, rows.Err()
+}
+
+func (s *ReservationStore) TableMinutes(restaurantID string, from, to time.Time) (int, error) {
+    // reservations from before reservation_tables was seeded hold only table_id
+    var total int
+    err := s.db.QueryRow(`SELECT COALESCE(SUM(TIMESTAMPDIFF(MINUTE, GREATEST(start_time, ?), LEAST(end_time, ?))
+            * GREATEST(1, (SELECT COUNT(*) FROM reservation_tables rt WHERE rt.reservation_id = reservations.id))),0)
+        FROM reservations WHERE restaurant_id=? AND status IN `+bookedStatuses+` AND start_time < ? AND end_time > ?`,
+        from, to, restaurantID, to, from).Scan(&total)
+    return total, err
+}
+
+func (s *ReservationStore) HourlyTableMinutes(restaurantID string, from, to time.Time, loc *time.Location) ([]store.HourCount, error) {
+    // Sum by UTC quarter hour, which every zone's hours are made of, and add
+    // those up by local hour below. Reservations last at most 12 hours, so
+    // the 49 quarter hours from the one each starts in cover it.
+    rows, err := s.db.Query(`WITH RECURSIVE offsets (n) AS (SELECT 0 UNION ALL SELECT n + 1 FROM offsets WHERE n < 48)
+        SELECT bucket, SUM(GREATEST(0, TIMESTAMPDIFF(MINUTE, GREATEST(start_time, bucket, ?), LEAST(end_time, bucket + INTERVAL 15 MINUTE, ?))) * tables) FROM (
+            SELECT reservations.start_time, reservations.end_time,
+                TIMESTAMP(DATE_FORMAT(reservations.start_time, '%Y-%m-%d %H:00:00')) + INTERVAL (MINUTE(reservations.start_time) DIV 15 + offsets.n) * 15 MINUTE AS bucket,
+                GREATEST(1, (SELECT COUNT(*) FROM reservation_tables rt WHERE rt.reservation_id = reservations.id)) AS tables
+            FROM reservations JOIN offsets
+            WHERE reservations.restaurant_id=? AND reservations.status IN `+bookedStatuses+` AND reservations.start_time < ? AND reservations.end_time > ?
+        ) b WHERE bucket < end_time AND bucket < ? AND bucket + INTERVAL 15 MINUTE > ?
+        GROUP BY bucket ORDER BY bucket ASC`, from, to, restaurantID, to, from, to, from)
     if err != nil { return nil, err }
     defer rows.Close()
-    var out []*models.Reservation
+    out := []store.HourCount{}
     for rows.Next() {
-        var r models.Reservation
-        if err := rows.Scan(&r.ID,&r.RestaurantID,&r.TableID,&r.UserID,&r.StartTime,&r.EndTime,&r.Guests,&r.Status,&r.CreatedAt); err != nil { return nil, err }
-        out = append(out, &r)
+        var bucket time.Time
+        var minutes int
+        if err := rows.Scan(&bucket, &minutes); err != nil { return nil, err }
+        if minutes <= 0 { continue }
+        hour := store.HourStart(bucket.In(loc))
+        if n := len(out); n > 0 && out[n-1].Hour.Equal(hour) {
+            out[n-1].TableMinutes += minutes
+        } else {
+            out = append(out, store.HourCount{Hour: hour, TableMinutes: minutes})
+        }
     }
-    // Ensure we always return a slice, not nil
-    if out == nil {
-        out = []*models.Reservation{}
+    return out, rows.Err()
+}
+
+func (s *ReservationStore) CountBySeating(restaurantID string, from, to time.Time) ([]store.SeatingCount, error) {
+    rows, err := s.db.Query(`SELECT table_id, combination_id, COUNT(*), COALESCE(SUM(guests),0) FROM reservations
+        WHERE restaurant_id=? AND status IN `+bookedStatuses+` AND start_time >= ? AND start_time < ?
+        GROUP BY table_id, combination_id ORDER BY table_id ASC, combination_id ASC`, restaurantID, from, to)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    out := []store.SeatingCount{}
+    for rows.Next() {
+        var c store.SeatingCount
+        if err := rows.Scan(&c.TableID, &c.CombinationID, &c.Parties, &c.Guests); err != nil { return nil, err }
+        out = append(out, c)
     }
-    return out, nil
+    return out, rows.Err()
+}
+
+func (s *ReservationStore) LeadTime(restaurantID string, from, to time.Time) (store.LeadTime, error) {
+    var lt store.LeadTime
+    err := s.db.QueryRow(`SELECT COUNT(*), COALESCE(SUM(GREATEST(0, TIMESTAMPDIFF(MINUTE, created_at, start_time))),0) FROM reservations
+        WHERE restaurant_id=? AND status IN `+bookedStatuses+` AND start_time >= ? AND start_time < ?`, restaurantID, from, to).Scan(&lt.Parties, &lt.Minutes)
+    return lt, err
 }
 
 func (s *ReservationStore) ListOverlap(f store.ReservationFilter) ([]*models.Reservation, error) {
     // Build query with optional filters
-    q := `SELECT id,restaurant_id,table_id,user_id,start_time,end_time,guests,status,created_at
-          FROM reservations
-          WHERE status <> 'cancelled' AND start_time < ? AND end_time > ?`
-    args := []any{f.EndAfter, f.StartBefore}
+    q := reservationSelect + ` WHERE ` + overlapCond
+    now := time.Now()
+    args := []any{f.EndAfter, f.StartBefore, now, f.StartBefore, now}
     if f.RestaurantID != "" { q += " AND restaurant_id = ?"; args = append(args, f.RestaurantID) }
-    if f.TableID != "" { q += " AND table_id = ?"; args = append(args, f.TableID) }
+    if f.TableID != "" { q += " AND id IN (SELECT reservation_id FROM reservation_tables WHERE table_id = ?)"; args = append(args, f.TableID) }
     if f.UserID != "" { q += " AND user_id = ?"; args = append(args, f.UserID) }
+    if f.ExcludeID != "" { q += " AND id <> ?"; args = append(args, f.ExcludeID) }
     q += " ORDER BY start_time ASC, id ASC"
     rows, err := s.db.Query(q, args...)
     if err != nil { return nil, err }
-    defer rows.Close()
-    var out []*models.Reservation
-    for rows.Next() {
-        var r models.Reservation
-        if err := rows.Scan(&r.ID,&r.RestaurantID,&r.TableID,&r.UserID,&r.StartTime,&r.EndTime,&r.Guests,&r.Status,&r.CreatedAt); err != nil { return nil, err }
-        out = append(out, &r)
-    }
-    return out, nil
+    return scanReservations(rows)
 }
-
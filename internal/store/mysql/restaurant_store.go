@@ -2,6 +2,7 @@ package mysql
 
 import (
     "database/sql"
+    "encoding/json"
     "errors"
     "time"
 
@@ -13,34 +14,62 @@ type RestaurantStore struct { db *sql.DB }
 
 func NewRestaurantStore(db *sql.DB) *RestaurantStore { return &RestaurantStore{db: db} }
 
+const restaurantColumns = `id,name,address,open_time,close_time,time_zone,settings,created_at,archived_at`
+
+func scanRestaurant(row scanner) (*models.Restaurant, error) {
+    var r models.Restaurant
+    var settings sql.NullString
+    var archived sql.NullTime
+    if err := row.Scan(&r.ID,&r.Name,&r.Address,&r.OpenTime,&r.CloseTime,&r.TimeZone,&settings,&r.CreatedAt,&archived); err != nil { return nil, err }
+    r.ArchivedAt = nullTimePtr(archived)
+    if settings.Valid && settings.String != "" {
+        if err := json.Unmarshal([]byte(settings.String), &r.Settings); err != nil { return nil, err }
+    }
+    return &r, nil
+}
+
 func (s *RestaurantStore) Create(r *models.Restaurant) error {
     if r.ID == "" { r.ID = mem.NewIDForExternal() }
     if r.CreatedAt.IsZero() { r.CreatedAt = time.Now() }
-    _, err := s.db.Exec(`INSERT INTO restaurants (id,name,address,open_time,close_time,created_at) VALUES (?,?,?,?,?,?)`, r.ID, r.Name, r.Address, r.OpenTime, r.CloseTime, r.CreatedAt)
+    if r.TimeZone == "" { r.TimeZone = models.DefaultTimeZone }
+    settings, err := json.Marshal(r.Settings)
+    if err != nil { return err }
+    _, err = s.db.Exec(`INSERT INTO restaurants (`+restaurantColumns+`) VALUES (?,?,?,?,?,?,?,?,?)`, r.ID, r.Name, r.Address, r.OpenTime, r.CloseTime, r.TimeZone, string(settings), r.CreatedAt, r.ArchivedAt)
     return err
 }
 
 func (s *RestaurantStore) List() ([]*models.Restaurant, error) {
-    rows, err := s.db.Query(`SELECT id,name,address,open_time,close_time,created_at FROM restaurants ORDER BY created_at ASC, id ASC`)
+    rows, err := s.db.Query(`SELECT `+restaurantColumns+` FROM restaurants ORDER BY created_at ASC, id ASC`)
     if err != nil { return nil, err }
     defer rows.Close()
     var out []*models.Restaurant
     for rows.Next() {
-        var r models.Restaurant
-        if err := rows.Scan(&r.ID,&r.Name,&r.Address,&r.OpenTime,&r.CloseTime,&r.CreatedAt); err != nil { return nil, err }
-        out = append(out, &r)
+        r, err := scanRestaurant(rows)
+        if err != nil { return nil, err }
+        out = append(out, r)
     }
     return out, nil
 }
 
 func (s *RestaurantStore) ByID(id string) (*models.Restaurant, error) {
-    row := s.db.QueryRow(`SELECT id,name,address,open_time,close_time,created_at FROM restaurants WHERE id=?`, id)
-    var r models.Restaurant
-    if err := row.Scan(&r.ID,&r.Name,&r.Address,&r.OpenTime,&r.CloseTime,&r.CreatedAt); err != nil {
+    r, err := scanRestaurant(s.db.QueryRow(`SELECT `+restaurantColumns+` FROM restaurants WHERE id=?`, id))
+    if err != nil {
         if errors.Is(err, sql.ErrNoRows) { return nil, errors.New("not found") }
         return nil, err
     }
-    return &r, nil
+    return r, nil
+}
+
+func (s *RestaurantStore) Update(r *models.Restaurant) error {
+    settings, err := json.Marshal(r.Settings)
+    if err != nil { return err }
+    result, err := s.db.Exec(`UPDATE restaurants SET name=?, address=?, open_time=?, close_time=?, time_zone=?, settings=?, archived_at=? WHERE id=?`,
+        r.Name, r.Address, r.OpenTime, r.CloseTime, r.TimeZone, string(settings), r.ArchivedAt, r.ID)
+    if err != nil { return err }
+    if n, err := result.RowsAffected(); err != nil || n > 0 { return err }
+    // MySQL reports 0 affected rows when nothing changed.
+    _, err = s.ByID(r.ID)
+    return err
 }
 
 func (s *RestaurantStore) Delete(id string) error {
@@ -0,0 +1,54 @@
+package mysql
+
+import (
+    "database/sql"
+    "errors"
+    "time"
+
+    "orderation/internal/models"
+    mem "orderation/internal/store/memory"
+)
+
+type SeriesStore struct { db *sql.DB }
+
+func NewSeriesStore(db *sql.DB) *SeriesStore { return &SeriesStore{db: db} }
+
+const seriesColumns = `id,restaurant_id,user_id,rule,first_start,duration_minutes,guests,table_id,combination_id,status,created_at`
+
+func (s *SeriesStore) Create(sr *models.ReservationSeries) error {
+    if sr.ID == "" { sr.ID = mem.NewIDForExternal() }
+    if sr.CreatedAt.IsZero() { sr.CreatedAt = time.Now() }
+    if sr.Status == "" { sr.Status = models.SeriesActive }
+    _, err := s.db.Exec(`INSERT INTO reservation_series (`+seriesColumns+`) VALUES (?,?,?,?,?,?,?,?,?,?,?)`,
+        sr.ID, sr.RestaurantID, sr.UserID, sr.Rule, sr.FirstStart, sr.DurationMinutes, sr.Guests, sr.TableID, sr.CombinationID, sr.Status, sr.CreatedAt)
+    return err
+}
+
+func (s *SeriesStore) ByID(id string) (*models.ReservationSeries, error) {
+    var sr models.ReservationSeries
+    err := s.db.QueryRow(`SELECT `+seriesColumns+` FROM reservation_series WHERE id=?`, id).
+        Scan(&sr.ID, &sr.RestaurantID, &sr.UserID, &sr.Rule, &sr.FirstStart, &sr.DurationMinutes, &sr.Guests, &sr.TableID, &sr.CombinationID, &sr.Status, &sr.CreatedAt)
+    if err != nil {
+        if errors.Is(err, sql.ErrNoRows) { return nil, errors.New("not found") }
+        return nil, err
+    }
+    return &sr, nil
+}
+
+func (s *SeriesStore) Update(sr *models.ReservationSeries) error {
+    result, err := s.db.Exec(`UPDATE reservation_series SET rule=?, first_start=?, duration_minutes=?, guests=?, table_id=?, combination_id=?, status=? WHERE id=?`,
+        sr.Rule, sr.FirstStart, sr.DurationMinutes, sr.Guests, sr.TableID, sr.CombinationID, sr.Status, sr.ID)
+    if err != nil { return err }
+    if n, err := result.RowsAffected(); err != nil || n > 0 { return err }
+    _, err = s.ByID(sr.ID)
+    return err
+}
+
+func (s *SeriesStore) Delete(id string) error {
+    result, err := s.db.Exec(`DELETE FROM reservation_series WHERE id=?`, id)
+    if err != nil { return err }
+    n, err := result.RowsAffected()
+    if err != nil { return err }
+    if n == 0 { return errors.New("not found") }
+    return nil
+}
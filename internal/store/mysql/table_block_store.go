@@ -0,0 +1,67 @@
+package mysql
+
+import (
+    "database/sql"
+    "errors"
+    "time"
+
+    "orderation/internal/models"
+    mem "orderation/internal/store/memory"
+)
+
+type TableBlockStore struct { db *sql.DB }
+
+func NewTableBlockStore(db *sql.DB) *TableBlockStore { return &TableBlockStore{db: db} }
+
+const tableBlockColumns = `id,restaurant_id,table_id,start_time,end_time,reason,created_at`
+
+func scanTableBlock(row scanner) (*models.TableBlock, error) {
+    var b models.TableBlock
+    if err := row.Scan(&b.ID,&b.RestaurantID,&b.TableID,&b.Start,&b.End,&b.Reason,&b.CreatedAt); err != nil { return nil, err }
+    return &b, nil
+}
+
+// Create locks the table like a booking does, so a booking in progress
+// either lands before the block or sees it.
+func (s *TableBlockStore) Create(b *models.TableBlock) error {
+    if b.ID == "" { b.ID = mem.NewIDForExternal() }
+    if b.CreatedAt.IsZero() { b.CreatedAt = time.Now() }
+    tx, err := s.db.Begin()
+    if err != nil { return err }
+    defer tx.Rollback()
+    if err := lockTables(tx, []string{b.TableID}); err != nil { return err }
+    if _, err := tx.Exec(`INSERT INTO table_blocks (`+tableBlockColumns+`) VALUES (?,?,?,?,?,?,?)`,
+        b.ID, b.RestaurantID, b.TableID, b.Start, b.End, b.Reason, b.CreatedAt); err != nil { return err }
+    return tx.Commit()
+}
+
+func (s *TableBlockStore) ByID(id string) (*models.TableBlock, error) {
+    b, err := scanTableBlock(s.db.QueryRow(`SELECT `+tableBlockColumns+` FROM table_blocks WHERE id=?`, id))
+    if err != nil {
+        if errors.Is(err, sql.ErrNoRows) { return nil, errors.New("not found") }
+        return nil, err
+    }
+    return b, nil
+}
+
+func (s *TableBlockStore) Delete(id string) error {
+    result, err := s.db.Exec(`DELETE FROM table_blocks WHERE id=?`, id)
+    if err != nil { return err }
+    n, err := result.RowsAffected()
+    if err != nil { return err }
+    if n == 0 { return errors.New("not found") }
+    return nil
+}
+
+func (s *TableBlockStore) ListByRestaurant(restaurantID string, from, to time.Time) ([]*models.TableBlock, error) {
+    rows, err := s.db.Query(`SELECT `+tableBlockColumns+` FROM table_blocks WHERE restaurant_id=? AND start_time < ? AND end_time > ? ORDER BY start_time ASC, id ASC`, restaurantID, to, from)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    out := []*models.TableBlock{}
+    for rows.Next() {
+        b, err := scanTableBlock(rows)
+        if err != nil { return nil, err }
+        out = append(out, b)
+    }
+    return out, rows.Err()
+}
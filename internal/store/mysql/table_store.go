@@ -13,33 +13,76 @@ type TableStore struct { db *sql.DB }
 
 func NewTableStore(db *sql.DB) *TableStore { return &TableStore{db: db} }
 
+const tableColumns = `id,restaurant_id,name,capacity,section,accessible,high_top,booth,smoking,created_at,deleted_at`
+
+func scanTable(row scanner) (*models.Table, error) {
+    var t models.Table
+    var deleted sql.NullTime
+    if err := row.Scan(&t.ID,&t.RestaurantID,&t.Name,&t.Capacity,&t.Section,&t.Accessible,&t.HighTop,&t.Booth,&t.Smoking,&t.CreatedAt,&deleted); err != nil { return nil, err }
+    t.DeletedAt = nullTimePtr(deleted)
+    return &t, nil
+}
+
 func (s *TableStore) Create(t *models.Table) error {
     if t.ID == "" { t.ID = mem.NewIDForExternal() }
     if t.CreatedAt.IsZero() { t.CreatedAt = time.Now() }
-    _, err := s.db.Exec(`INSERT INTO tables (id,restaurant_id,name,capacity,created_at) VALUES (?,?,?,?,?)`, t.ID, t.RestaurantID, t.Name, t.Capacity, t.CreatedAt)
+    _, err := s.db.Exec(`INSERT INTO tables (`+tableColumns+`) VALUES (?,?,?,?,?,?,?,?,?,?,?)`,
+        t.ID, t.RestaurantID, t.Name, t.Capacity, t.Section, t.Accessible, t.HighTop, t.Booth, t.Smoking, t.CreatedAt, t.DeletedAt)
     return err
 }
 
 func (s *TableStore) ListByRestaurant(restaurantID string) ([]*models.Table, error) {
-    rows, err := s.db.Query(`SELECT id,restaurant_id,name,capacity,created_at FROM tables WHERE restaurant_id=? ORDER BY capacity ASC, created_at ASC`, restaurantID)
+    return s.list(`SELECT `+tableColumns+` FROM tables WHERE restaurant_id=? AND deleted_at IS NULL ORDER BY capacity ASC, created_at ASC`, restaurantID)
+}
+
+func (s *TableStore) ListAllByRestaurant(restaurantID string) ([]*models.Table, error) {
+    return s.list(`SELECT `+tableColumns+` FROM tables WHERE restaurant_id=? ORDER BY capacity ASC, created_at ASC`, restaurantID)
+}
+
+func (s *TableStore) list(query string, args ...any) ([]*models.Table, error) {
+    rows, err := s.db.Query(query, args...)
     if err != nil { return nil, err }
     defer rows.Close()
     var out []*models.Table
     for rows.Next() {
-        var t models.Table
-        if err := rows.Scan(&t.ID,&t.RestaurantID,&t.Name,&t.Capacity,&t.CreatedAt); err != nil { return nil, err }
-        out = append(out, &t)
+        t, err := scanTable(rows)
+        if err != nil { return nil, err }
+        out = append(out, t)
     }
     return out, nil
 }
 
 func (s *TableStore) ByID(id string) (*models.Table, error) {
-    row := s.db.QueryRow(`SELECT id,restaurant_id,name,capacity,created_at FROM tables WHERE id=?`, id)
-    var t models.Table
-    if err := row.Scan(&t.ID,&t.RestaurantID,&t.Name,&t.Capacity,&t.CreatedAt); err != nil {
+    t, err := scanTable(s.db.QueryRow(`SELECT `+tableColumns+` FROM tables WHERE id=?`, id))
+    if err != nil {
         if errors.Is(err, sql.ErrNoRows) { return nil, errors.New("not found") }
         return nil, err
     }
-    return &t, nil
+    return t, nil
 }
 
+
+func (s *TableStore) Update(t *models.Table) error {
+    result, err := s.db.Exec(`UPDATE tables SET name=?, capacity=?, section=?, accessible=?, high_top=?, booth=?, smoking=? WHERE id=?`,
+        t.Name, t.Capacity, t.Section, t.Accessible, t.HighTop, t.Booth, t.Smoking, t.ID)
+    if err != nil { return err }
+    if n, err := result.RowsAffected(); err != nil || n > 0 { return err }
+    _, err = s.ByID(t.ID)
+    return err
+}
+
+func (s *TableStore) Delete(id string, at time.Time) error {
+    result, err := s.db.Exec(`UPDATE tables SET deleted_at=? WHERE id=? AND deleted_at IS NULL`, at, id)
+    if err != nil { return err }
+    n, err := result.RowsAffected()
+    if err != nil { return err }
+    if n == 0 { return errors.New("not found") }
+    return nil
+}
+
+func (s *TableStore) DeleteByRestaurant(restaurantID string) (int, error) {
+    result, err := s.db.Exec(`DELETE FROM tables WHERE restaurant_id=?`, restaurantID)
+    if err != nil { return 0, err }
+    n, err := result.RowsAffected()
+    return int(n), err
+}
@@ -17,15 +17,15 @@ func NewUserStore(db *sql.DB) *UserStore { return &UserStore{db: db} }
 func (s *UserStore) Create(u *models.User) error {
     if u.ID == "" { u.ID = mem.NewIDForExternal() }
     if u.CreatedAt.IsZero() { u.CreatedAt = time.Now() }
-    _, err := s.db.Exec(`INSERT INTO users (id,name,email,pass_hash,role,created_at) VALUES (?,?,?,?,?,?)`, u.ID, u.Name, strings.ToLower(u.Email), u.PassHash, u.Role, u.CreatedAt)
+    _, err := s.db.Exec(`INSERT INTO users (id,name,email,pass_hash,role,limit_exempt,created_at) VALUES (?,?,?,?,?,?,?)`, u.ID, u.Name, strings.ToLower(u.Email), u.PassHash, u.Role, u.LimitExempt, u.CreatedAt)
     if err != nil { return err }
     return nil
 }
 
 func (s *UserStore) ByEmail(email string) (*models.User, error) {
-    row := s.db.QueryRow(`SELECT id,name,email,pass_hash,role,created_at FROM users WHERE email=?`, strings.ToLower(email))
+    row := s.db.QueryRow(`SELECT id,name,email,pass_hash,role,limit_exempt,created_at FROM users WHERE email=?`, strings.ToLower(email))
     var u models.User
-    if err := row.Scan(&u.ID,&u.Name,&u.Email,&u.PassHash,&u.Role,&u.CreatedAt); err != nil {
+    if err := row.Scan(&u.ID,&u.Name,&u.Email,&u.PassHash,&u.Role,&u.LimitExempt,&u.CreatedAt); err != nil {
         if errors.Is(err, sql.ErrNoRows) { return nil, errors.New("not found") }
         return nil, err
     }
@@ -33,12 +33,19 @@ func (s *UserStore) ByEmail(email string) (*models.User, error) {
 }
 
 func (s *UserStore) ByID(id string) (*models.User, error) {
-    row := s.db.QueryRow(`SELECT id,name,email,pass_hash,role,created_at FROM users WHERE id=?`, id)
+    row := s.db.QueryRow(`SELECT id,name,email,pass_hash,role,limit_exempt,created_at FROM users WHERE id=?`, id)
     var u models.User
-    if err := row.Scan(&u.ID,&u.Name,&u.Email,&u.PassHash,&u.Role,&u.CreatedAt); err != nil {
+    if err := row.Scan(&u.ID,&u.Name,&u.Email,&u.PassHash,&u.Role,&u.LimitExempt,&u.CreatedAt); err != nil {
         if errors.Is(err, sql.ErrNoRows) { return nil, errors.New("not found") }
         return nil, err
     }
     return &u, nil
 }
 
+func (s *UserStore) SetLimitExempt(id string, exempt bool) error {
+    result, err := s.db.Exec(`UPDATE users SET limit_exempt=? WHERE id=?`, exempt, id)
+    if err != nil { return err }
+    if n, err := result.RowsAffected(); err != nil || n > 0 { return err }
+    _, err = s.ByID(id)
+    return err
+}
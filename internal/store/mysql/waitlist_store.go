@@ -0,0 +1,91 @@
+package mysql
+
+import (
+    "database/sql"
+    "errors"
+    "time"
+
+    "orderation/internal/models"
+    mem "orderation/internal/store/memory"
+)
+
+type WaitlistStore struct { db *sql.DB }
+
+func NewWaitlistStore(db *sql.DB) *WaitlistStore { return &WaitlistStore{db: db} }
+
+const waitlistColumns = `id,restaurant_id,user_id,date,window_start,window_end,guests,duration_minutes,auto_book,status,
+    offer_start,offer_expires_at,claim_token,reservation_id,created_at`
+
+func scanWaitlistEntry(row scanner) (*models.WaitlistEntry, error) {
+    var e models.WaitlistEntry
+    var offerStart, offerExpires sql.NullTime
+    if err := row.Scan(&e.ID,&e.RestaurantID,&e.UserID,&e.Date,&e.WindowStart,&e.WindowEnd,&e.Guests,&e.DurationMinutes,&e.AutoBook,&e.Status,
+        &offerStart,&offerExpires,&e.ClaimToken,&e.ReservationID,&e.CreatedAt); err != nil { return nil, err }
+    e.OfferStart = nullTimePtr(offerStart)
+    e.OfferExpiresAt = nullTimePtr(offerExpires)
+    return &e, nil
+}
+
+func (s *WaitlistStore) Create(e *models.WaitlistEntry) error {
+    if e.ID == "" { e.ID = mem.NewIDForExternal() }
+    if e.CreatedAt.IsZero() { e.CreatedAt = time.Now() }
+    if e.Status == "" { e.Status = models.WaitlistWaiting }
+    _, err := s.db.Exec(`INSERT INTO waitlist_entries (`+waitlistColumns+`) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`,
+        e.ID, e.RestaurantID, e.UserID, e.Date, e.WindowStart, e.WindowEnd, e.Guests, e.DurationMinutes, e.AutoBook, e.Status,
+        e.OfferStart, e.OfferExpiresAt, e.ClaimToken, e.ReservationID, e.CreatedAt)
+    return err
+}
+
+func (s *WaitlistStore) ByID(id string) (*models.WaitlistEntry, error) {
+    e, err := scanWaitlistEntry(s.db.QueryRow(`SELECT `+waitlistColumns+` FROM waitlist_entries WHERE id=?`, id))
+    if err != nil {
+        if errors.Is(err, sql.ErrNoRows) { return nil, errors.New("not found") }
+        return nil, err
+    }
+    return e, nil
+}
+
+func (s *WaitlistStore) Update(e *models.WaitlistEntry) error {
+    result, err := s.db.Exec(`UPDATE waitlist_entries SET status=?, offer_start=?, offer_expires_at=?, claim_token=?, reservation_id=? WHERE id=?`,
+        e.Status, e.OfferStart, e.OfferExpiresAt, e.ClaimToken, e.ReservationID, e.ID)
+    if err != nil { return err }
+    if n, err := result.RowsAffected(); err != nil || n > 0 { return err }
+    _, err = s.ByID(e.ID)
+    return err
+}
+
+func (s *WaitlistStore) ListByRestaurant(restaurantID, date string) ([]*models.WaitlistEntry, error) {
+    q := `SELECT ` + waitlistColumns + ` FROM waitlist_entries WHERE restaurant_id=?`
+    args := []any{restaurantID}
+    if date != "" { q += " AND date = ?"; args = append(args, date) }
+    return s.query(q+" ORDER BY created_at ASC, id ASC", args...)
+}
+
+func (s *WaitlistStore) ListByUser(userID string) ([]*models.WaitlistEntry, error) {
+    return s.query(`SELECT `+waitlistColumns+` FROM waitlist_entries WHERE user_id=? ORDER BY created_at ASC, id ASC`, userID)
+}
+
+func (s *WaitlistStore) ListExpiredOffers(now time.Time) ([]*models.WaitlistEntry, error) {
+    return s.query(`SELECT `+waitlistColumns+` FROM waitlist_entries WHERE status=? AND offer_expires_at < ? ORDER BY created_at ASC, id ASC`,
+        models.WaitlistOffered, now)
+}
+
+func (s *WaitlistStore) DeleteByRestaurant(restaurantID string) (int, error) {
+    result, err := s.db.Exec(`DELETE FROM waitlist_entries WHERE restaurant_id=?`, restaurantID)
+    if err != nil { return 0, err }
+    n, err := result.RowsAffected()
+    return int(n), err
+}
+
+func (s *WaitlistStore) query(q string, args ...any) ([]*models.WaitlistEntry, error) {
+    rows, err := s.db.Query(q, args...)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    out := []*models.WaitlistEntry{}
+    for rows.Next() {
+        e, err := scanWaitlistEntry(rows)
+        if err != nil { return nil, err }
+        out = append(out, e)
+    }
+    return out, rows.Err()
+}
@@ -1,6 +1,8 @@
 package store
 
 import (
+    "errors"
+    "fmt"
     "time"
 
     "orderation/internal/models"
@@ -10,34 +12,286 @@ type UserStore interface {
     Create(u *models.User) error
     ByEmail(email string) (*models.User, error)
     ByID(id string) (*models.User, error)
+    // SetLimitExempt marks whether the user is exempt from booking limits.
+    SetLimitExempt(id string, exempt bool) error
 }
 
 type RestaurantStore interface {
     Create(r *models.Restaurant) error
     List() ([]*models.Restaurant, error)
     ByID(id string) (*models.Restaurant, error)
+    Update(r *models.Restaurant) error
+    // Delete removes the restaurant for good; see RestaurantHandler.Purge.
     Delete(id string) error
 }
 
 type TableStore interface {
     Create(t *models.Table) error
+    // ListByRestaurant returns the restaurant's tables that are not deleted.
     ListByRestaurant(restaurantID string) ([]*models.Table, error)
+    // ListAllByRestaurant also returns deleted tables, for figures about the
+    // past.
+    ListAllByRestaurant(restaurantID string) ([]*models.Table, error)
+    // ByID also finds deleted tables, so past reservations keep theirs.
     ByID(id string) (*models.Table, error)
+    // Update saves the table's name, capacity, section and features.
+    Update(t *models.Table) error
+    // Delete takes the table out of use by setting DeletedAt; the row is kept
+    // for the reservations that used it.
+    Delete(id string, at time.Time) error
+    // DeleteByRestaurant permanently removes every table of the restaurant,
+    // deleted or not, and returns how many there were.
+    DeleteByRestaurant(restaurantID string) (int, error)
+}
+
+// TableBlockStore holds the times tables are out of service.
+type TableBlockStore interface {
+    Create(b *models.TableBlock) error
+    ByID(id string) (*models.TableBlock, error)
+    Delete(id string) error
+    // ListByRestaurant returns the restaurant's blocks overlapping [from, to),
+    // ordered by start.
+    ListByRestaurant(restaurantID string, from, to time.Time) ([]*models.TableBlock, error)
+}
+
+// FloorPlanStore holds each restaurant's floor plan: the grid, its sections
+// and where tables are drawn.
+type FloorPlanStore interface {
+    // Plan returns the restaurant's grid, or a "not found" error if none has
+    // been saved.
+    Plan(restaurantID string) (*models.FloorPlan, error)
+    SavePlan(p *models.FloorPlan) error
+    CreateSection(s *models.FloorSection) error
+    SectionByID(id string) (*models.FloorSection, error)
+    UpdateSection(s *models.FloorSection) error
+    DeleteSection(id string) error
+    ListSections(restaurantID string) ([]*models.FloorSection, error)
+    // SetPosition places a table, replacing any earlier position.
+    SetPosition(p *models.TablePosition) error
+    DeletePosition(tableID string) error
+    ListPositions(restaurantID string) ([]*models.TablePosition, error)
+    // DeleteByRestaurant removes the plan with its sections and positions.
+    DeleteByRestaurant(restaurantID string) error
+}
+
+// TableCombinationStore holds the sets of tables that can be joined for
+// large parties.
+type TableCombinationStore interface {
+    Create(c *models.TableCombination) error
+    ListByRestaurant(restaurantID string) ([]*models.TableCombination, error)
+    ByID(id string) (*models.TableCombination, error)
+    Delete(id string) error
+}
+
+// HoursStore holds the weekly service periods of each restaurant.
+type HoursStore interface {
+    Create(p *models.ServicePeriod) error
+    ListByRestaurant(restaurantID string) ([]*models.ServicePeriod, error)
+    ByID(id string) (*models.ServicePeriod, error)
+    Update(p *models.ServicePeriod) error
+    Delete(id string) error
+}
+
+// ExceptionStore holds date-specific overrides of the weekly schedule. There is
+// at most one exception per restaurant and date.
+type ExceptionStore interface {
+    Create(e *models.HoursException) error
+    // ListByRestaurant returns exceptions with from <= date <= to, ordered by
+    // date. Dates are YYYY-MM-DD; an empty bound is open-ended.
+    ListByRestaurant(restaurantID, from, to string) ([]*models.HoursException, error)
+    ByID(id string) (*models.HoursException, error)
+    Delete(id string) error
+}
+
+// SeriesStore holds recurring reservation series; their occurrences live in
+// the ReservationStore (see ReservationStore.ListBySeries).
+type SeriesStore interface {
+    Create(s *models.ReservationSeries) error
+    ByID(id string) (*models.ReservationSeries, error)
+    // Update saves the series' rule, time, party size, tables and status.
+    Update(s *models.ReservationSeries) error
+    Delete(id string) error
+}
+
+// WaitlistStore holds parties waiting for a table to free up.
+type WaitlistStore interface {
+    Create(e *models.WaitlistEntry) error
+    ByID(id string) (*models.WaitlistEntry, error)
+    // Update saves the entry's status, offer and reservation fields.
+    Update(e *models.WaitlistEntry) error
+    // ListByRestaurant returns entries in the order they joined; an empty
+    // date (YYYY-MM-DD) returns every date.
+    ListByRestaurant(restaurantID, date string) ([]*models.WaitlistEntry, error)
+    ListByUser(userID string) ([]*models.WaitlistEntry, error)
+    // ListExpiredOffers returns the entries of every restaurant holding an
+    // offer that expired before now, in the order they joined.
+    ListExpiredOffers(now time.Time) ([]*models.WaitlistEntry, error)
+    // DeleteByRestaurant removes all of the restaurant's entries and returns
+    // how many there were.
+    DeleteByRestaurant(restaurantID string) (int, error)
 }
 
 type ReservationFilter struct {
     RestaurantID string
-    TableID      string
+    TableID      string // matches reservations holding this table, alone or combined
     UserID       string
+    ExcludeID    string // skip this reservation, e.g. the one being modified
     StartBefore  time.Time
     EndAfter     time.Time
 }
 
+// ConflictError is returned when a reservation cannot be stored because one of
+// its tables is already booked for an overlapping time.
+type ConflictError struct {
+    TableID string
+}
+
+func (e *ConflictError) Error() string {
+    return fmt.Sprintf("table %s is already booked for the requested time", e.TableID)
+}
+
+// TransitionError is returned when a reservation cannot move from its current
+// status to the requested one.
+type TransitionError struct {
+    From string
+    To   string
+}
+
+func (e *TransitionError) Error() string {
+    return fmt.Sprintf("cannot change reservation from %s to %s", e.From, e.To)
+}
+
+// ErrHoldExpired is returned when confirming a hold after its expiry; the
+// table may already have been given to someone else.
+var ErrHoldExpired = errors.New("hold has expired")
+
+// StartCount totals the reservations that start at one moment.
+type StartCount struct {
+    Start   time.Time
+    Parties int
+    Guests  int
+}
+
+// StatusCount totals the reservations in one status.
+type StatusCount struct {
+    Status  string
+    Parties int
+    Guests  int
+}
+
+// HourCount totals the table minutes booked within one hour.
+type HourCount struct {
+    Hour         time.Time // start of the local hour
+    TableMinutes int
+}
+
+// HourStart is the start of t's hour in t's location. Zone offsets are whole
+// quarter hours, so that is where the local minutes are zero.
+func HourStart(t time.Time) time.Time {
+    return t.Add(-time.Duration(t.Minute())*time.Minute - time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
+}
+
+// SeatingCount totals the booked parties seated at one table or combination.
+type SeatingCount struct {
+    TableID       string
+    CombinationID string
+    Parties       int
+    Guests        int
+}
+
+// LeadTime totals how long before their start booked parties reserved.
+type LeadTime struct {
+    Parties int
+    Minutes int // sum over the parties; bookings made after the start count as 0
+}
+
 type ReservationStore interface {
     Create(r *models.Reservation) error
+    // CreateIfFree checks every table of the reservation (see
+    // models.Reservation.Tables) for overlapping bookings and inserts it in one
+    // atomic step. It returns *ConflictError on overlap.
+    CreateIfFree(r *models.Reservation) error
+    // UpdateIfFree moves an existing reservation to r's tables, time and party
+    // size if none of those tables has another overlapping booking, all in one
+    // atomic step. It returns *ConflictError on overlap and leaves the stored
+    // reservation unchanged.
+    UpdateIfFree(r *models.Reservation) error
     ByID(id string) (*models.Reservation, error)
+    // ByConfirmationCode finds a guest booking by its confirmation code.
+    ByConfirmationCode(code string) (*models.Reservation, error)
+    // Cancel is UpdateStatus(id, models.StatusCancelled, time.Now()).
     Cancel(id string) error
+    // CancelWithReason cancels like UpdateStatus and also records the reason
+    // and whether the cancellation came after the restaurant's deadline.
+    CancelWithReason(id, reason string, late bool, at time.Time) error
+    // UpdateStatus moves the reservation to status and stamps the matching
+    // timestamp with at. It returns *TransitionError if the state machine does
+    // not allow the change, and ErrHoldExpired when confirming a lapsed hold.
+    UpdateStatus(id, status string, at time.Time) error
+    // UpdateSpecialRequests replaces the reservation's occasion, dietary
+    // restrictions, accessibility needs and notes.
+    UpdateSpecialRequests(id string, sr models.SpecialRequests) error
+    // DeleteHold removes a reservation that is still in status held.
+    DeleteHold(id string) error
+    // DeleteExpiredHolds removes holds that lapsed before now and returns how
+    // many were removed.
+    DeleteExpiredHolds(now time.Time) (int, error)
+    // DeleteByRestaurant removes all of the restaurant's reservations in any
+    // status and returns how many there were.
+    DeleteByRestaurant(restaurantID string) (int, error)
     ListByUser(userID string) ([]*models.Reservation, error)
+    // ListByRestaurant returns the restaurant's reservations in any status
+    // that start in [from, to), ordered by start time.
+    ListByRestaurant(restaurantID string, from, to time.Time) ([]*models.Reservation, error)
+    // ListBySeries returns the occurrences of a series ordered by start time.
+    ListBySeries(seriesID string) ([]*models.Reservation, error)
+    // UpcomingByUser returns the user's reservations that still hold their
+    // tables (see models.HoldsTable, ignoring lapsed holds) and end after
+    // from, ordered by start time.
+    UpcomingByUser(userID string, from time.Time) ([]*models.Reservation, error)
+    // UpcomingByGuest is UpcomingByUser for bookings made without an account
+    // with the given email or phone; an empty value matches nothing.
+    UpcomingByGuest(email, phone string, from time.Time) ([]*models.Reservation, error)
+    // CountStarts totals, by start time, the restaurant's reservations that
+    // hold their tables (ignoring lapsed holds) and start in [from, to),
+    // leaving out excludeID (if set). The result is ordered by start.
+    CountStarts(restaurantID string, from, to time.Time, excludeID string) ([]StartCount, error)
+    // CountByStatus totals, by status, the restaurant's reservations that
+    // start in [from, to), ignoring lapsed holds. The result is ordered by
+    // status.
+    CountByStatus(restaurantID string, from, to time.Time) ([]StatusCount, error)
+    // TableMinutes sums the minutes within [from, to) that the restaurant's
+    // tables are booked (see models.Booked), counting a reservation once for
+    // each table it holds.
+    TableMinutes(restaurantID string, from, to time.Time) (int, error)
+    // HourlyTableMinutes splits TableMinutes(restaurantID, from, to) over the
+    // hours of the local time in loc it falls in, ordered by hour and leaving
+    // out hours with nothing booked.
+    HourlyTableMinutes(restaurantID string, from, to time.Time, loc *time.Location) ([]HourCount, error)
+    // CountBySeating totals the booked reservations (see models.Booked) that
+    // start in [from, to) by their table and combination.
+    CountBySeating(restaurantID string, from, to time.Time) ([]SeatingCount, error)
+    // LeadTime totals the lead time of the booked reservations that start in
+    // [from, to).
+    LeadTime(restaurantID string, from, to time.Time) (LeadTime, error)
+    // ListOverlap returns reservations that still hold their table (see
+    // models.HoldsTable, ignoring lapsed holds) and overlap [StartBefore,
+    // EndAfter), treating seated parties that stay past their end time as
+    // occupying the table until now.
     ListOverlap(f ReservationFilter) ([]*models.Reservation, error)
 }
 
+// IdempotencyStore remembers responses to requests made with an
+// Idempotency-Key header.
+type IdempotencyStore interface {
+    // Begin stores rec as in progress unless an unexpired record with the same
+    // key exists, in which case it returns that record and stores nothing.
+    Begin(rec *models.IdempotencyRecord) (*models.IdempotencyRecord, error)
+    // Complete saves the response for a key started with Begin.
+    Complete(rec *models.IdempotencyRecord) error
+    // Abandon forgets a key whose request should be retried from scratch.
+    Abandon(key string) error
+    // DeleteExpired removes records that expired before now and returns how
+    // many were removed.
+    DeleteExpired(now time.Time) (int, error)
+}
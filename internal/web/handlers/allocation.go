@@ -0,0 +1,173 @@
+package handlers
+
+import (
+    "sort"
+    "time"
+
+    "orderation/internal/models"
+)
+
+// allocation is the set of tables assigned to one reservation: a single
+// table, or every table of a combination.
+type allocation struct {
+    tableIDs      []string
+    capacity      int
+    combinationID string
+}
+
+func singleTable(t *models.Table) *allocation {
+    return &allocation{tableIDs: []string{t.ID}, capacity: t.Capacity}
+}
+
+func combination(c *models.TableCombination) *allocation {
+    return &allocation{tableIDs: c.TableIDs, capacity: c.Capacity, combinationID: c.ID}
+}
+
+const msgNotAccessible = "table is not wheelchair accessible"
+
+// preferenceWeight is how many empty seats one unmet seating preference is
+// worth when ranking tables.
+const preferenceWeight = 3
+
+// seatingNeeds is what a party asks of its tables. accessible is a hard
+// requirement every table must meet; prefs only rank the tables that do.
+// avoid names a table the party is being moved off, e.g. one about to be
+// deleted or blocked.
+type seatingNeeds struct {
+    accessible bool
+    prefs      *models.TablePreferences
+    avoid      string
+}
+
+func needsOf(sr models.SpecialRequests) seatingNeeds {
+    return seatingNeeds{accessible: sr.NeedsAccessibleTable(), prefs: sr.Seating}
+}
+
+// admits reports whether t meets the hard requirements.
+func (n seatingNeeds) admits(t *models.Table) bool {
+    return t.ID != n.avoid && (!n.accessible || t.Accessible)
+}
+
+// admitsAll reports whether every table in tableIDs meets the hard
+// requirements. It loads the tables only when accessibility is required.
+func (h *ReservationHandler) admitsAll(n seatingNeeds, tableIDs []string) bool {
+    for _, id := range tableIDs {
+        if id == n.avoid {
+            return false
+        }
+        if !n.accessible {
+            continue
+        }
+        t, err := h.tables.ByID(id)
+        if err != nil || !n.admits(t) {
+            return false
+        }
+    }
+    return true
+}
+
+// apply assigns the allocation's tables to r.
+func (a *allocation) apply(r *models.Reservation) {
+    r.TableID = a.tableIDs[0]
+    r.TableIDs = append([]string(nil), a.tableIDs...)
+    r.CombinationID = a.combinationID
+}
+
+// allocationFor resolves an explicitly requested table or combination of the
+// restaurant. It returns nil and an error message when the choice is invalid
+// or the table has been deleted.
+func (h *ReservationHandler) allocationFor(restaurantID, tableID, combinationID string) (*allocation, string) {
+    if combinationID != "" {
+        c, err := h.combinations.ByID(combinationID)
+        if err != nil || c.RestaurantID != restaurantID || len(c.TableIDs) == 0 {
+            return nil, "invalid combinationId"
+        }
+        return combination(c), ""
+    }
+    t, err := h.tables.ByID(tableID)
+    if err != nil || t.RestaurantID != restaurantID || t.DeletedAt != nil {
+        return nil, "invalid tableId"
+    }
+    return singleTable(t), ""
+}
+
+// findBestAllocation picks a single table for the party when one is free,
+// and otherwise the best free combination of joinable tables. Only tables
+// meeting the party's hard requirements are considered.
+func (h *ReservationHandler) findBestAllocation(restaurant *models.Restaurant, start, end time.Time, guests int, excludeID string, needs seatingNeeds) *allocation {
+    occ, err := h.loadOccupancy(restaurant, start, end, excludeID)
+    if err != nil {
+        return nil
+    }
+    if t := h.findBestAvailableTable(restaurant.ID, occ, start, end, guests, needs); t != nil {
+        return singleTable(t)
+    }
+    if c := h.findBestCombination(restaurant.ID, occ, start, end, guests, needs); c != nil {
+        return combination(c)
+    }
+    return nil
+}
+
+// findBestAvailableTable finds the most suitable available table using smart allocation
+func (h *ReservationHandler) findBestAvailableTable(restaurantID string, occ *occupancy, start, end time.Time, guests int, needs seatingNeeds) *models.Table {
+    tables, err := h.tables.ListByRestaurant(restaurantID)
+    if err != nil {
+        return nil
+    }
+    
+    var availableTables []*models.Table
+    
+    // First, find all available tables that can accommodate the guests
+    for _, t := range tables {
+        if t.Capacity < guests || !needs.admits(t) {
+            continue
+        }
+        
+        if occ.free(t.ID, start, end) {
+            availableTables = append(availableTables, t)
+        }
+    }
+    
+    if len(availableTables) == 0 {
+        return nil
+    }
+    
+    // Smart allocation strategy:
+    // 1. Prefer tables with capacity closest to guest count (minimize waste),
+    //    counting each unmet seating preference as preferenceWeight empty seats
+    // 2. If multiple tables score the same, choose by ID
+    sort.Slice(availableTables, func(i, j int) bool {
+        // Sort by wasted seats plus preference penalty (ascending)
+        diffI := availableTables[i].Capacity - guests + preferenceWeight*needs.prefs.Misses(availableTables[i])
+        diffJ := availableTables[j].Capacity - guests + preferenceWeight*needs.prefs.Misses(availableTables[j])
+        if diffI != diffJ {
+            return diffI < diffJ
+        }
+        // If same difference, sort by table ID for deterministic behavior
+        return availableTables[i].ID < availableTables[j].ID
+    })
+    
+    return availableTables[0]
+}
+
+// findBestCombination returns the free combination that seats the party with
+// the fewest empty seats, preferring fewer joined tables on a tie. Seating
+// preferences do not apply to combinations.
+func (h *ReservationHandler) findBestCombination(restaurantID string, occ *occupancy, start, end time.Time, guests int, needs seatingNeeds) *models.TableCombination {
+    combos, err := h.combinations.ListByRestaurant(restaurantID)
+    if err != nil {
+        return nil
+    }
+    var best *models.TableCombination
+    for _, c := range combos {
+        if c.Capacity < guests || len(c.TableIDs) == 0 || !occ.freeAll(c.TableIDs, start, end) || !h.admitsAll(needs, c.TableIDs) {
+            continue
+        }
+        if best == nil || c.Capacity < best.Capacity ||
+            (c.Capacity == best.Capacity && (len(c.TableIDs) < len(best.TableIDs) ||
+                (len(c.TableIDs) == len(best.TableIDs) && c.ID < best.ID))) {
+            best = c
+        }
+    }
+    return best
+}
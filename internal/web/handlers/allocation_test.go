@@ -0,0 +1,49 @@
+package handlers
+
+import (
+    "testing"
+    "time"
+
+    "orderation/internal/models"
+    "orderation/internal/store/memory"
+)
+
+func TestFindBestAvailableTable(t *testing.T) {
+    tables := memory.NewTableStore()
+    add := func(name string, capacity int, section string, accessible, booth bool) {
+        tables.Create(&models.Table{ID: name, RestaurantID: "r", Name: name, Capacity: capacity, Section: section, Accessible: accessible, Booth: booth})
+    }
+    add("main2", 2, "main", false, false)
+    add("patio4", 4, "patio", false, false)
+    add("booth4", 4, "main", true, true)
+    add("patio6", 6, "patio", true, false)
+    h := &ReservationHandler{tables: tables}
+    occ := &occupancy{byTable: map[string][]interval{}}
+    start := time.Date(2026, 3, 3, 19, 0, 0, 0, time.UTC)
+    end := start.Add(2 * time.Hour)
+    yes, no := true, false
+
+    cases := []struct {
+        name   string
+        guests int
+        needs  seatingNeeds
+        want   string
+    }{
+        {"smallest table without preferences", 2, seatingNeeds{}, "main2"},
+        {"a preference outweighs two empty seats", 2, seatingNeeds{prefs: &models.TablePreferences{Section: "patio"}}, "patio4"},
+        {"an unmet preference counts against the table", 2, seatingNeeds{prefs: &models.TablePreferences{Section: "patio", HighTop: &yes}}, "patio4"},
+        {"avoiding a feature", 4, seatingNeeds{prefs: &models.TablePreferences{Booth: &no}}, "patio4"},
+        {"accessibility is a requirement", 2, seatingNeeds{accessible: true}, "booth4"},
+        {"preferences rank the accessible tables", 2, seatingNeeds{accessible: true, prefs: &models.TablePreferences{Section: "patio"}}, "patio6"},
+        {"no accessible table is large enough", 8, seatingNeeds{accessible: true}, ""},
+    }
+    for _, c := range cases {
+        got := ""
+        if tbl := h.findBestAvailableTable("r", occ, start, end, c.guests, c.needs); tbl != nil {
+            got = tbl.ID
+        }
+        if got != c.want {
+            t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+        }
+    }
+}
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+    "net/http"
+    "time"
+
+    "orderation/internal/models"
+    "orderation/internal/web/router"
+)
+
+// Archive hides a restaurant from the list and stops new bookings, keeping
+// its tables, reservations and history. Restore undoes it.
+func (h *RestaurantHandler) Archive(w http.ResponseWriter, r *http.Request) {
+    h.setArchived(w, r, true)
+}
+
+// Restore brings an archived restaurant back.
+func (h *RestaurantHandler) Restore(w http.ResponseWriter, r *http.Request) {
+    h.setArchived(w, r, false)
+}
+
+func (h *RestaurantHandler) setArchived(w http.ResponseWriter, r *http.Request, archived bool) {
+    rest, err := h.restaurants.ByID(router.Param(r, "id"))
+    if err != nil {
+        notFound(w, "restaurant not found")
+        return
+    }
+    if rest.Archived() == archived {
+        if archived {
+            conflict(w, "restaurant is already archived")
+        } else {
+            conflict(w, "restaurant is not archived")
+        }
+        return
+    }
+    next := *rest
+    next.ArchivedAt = nil
+    if archived {
+        now := time.Now()
+        next.ArchivedAt = &now
+    }
+    if err := h.restaurants.Update(&next); err != nil {
+        badRequest(w, "could not update restaurant")
+        return
+    }
+    writeJSON(w, http.StatusOK, next)
+}
+
+// ListArchived returns the archived restaurants.
+func (h *RestaurantHandler) ListArchived(w http.ResponseWriter, r *http.Request) {
+    writeJSON(w, http.StatusOK, h.listArchived(true))
+}
+
+// purgeSummary counts what purging a restaurant removes along with it.
+type purgeSummary struct {
+    Restaurant           *models.Restaurant `json:"restaurant"`
+    Tables               int                `json:"tables"`
+    Combinations         int                `json:"combinations"`
+    ServicePeriods       int                `json:"servicePeriods"`
+    Exceptions           int                `json:"exceptions"`
+    Blocks               int                `json:"blocks"`
+    Reservations         int                `json:"reservations"`
+    UpcomingReservations int                `json:"upcomingReservations"` // still holding a table
+    Series               int                `json:"series"`
+    WaitlistEntries      int                `json:"waitlistEntries"`
+}
+
+// purgeContents is everything stored for one restaurant.
+type purgeContents struct {
+    tables       []*models.Table
+    combinations []*models.TableCombination
+    periods      []*models.ServicePeriod
+    exceptions   []*models.HoursException
+    blocks       []*models.TableBlock
+    reservations []*models.Reservation
+    seriesIDs    []string
+    waitlist     []*models.WaitlistEntry
+}
+
+func (h *RestaurantHandler) loadPurgeContents(rid string) (*purgeContents, error) {
+    c := &purgeContents{}
+    var err error
+    // wide enough to cover every booking the restaurant can have
+    from, to := time.Unix(0, 0), time.Now().AddDate(upcomingYears, 0, 0)
+    if c.tables, err = h.tables.ListByRestaurant(rid); err != nil {
+        return nil, err
+    }
+    if c.combinations, err = h.combinations.ListByRestaurant(rid); err != nil {
+        return nil, err
+    }
+    if c.periods, err = h.hours.ListByRestaurant(rid); err != nil {
+        return nil, err
+    }
+    if c.exceptions, err = h.exceptions.ListByRestaurant(rid, "", ""); err != nil {
+        return nil, err
+    }
+    if c.blocks, err = h.blocks.ListByRestaurant(rid, from, to); err != nil {
+        return nil, err
+    }
+    if c.reservations, err = h.reservations.ListByRestaurant(rid, from, to); err != nil {
+        return nil, err
+    }
+    seen := map[string]bool{}
+    for _, res := range c.reservations {
+        if res.SeriesID == "" || seen[res.SeriesID] {
+            continue
+        }
+        seen[res.SeriesID] = true
+        // a purge that failed partway may have removed the series already
+        if _, err := h.series.ByID(res.SeriesID); err == nil {
+            c.seriesIDs = append(c.seriesIDs, res.SeriesID)
+        }
+    }
+    if c.waitlist, err = h.waitlist.ListByRestaurant(rid, ""); err != nil {
+        return nil, err
+    }
+    return c, nil
+}
+
+func (c *purgeContents) summary(rest *models.Restaurant) purgeSummary {
+    now := time.Now()
+    upcoming := 0
+    for _, res := range c.reservations {
+        if models.HoldsTable(res.Status) && res.EndTime.After(now) {
+            upcoming++
+        }
+    }
+    return purgeSummary{
+        Restaurant:           rest,
+        Tables:               len(c.tables),
+        Combinations:         len(c.combinations),
+        ServicePeriods:       len(c.periods),
+        Exceptions:           len(c.exceptions),
+        Blocks:               len(c.blocks),
+        Reservations:         len(c.reservations),
+        UpcomingReservations: upcoming,
+        Series:               len(c.seriesIDs),
+        WaitlistEntries:      len(c.waitlist),
+    }
+}
+
+// PurgePreview shows what Purge would remove.
+func (h *RestaurantHandler) PurgePreview(w http.ResponseWriter, r *http.Request) {
+    rest, err := h.restaurants.ByID(router.Param(r, "id"))
+    if err != nil {
+        notFound(w, "restaurant not found")
+        return
+    }
+    c, err := h.loadPurgeContents(rest.ID)
+    if err != nil {
+        badRequest(w, "could not load restaurant data")
+        return
+    }
+    writeJSON(w, http.StatusOK, c.summary(rest))
+}
+
+// Purge permanently removes an archived restaurant with its tables, hours,
+// reservations, series and waitlist, and returns what was removed. Only
+// archived restaurants can be purged, so a restaurant in use is never lost
+// by one request.
+func (h *RestaurantHandler) Purge(w http.ResponseWriter, r *http.Request) {
+    rest, err := h.restaurants.ByID(router.Param(r, "id"))
+    if err != nil {
+        notFound(w, "restaurant not found")
+        return
+    }
+    if !rest.Archived() {
+        writeError(w, http.StatusConflict, "restaurant_not_archived", "archive the restaurant before purging it")
+        return
+    }
+    c, err := h.loadPurgeContents(rest.ID)
+    if err != nil {
+        badRequest(w, "could not load restaurant data")
+        return
+    }
+    removed := c.summary(rest)
+    if err := h.purge(rest.ID, c, &removed); err != nil {
+        badRequest(w, "could not purge restaurant")
+        return
+    }
+    writeJSON(w, http.StatusOK, removed)
+}
+
+// purge deletes the restaurant's data, dependents first, and records the
+// actual number of reservations, waitlist entries and tables removed. Each
+// step removes only what is still stored and the restaurant row goes last,
+// so a purge that fails partway leaves the restaurant archived and can be
+// run again to finish. Series go before reservations because they are found
+// through them.
+func (h *RestaurantHandler) purge(rid string, c *purgeContents, removed *purgeSummary) error {
+    var err error
+    for _, id := range c.seriesIDs {
+        if err := h.series.Delete(id); err != nil {
+            return err
+        }
+    }
+    if removed.Reservations, err = h.reservations.DeleteByRestaurant(rid); err != nil {
+        return err
+    }
+    if removed.WaitlistEntries, err = h.waitlist.DeleteByRestaurant(rid); err != nil {
+        return err
+    }
+    for _, b := range c.blocks {
+        if err := h.blocks.Delete(b.ID); err != nil {
+            return err
+        }
+    }
+    for _, cb := range c.combinations {
+        if err := h.combinations.Delete(cb.ID); err != nil {
+            return err
+        }
+    }
+    for _, p := range c.periods {
+        if err := h.hours.Delete(p.ID); err != nil {
+            return err
+        }
+    }
+    for _, e := range c.exceptions {
+        if err := h.exceptions.Delete(e.ID); err != nil {
+            return err
+        }
+    }
+    if err := h.floor.DeleteByRestaurant(rid); err != nil {
+        return err
+    }
+    // includes tables already deleted, which ListByRestaurant skips
+    if removed.Tables, err = h.tables.DeleteByRestaurant(rid); err != nil {
+        return err
+    }
+    return h.restaurants.Delete(rid)
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+    "fmt"
+    "net/http"
+    "time"
+
+    "orderation/internal/models"
+)
+
+// BookingLimits cap how much a single account can book, across all
+// restaurants. Zero disables a limit. Admins and accounts marked
+// LimitExempt are not limited. Guests booking without an account are limited
+// by their email and phone, each counting as one booker.
+type BookingLimits struct {
+    MaxActive    int  // upcoming reservations held at once
+    MaxPerDay    int  // reservations starting on the same day
+    AllowOverlap bool // allow reservations that overlap in time
+}
+
+// DefaultBookingLimits are used unless configured otherwise: no limits.
+var DefaultBookingLimits = BookingLimits{AllowOverlap: true}
+
+// Error codes returned when a booking would exceed a per-user limit.
+const (
+    codeActiveLimit     = "active_reservation_limit"
+    codeDailyLimit      = "daily_reservation_limit"
+    codeOverlappingUser = "overlapping_reservation"
+)
+
+// bookingLimitsError checks a booking between start and end at restaurant,
+// made by the user or guest of who, against the per-user limits, ignoring the
+// reservation excludeID (if set) when an existing one is moved. Occurrences of
+// a recurring series do not count against each other: other occurrences of
+// who's series (if any) are ignored too. It returns a 409 when a limit would
+// be exceeded, or nil.
+func (h *ReservationHandler) bookingLimitsError(who *models.Reservation, restaurant *models.Restaurant, start, end time.Time, excludeID string) *bookingError {
+    l := h.limits
+    if l.MaxActive <= 0 && l.MaxPerDay <= 0 && l.AllowOverlap {
+        return nil
+    }
+    var upcoming []*models.Reservation
+    var err error
+    switch {
+    case who.UserID != "":
+        if u, err := h.users.ByID(who.UserID); err == nil && (u.Role == "admin" || u.LimitExempt) {
+            return nil
+        }
+        upcoming, err = h.reservations.UpcomingByUser(who.UserID, time.Now())
+    case who.GuestEmail != "" || who.GuestPhone != "":
+        upcoming, err = h.reservations.UpcomingByGuest(who.GuestEmail, who.GuestPhone, time.Now())
+    default:
+        return nil
+    }
+    if err != nil {
+        return &bookingError{status: http.StatusBadRequest, msg: "could not load reservations"}
+    }
+    if seriesID := who.SeriesID; excludeID != "" || seriesID != "" {
+        others := upcoming[:0:0]
+        for _, r := range upcoming {
+            if r.ID != excludeID && (seriesID == "" || r.SeriesID != seriesID) {
+                others = append(others, r)
+            }
+        }
+        upcoming = others
+    }
+    if l.MaxActive > 0 && len(upcoming) >= l.MaxActive {
+        return &bookingError{status: http.StatusConflict, code: codeActiveLimit, msg: fmt.Sprintf("you already have %d upcoming reservations", len(upcoming))}
+    }
+    // days are counted in the time zone of the restaurant being booked
+    loc := restaurant.Location()
+    day := start.In(loc).Format("2006-01-02")
+    sameDay := 0
+    for _, r := range upcoming {
+        if !l.AllowOverlap && r.StartTime.Before(end) && r.EndTime.After(start) {
+            return &bookingError{status: http.StatusConflict, code: codeOverlappingUser, msg: "you already have a reservation at this time"}
+        }
+        if r.StartTime.In(loc).Format("2006-01-02") == day {
+            sameDay++
+        }
+    }
+    if l.MaxPerDay > 0 && sameDay >= l.MaxPerDay {
+        return &bookingError{status: http.StatusConflict, code: codeDailyLimit, msg: fmt.Sprintf("at most %d reservations can start on the same day", l.MaxPerDay)}
+    }
+    return nil
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+    "fmt"
+    "net/http"
+    "time"
+
+    "orderation/internal/models"
+)
+
+// ruleViolation is one booking rule a request breaks. Field names the request
+// field at fault so clients can highlight it.
+type ruleViolation struct {
+    Field   string `json:"field"`
+    Code    string `json:"code"`
+    Message string `json:"message"`
+}
+
+// codeBookingRules is the error code of a response listing rule violations.
+const codeBookingRules = "booking_rules_violated"
+
+// checkBookingRules returns the restaurant's booking rules that a booking for
+// guests starting at start, made at now, would break. Starts in the past are
+// never allowed.
+func checkBookingRules(restaurant *models.Restaurant, start time.Time, guests int, now time.Time) []ruleViolation {
+    rules := restaurant.Settings.BookingRules
+    var out []ruleViolation
+    earliest, latest := rules.Window(now)
+    switch {
+    case start.Before(now):
+        out = append(out, ruleViolation{"start", "start_in_past", "start must not be in the past"})
+    case start.Before(earliest):
+        out = append(out, ruleViolation{"start", "lead_time_too_short", fmt.Sprintf("bookings must be made at least %d minutes in advance", rules.MinLeadMinutes)})
+    case !latest.IsZero() && start.After(latest):
+        out = append(out, ruleViolation{"start", "too_far_in_advance", fmt.Sprintf("bookings open at most %d days in advance", rules.MaxAdvanceDays)})
+    }
+    if n := rules.StartIntervalMinutes; n > 0 {
+        local := start.In(restaurant.Location())
+        if local.Second() != 0 || local.Nanosecond() != 0 || (local.Hour()*60+local.Minute())%n != 0 {
+            out = append(out, ruleViolation{"start", "start_not_on_interval", fmt.Sprintf("start must be on a %d-minute boundary", n)})
+        }
+    }
+    return append(out, partySizeViolations(rules, guests)...)
+}
+
+// partySizeViolations returns the party-size rules a party of guests breaks.
+func partySizeViolations(rules models.BookingRules, guests int) []ruleViolation {
+    var out []ruleViolation
+    if rules.MinPartySize > 0 && guests < rules.MinPartySize {
+        out = append(out, ruleViolation{"guests", "party_too_small", fmt.Sprintf("parties must have at least %d guests", rules.MinPartySize)})
+    }
+    if rules.MaxPartySize > 0 && guests > rules.MaxPartySize {
+        out = append(out, ruleViolation{"guests", "party_too_large", fmt.Sprintf("parties can have at most %d guests", rules.MaxPartySize)})
+    }
+    return out
+}
+
+// bookingRulesError returns a 400 listing the violations if the booking
+// breaks any of the restaurant's rules, or nil.
+func bookingRulesError(restaurant *models.Restaurant, start time.Time, guests int) *bookingError {
+    violations := checkBookingRules(restaurant, start, guests, time.Now())
+    if len(violations) == 0 {
+        return nil
+    }
+    return &bookingError{status: http.StatusBadRequest, code: codeBookingRules, msg: violations[0].Message, violations: violations}
+}
+
+// enforceBookingRules writes the bookingRulesError and returns false if the
+// booking breaks any of the restaurant's rules.
+func enforceBookingRules(w http.ResponseWriter, restaurant *models.Restaurant, start time.Time, guests int) bool {
+    if e := bookingRulesError(restaurant, start, guests); e != nil {
+        e.write(w)
+        return false
+    }
+    return true
+}
+
+func validateBookingRules(b models.BookingRules) string {
+    switch {
+    case b.MinLeadMinutes < 0 || b.MinLeadMinutes > 30*24*60:
+        return "bookingRules.minLeadMinutes must be between 0 and 43200"
+    case b.MaxAdvanceDays < 0 || b.MaxAdvanceDays > 3*365:
+        return "bookingRules.maxAdvanceDays must be between 0 and 1095"
+    case b.MaxAdvanceDays > 0 && b.MinLeadMinutes >= b.MaxAdvanceDays*24*60:
+        return "bookingRules.minLeadMinutes must be shorter than maxAdvanceDays"
+    case b.MinPartySize < 0 || b.MaxPartySize < 0:
+        return "bookingRules party sizes must be >= 0"
+    case b.MaxPartySize > 0 && b.MinPartySize > b.MaxPartySize:
+        return "bookingRules.minPartySize must not exceed maxPartySize"
+    case b.StartIntervalMinutes < 0 || b.StartIntervalMinutes > 24*60 || (b.StartIntervalMinutes > 0 && 24*60%b.StartIntervalMinutes != 0):
+        return "bookingRules.startIntervalMinutes must divide a day evenly"
+    }
+    return ""
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+    "errors"
+    "net/http"
+    "strings"
+    "time"
+
+    "orderation/internal/models"
+    "orderation/internal/store"
+)
+
+// Error codes returned when the cancellation policy refuses a cancellation.
+const (
+    codeCancelDeadlinePassed = "cancellation_deadline_passed"
+    codeReservationStarted   = "reservation_already_started"
+    codeReasonRequired       = "cancel_reason_required"
+)
+
+const maxCancelReasonLen = 500
+
+// cancel cancels res under its restaurant's cancellation policy and writes the
+// response.
+func (h *ReservationHandler) cancel(w http.ResponseWriter, res *models.Reservation, reason string, admin bool) {
+    late, e := h.cancelUnderPolicy(res, reason, admin)
+    if e != nil {
+        e.write(w)
+        return
+    }
+    writeJSON(w, http.StatusOK, map[string]any{"status": "cancelled", "lateCancel": late})
+}
+
+// cancelUnderPolicy cancels res and offers its tables to the waitlist.
+// Guests cannot cancel once the reservation has started, nor after the
+// deadline unless the policy allows late cancellations; admins can always
+// cancel. A cancellation after the deadline is recorded as late and needs a
+// reason.
+func (h *ReservationHandler) cancelUnderPolicy(res *models.Reservation, reason string, admin bool) (late bool, e *bookingError) {
+    reason = strings.TrimSpace(reason)
+    if len(reason) > maxCancelReasonLen {
+        return false, &bookingError{status: http.StatusBadRequest, msg: "reason is too long"}
+    }
+    restaurant, err := h.restaurants.ByID(res.RestaurantID)
+    if err != nil {
+        return false, &bookingError{status: http.StatusNotFound, msg: "restaurant not found"}
+    }
+    policy := restaurant.Settings.Cancellation
+    now := time.Now()
+    // holds are released rather than cancelled late
+    if models.HoldsTable(res.Status) && res.Status != models.StatusHeld {
+        deadline := policy.Deadline(res.StartTime)
+        switch {
+        case !now.Before(res.StartTime):
+            if !admin {
+                return false, &bookingError{status: http.StatusConflict, code: codeReservationStarted, msg: "the reservation has already started and can no longer be cancelled"}
+            }
+            late = true
+        case !now.Before(deadline):
+            if !admin && !policy.AllowLateCancel {
+                return false, &bookingError{status: http.StatusForbidden, code: codeCancelDeadlinePassed,
+                    msg: "reservations can only be cancelled until " + deadline.In(restaurant.Location()).Format(time.RFC3339)}
+            }
+            late = true
+        }
+    }
+    if late && reason == "" {
+        return false, &bookingError{status: http.StatusBadRequest, code: codeReasonRequired, msg: "a reason is required for late cancellations"}
+    }
+    if err := h.reservations.CancelWithReason(res.ID, reason, late, now); err != nil {
+        var te *store.TransitionError
+        if errors.As(err, &te) {
+            return false, &bookingError{status: http.StatusConflict, msg: "reservation is already " + te.From}
+        }
+        return false, &bookingError{status: http.StatusBadRequest, msg: "unable to cancel"}
+    }
+    h.offerFreedTables(res)
+    return late, nil
+}
@@ -0,0 +1,445 @@
+package handlers
+
+import (
+    "encoding/json"
+    "net/http"
+    "strings"
+    "time"
+
+    "orderation/internal/models"
+    "orderation/internal/store"
+    "orderation/internal/web/router"
+)
+
+// Floor statuses of a table at a moment, from the floor plan state and
+// restaurant details.
+const (
+    floorAvailable = "available"
+    floorReserved  = "reserved" // booked, party not seated yet
+    floorSeated    = "seated"
+    floorTurnover  = "turnover" // party gone, table being reset
+    floorBlocked   = "blocked"  // out of service
+)
+
+// planFor returns the restaurant's floor plan, or the default grid if staff
+// have not saved one.
+func (h *RestaurantHandler) planFor(rid string) models.FloorPlan {
+    if p, err := h.floor.Plan(rid); err == nil {
+        return *p
+    }
+    return models.FloorPlan{RestaurantID: rid, Width: models.DefaultFloorWidth, Height: models.DefaultFloorHeight}
+}
+
+// livePositions returns the positions of tables that have not been deleted,
+// by table ID.
+func (h *RestaurantHandler) livePositions(rid string, tables []*models.Table) (map[string]*models.TablePosition, error) {
+    list, err := h.floor.ListPositions(rid)
+    if err != nil {
+        return nil, err
+    }
+    live := map[string]bool{}
+    for _, t := range tables {
+        live[t.ID] = true
+    }
+    out := map[string]*models.TablePosition{}
+    for _, p := range list {
+        if live[p.TableID] {
+            out[p.TableID] = p
+        }
+    }
+    return out, nil
+}
+
+type floorPlanResp struct {
+    Plan      models.FloorPlan        `json:"plan"`
+    Sections  []*models.FloorSection  `json:"sections"`
+    Positions []*models.TablePosition `json:"positions"`
+}
+
+// GetFloorPlan returns the restaurant's grid, sections and table positions.
+func (h *RestaurantHandler) GetFloorPlan(w http.ResponseWriter, r *http.Request) {
+    rid := router.Param(r, "id")
+    if _, err := h.restaurants.ByID(rid); err != nil {
+        notFound(w, "restaurant not found")
+        return
+    }
+    sections, err := h.floor.ListSections(rid)
+    if err != nil {
+        badRequest(w, "could not load floor plan")
+        return
+    }
+    tables, _ := h.tables.ListByRestaurant(rid)
+    byTable, err := h.livePositions(rid, tables)
+    if err != nil {
+        badRequest(w, "could not load floor plan")
+        return
+    }
+    positions := []*models.TablePosition{}
+    for _, t := range tables {
+        if p := byTable[t.ID]; p != nil {
+            positions = append(positions, p)
+        }
+    }
+    writeJSON(w, http.StatusOK, floorPlanResp{Plan: h.planFor(rid), Sections: sections, Positions: positions})
+}
+
+type saveFloorPlanReq struct {
+    Width  int `json:"width"`
+    Height int `json:"height"`
+}
+
+// SaveFloorPlan resizes the grid. Shrinking it is refused while a section or
+// table would fall off the edge.
+func (h *RestaurantHandler) SaveFloorPlan(w http.ResponseWriter, r *http.Request) {
+    rid := router.Param(r, "id")
+    if _, err := h.restaurants.ByID(rid); err != nil {
+        notFound(w, "restaurant not found")
+        return
+    }
+    var req saveFloorPlanReq
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        badRequest(w, "invalid json")
+        return
+    }
+    if req.Width <= 0 || req.Height <= 0 {
+        badRequest(w, "width and height must be > 0")
+        return
+    }
+    plan := &models.FloorPlan{RestaurantID: rid, Width: req.Width, Height: req.Height}
+    sections, err := h.floor.ListSections(rid)
+    if err != nil {
+        badRequest(w, "could not load floor plan")
+        return
+    }
+    tables, _ := h.tables.ListByRestaurant(rid)
+    positions, err := h.livePositions(rid, tables)
+    if err != nil {
+        badRequest(w, "could not load floor plan")
+        return
+    }
+    outside := []string{}
+    for _, s := range sections {
+        if !plan.Fits(s.X, s.Y, s.Width, s.Height) {
+            outside = append(outside, s.ID)
+        }
+    }
+    for _, p := range positions {
+        if !plan.Fits(p.X, p.Y, p.Width, p.Height) {
+            outside = append(outside, p.TableID)
+        }
+    }
+    if len(outside) > 0 {
+        writeJSON(w, http.StatusConflict, map[string]any{"error": "sections or tables lie outside the new plan", "code": "floor_plan_too_small", "outside": outside})
+        return
+    }
+    if err := h.floor.SavePlan(plan); err != nil {
+        badRequest(w, "could not save floor plan")
+        return
+    }
+    writeJSON(w, http.StatusOK, plan)
+}
+
+type floorSectionReq struct {
+    Name   string `json:"name"`
+    X      int    `json:"x"`
+    Y      int    `json:"y"`
+    Width  int    `json:"width"`
+    Height int    `json:"height"`
+}
+
+// checkSection validates a section against the plan and the restaurant's
+// other sections, writing the error response if it is not acceptable.
+func (h *RestaurantHandler) checkSection(w http.ResponseWriter, sec *models.FloorSection) bool {
+    if sec.Name == "" {
+        badRequest(w, "name is required")
+        return false
+    }
+    plan := h.planFor(sec.RestaurantID)
+    if !plan.Fits(sec.X, sec.Y, sec.Width, sec.Height) {
+        badRequest(w, "section must lie within the plan")
+        return false
+    }
+    others, err := h.floor.ListSections(sec.RestaurantID)
+    if err != nil {
+        badRequest(w, "could not load floor plan")
+        return false
+    }
+    for _, o := range others {
+        if o.ID != sec.ID && o.Name == sec.Name {
+            conflict(w, "section name already exists")
+            return false
+        }
+    }
+    return true
+}
+
+// CreateSection adds a named area to the plan. Tables whose section has the
+// same name are drawn in it.
+func (h *RestaurantHandler) CreateSection(w http.ResponseWriter, r *http.Request) {
+    rid := router.Param(r, "id")
+    if _, err := h.restaurants.ByID(rid); err != nil {
+        notFound(w, "restaurant not found")
+        return
+    }
+    var req floorSectionReq
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        badRequest(w, "invalid json")
+        return
+    }
+    sec := &models.FloorSection{RestaurantID: rid, Name: strings.ToLower(strings.TrimSpace(req.Name)), X: req.X, Y: req.Y, Width: req.Width, Height: req.Height}
+    if !h.checkSection(w, sec) {
+        return
+    }
+    if err := h.floor.CreateSection(sec); err != nil {
+        badRequest(w, "could not create section")
+        return
+    }
+    writeJSON(w, http.StatusCreated, sec)
+}
+
+// UpdateSection renames, moves or resizes a section.
+func (h *RestaurantHandler) UpdateSection(w http.ResponseWriter, r *http.Request) {
+    cur, err := h.floor.SectionByID(router.Param(r, "id"))
+    if err != nil {
+        notFound(w, "section not found")
+        return
+    }
+    var req floorSectionReq
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        badRequest(w, "invalid json")
+        return
+    }
+    next := *cur
+    next.Name = strings.ToLower(strings.TrimSpace(req.Name))
+    next.X, next.Y, next.Width, next.Height = req.X, req.Y, req.Width, req.Height
+    if !h.checkSection(w, &next) {
+        return
+    }
+    if err := h.floor.UpdateSection(&next); err != nil {
+        badRequest(w, "could not update section")
+        return
+    }
+    writeJSON(w, http.StatusOK, next)
+}
+
+// DeleteSection removes a section from the plan; its tables keep their
+// positions.
+func (h *RestaurantHandler) DeleteSection(w http.ResponseWriter, r *http.Request) {
+    if err := h.floor.DeleteSection(router.Param(r, "id")); err != nil {
+        notFound(w, "section not found")
+        return
+    }
+    writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+type tablePositionReq struct {
+    X        int    `json:"x"`
+    Y        int    `json:"y"`
+    Width    int    `json:"width"`
+    Height   int    `json:"height"`
+    Shape    string `json:"shape"`    // rect, square, round or oval; defaults to rect
+    Rotation int    `json:"rotation"` // degrees clockwise
+}
+
+// SetTablePosition places a table on the plan or moves it. Tables may not
+// overlap one another.
+func (h *RestaurantHandler) SetTablePosition(w http.ResponseWriter, r *http.Request) {
+    t, err := h.tables.ByID(router.Param(r, "id"))
+    if err != nil || t.DeletedAt != nil {
+        notFound(w, "table not found")
+        return
+    }
+    var req tablePositionReq
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        badRequest(w, "invalid json")
+        return
+    }
+    pos := &models.TablePosition{TableID: t.ID, RestaurantID: t.RestaurantID, X: req.X, Y: req.Y, Width: req.Width, Height: req.Height, Shape: req.Shape, Rotation: req.Rotation}
+    if err := pos.Normalize(h.planFor(t.RestaurantID)); err != nil {
+        badRequest(w, err.Error())
+        return
+    }
+    tables, _ := h.tables.ListByRestaurant(t.RestaurantID)
+    others, err := h.livePositions(t.RestaurantID, tables)
+    if err != nil {
+        badRequest(w, "could not load floor plan")
+        return
+    }
+    for _, o := range others {
+        if o.TableID != t.ID && pos.X < o.X+o.Width && o.X < pos.X+pos.Width && pos.Y < o.Y+o.Height && o.Y < pos.Y+pos.Height {
+            writeJSON(w, http.StatusConflict, map[string]string{"error": "position overlaps another table", "code": "position_overlaps", "tableId": o.TableID})
+            return
+        }
+    }
+    if err := h.floor.SetPosition(pos); err != nil {
+        badRequest(w, "could not save position")
+        return
+    }
+    writeJSON(w, http.StatusOK, pos)
+}
+
+// DeleteTablePosition takes a table off the plan without deleting it.
+func (h *RestaurantHandler) DeleteTablePosition(w http.ResponseWriter, r *http.Request) {
+    if err := h.floor.DeletePosition(router.Param(r, "id")); err != nil {
+        notFound(w, "table is not on the floor plan")
+        return
+    }
+    writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// floorReservation is the part of a reservation shown on the floor plan.
+type floorReservation struct {
+    ID        string    `json:"id"`
+    Status    string    `json:"status"`
+    Guests    int       `json:"guests"`
+    GuestName string    `json:"guestName,omitempty"`
+    Start     time.Time `json:"start"`
+    End       time.Time `json:"end"`
+}
+
+func newFloorReservation(r *models.Reservation) *floorReservation {
+    return &floorReservation{ID: r.ID, Status: r.Status, Guests: r.Guests, GuestName: r.GuestName, Start: r.StartTime, End: r.EndTime}
+}
+
+// tableState is what is happening at a table at one moment.
+type tableState struct {
+    Status      string             `json:"status"`                // see floor* constants
+    Reservation *floorReservation  `json:"reservation,omitempty"` // the party at the table, or the one it is reset for
+    Next        *floorReservation  `json:"next,omitempty"`        // next party later that local day
+    Block       *models.TableBlock `json:"block,omitempty"`
+}
+
+// floorState works out the status of each table at at, by table ID.
+// Reservations count from their start until they are cleared; seated parties
+// that stay past their end keep the table until they leave. For a moment in
+// the past, reservations are taken in the status they had then (see
+// models.Reservation.AsOf).
+func (h *RestaurantHandler) floorState(restaurant *models.Restaurant, tables []*models.Table, at time.Time) (map[string]*tableState, error) {
+    local := at.In(restaurant.Location())
+    endOfDay := time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, local.Location())
+    now := time.Now()
+    // seen is when the reservations are looked at: now, or at if that is past
+    seen := now
+    var list []*models.Reservation
+    var err error
+    if at.Before(now) {
+        seen = at
+        list, err = h.reservationsAsOf(restaurant.ID, at, endOfDay)
+    } else {
+        list, err = h.reservations.ListOverlap(store.ReservationFilter{
+            RestaurantID: restaurant.ID,
+            StartBefore:  at,
+            EndAfter:     endOfDay,
+        })
+    }
+    if err != nil {
+        return nil, err
+    }
+    blocks, err := h.blocks.ListByRestaurant(restaurant.ID, at, at.Add(time.Second))
+    if err != nil {
+        return nil, err
+    }
+    out := map[string]*tableState{}
+    for _, t := range tables {
+        out[t.ID] = &tableState{Status: floorAvailable}
+    }
+    for _, b := range blocks {
+        if st := out[b.TableID]; st != nil {
+            st.Status, st.Block = floorBlocked, b
+        }
+    }
+    // list is sorted by start, so the first match per table wins
+    for _, res := range list {
+        for _, tid := range res.Tables() {
+            st := out[tid]
+            if st == nil {
+                continue
+            }
+            if res.StartTime.After(at) {
+                if st.Next == nil {
+                    st.Next = newFloorReservation(res)
+                }
+                continue
+            }
+            if st.Status != floorAvailable {
+                continue
+            }
+            st.Reservation = newFloorReservation(res)
+            switch {
+            case res.Status == models.StatusSeated && !seen.Before(at):
+                st.Status = floorSeated // still at the table when last seen
+            case !res.EffectiveEnd(seen).After(at):
+                st.Status = floorTurnover
+            case res.Status == models.StatusSeated:
+                st.Status = floorSeated
+            default:
+                st.Status = floorReserved
+            }
+        }
+    }
+    return out, nil
+}
+
+// reservationsAsOf is ListOverlap for [at, to) as the reservations stood at
+// a past moment at.
+func (h *RestaurantHandler) reservationsAsOf(restaurantID string, at, to time.Time) ([]*models.Reservation, error) {
+    // no reservation lasts longer than maxBookingDuration, but a seated one
+    // can overrun it; a day back finds those too
+    list, err := h.reservations.ListByRestaurant(restaurantID, at.AddDate(0, 0, -1), to)
+    if err != nil {
+        return nil, err
+    }
+    var out []*models.Reservation
+    for _, res := range list {
+        if past := res.AsOf(at); past != nil && past.Occupies(at, to, at) {
+            out = append(out, past)
+        }
+    }
+    return out, nil
+}
+
+// floorTable is one table in the floor plan state.
+type floorTable struct {
+    Table    *models.Table         `json:"table"`
+    Position *models.TablePosition `json:"position,omitempty"` // nil if the table is not on the plan
+    tableState
+}
+
+// FloorState returns the plan with every table's status at ?at= (RFC3339,
+// default now): GET /restaurants/:id/floor-plan/state.
+func (h *RestaurantHandler) FloorState(w http.ResponseWriter, r *http.Request) {
+    rid := router.Param(r, "id")
+    restaurant, err := h.restaurants.ByID(rid)
+    if err != nil {
+        notFound(w, "restaurant not found")
+        return
+    }
+    at := time.Now()
+    if v := r.URL.Query().Get("at"); v != "" {
+        if at, err = time.Parse(time.RFC3339, v); err != nil {
+            badRequest(w, "at must be RFC3339")
+            return
+        }
+    }
+    sections, err := h.floor.ListSections(rid)
+    if err != nil {
+        badRequest(w, "could not load floor plan")
+        return
+    }
+    tables, _ := h.tables.ListByRestaurant(rid)
+    positions, err := h.livePositions(rid, tables)
+    if err != nil {
+        badRequest(w, "could not load floor plan")
+        return
+    }
+    states, err := h.floorState(restaurant, tables, at)
+    if err != nil {
+        badRequest(w, "could not load reservations")
+        return
+    }
+    out := []floorTable{}
+    for _, t := range tables {
+        out = append(out, floorTable{Table: t, Position: positions[t.ID], tableState: *states[t.ID]})
+    }
+    writeJSON(w, http.StatusOK, map[string]any{"at": at, "plan": h.planFor(rid), "sections": sections, "tables": out})
+}
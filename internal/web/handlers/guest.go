@@ -0,0 +1,160 @@
+package handlers
+
+import (
+    "crypto/rand"
+    "crypto/sha256"
+    "crypto/subtle"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "net/http"
+    "strings"
+
+    "orderation/internal/models"
+    "orderation/internal/web/router"
+)
+
+// codeAlphabet leaves out characters that are easy to confuse over the phone
+// (0/O, 1/I/L).
+const codeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+
+const confirmationCodeLen = 6
+
+type createGuestReservationReq struct {
+    createReservationReq
+    Name  string `json:"name"`
+    Phone string `json:"phone"`
+    Email string `json:"email"`
+}
+
+type guestReservationResp struct {
+    *models.Reservation
+    ManageToken string `json:"manageToken"` // shown once; needed with the code to manage the booking
+}
+
+// CreateGuest books a table for a guest without an account. The response
+// carries the confirmation code and a manage token; only a hash of the token
+// is stored.
+func (h *ReservationHandler) CreateGuest(w http.ResponseWriter, r *http.Request) {
+    restaurant, err := h.restaurants.ByID(router.Param(r, "id"))
+    if err != nil {
+        notFound(w, "restaurant not found")
+        return
+    }
+    var req createGuestReservationReq
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        badRequest(w, "invalid json")
+        return
+    }
+    req.Name = strings.TrimSpace(req.Name)
+    req.Phone = normalizePhone(req.Phone)
+    req.Email = strings.ToLower(strings.TrimSpace(req.Email))
+    if req.Name == "" {
+        badRequest(w, "name required")
+        return
+    }
+    if req.Phone == "" && req.Email == "" {
+        badRequest(w, "phone or email required")
+        return
+    }
+    if req.Email != "" && !strings.Contains(req.Email, "@") {
+        badRequest(w, "invalid email")
+        return
+    }
+    code, err := h.newConfirmationCode()
+    if err != nil {
+        badRequest(w, "could not create reservation")
+        return
+    }
+    token := newToken()
+    tmpl := models.Reservation{
+        Status:           models.StatusConfirmed,
+        GuestName:        req.Name,
+        GuestPhone:       req.Phone,
+        GuestEmail:       req.Email,
+        ConfirmationCode: code,
+        ManageTokenHash:  hashToken(token),
+    }
+    if res := h.book(w, restaurant, req.createReservationReq, tmpl); res != nil {
+        writeJSON(w, http.StatusCreated, guestReservationResp{Reservation: res, ManageToken: token})
+    }
+}
+
+// normalizePhone keeps the digits of a phone number and a leading +, so the
+// same number written differently counts as one guest for booking limits.
+func normalizePhone(phone string) string {
+    phone = strings.TrimSpace(phone)
+    var b strings.Builder
+    for i, c := range phone {
+        if (c >= '0' && c <= '9') || (c == '+' && i == 0) {
+            b.WriteRune(c)
+        }
+    }
+    return b.String()
+}
+
+type lookupReq struct {
+    ConfirmationCode string `json:"confirmationCode"`
+    ManageToken      string `json:"manageToken"`
+    Reason           string `json:"reason"` // LookupCancel only
+    models.SpecialRequests                  // LookupUpdateRequests only
+}
+
+// guestReservation decodes the request body into req and returns the guest
+// booking matching its code and manage token, writing the error response
+// otherwise.
+func (h *ReservationHandler) guestReservation(w http.ResponseWriter, r *http.Request, req *lookupReq) *models.Reservation {
+    if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+        badRequest(w, "invalid json")
+        return nil
+    }
+    code := strings.ToUpper(strings.TrimSpace(req.ConfirmationCode))
+    res, err := h.reservations.ByConfirmationCode(code)
+    // the same answer for an unknown code and a wrong token
+    if err != nil || code == "" || res.ManageTokenHash == "" ||
+        subtle.ConstantTimeCompare([]byte(hashToken(req.ManageToken)), []byte(res.ManageTokenHash)) != 1 {
+        notFound(w, "reservation not found")
+        return nil
+    }
+    return res
+}
+
+// Lookup shows a guest booking: POST /reservations/lookup with
+// {"confirmationCode", "manageToken"}.
+func (h *ReservationHandler) Lookup(w http.ResponseWriter, r *http.Request) {
+    var req lookupReq
+    if res := h.guestReservation(w, r, &req); res != nil {
+        writeJSON(w, http.StatusOK, res)
+    }
+}
+
+// LookupCancel cancels a guest booking identified like Lookup, under the
+// restaurant's cancellation policy.
+func (h *ReservationHandler) LookupCancel(w http.ResponseWriter, r *http.Request) {
+    var req lookupReq
+    if res := h.guestReservation(w, r, &req); res != nil {
+        h.cancel(w, res, req.Reason, false)
+    }
+}
+
+// newConfirmationCode returns a random code not used by another reservation.
+func (h *ReservationHandler) newConfirmationCode() (string, error) {
+    b := make([]byte, confirmationCodeLen)
+    for attempt := 0; attempt < 5; attempt++ {
+        if _, err := rand.Read(b); err != nil {
+            return "", err
+        }
+        for i := range b {
+            b[i] = codeAlphabet[int(b[i])%len(codeAlphabet)]
+        }
+        if _, err := h.reservations.ByConfirmationCode(string(b)); err != nil {
+            return string(b), nil
+        }
+    }
+    return "", errors.New("no free confirmation code")
+}
+
+func hashToken(token string) string {
+    sum := sha256.Sum256([]byte(token))
+    return hex.EncodeToString(sum[:])
+}
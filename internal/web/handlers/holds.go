@@ -0,0 +1,104 @@
+package handlers
+
+import (
+    "encoding/json"
+    "errors"
+    "net/http"
+    "time"
+
+    "orderation/internal/models"
+    "orderation/internal/store"
+    "orderation/internal/web/middleware"
+    "orderation/internal/web/router"
+)
+
+// holdTTL is how long a checkout hold keeps its table.
+const holdTTL = 5 * time.Minute
+
+// CreateHold keeps a table for the caller while they finish booking. It takes
+// the same body as Create and returns a reservation in status held whose ID is
+// passed back to Create as holdId before holdExpiresAt.
+func (h *ReservationHandler) CreateHold(w http.ResponseWriter, r *http.Request) {
+    restaurant, err := h.restaurants.ByID(router.Param(r, "id"))
+    if err != nil {
+        notFound(w, "restaurant not found")
+        return
+    }
+    claims := middleware.ClaimsFromContext(r)
+    if claims == nil {
+        unauthorized(w, "no auth")
+        return
+    }
+    var req createReservationReq
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        badRequest(w, "invalid json")
+        return
+    }
+    expires := time.Now().Add(holdTTL)
+    if res := h.book(w, restaurant, req, models.Reservation{UserID: claims.Sub, Status: models.StatusHeld, HoldExpiresAt: &expires}); res != nil {
+        writeJSON(w, http.StatusCreated, res)
+    }
+}
+
+// ReleaseHold gives up a hold before it expires.
+func (h *ReservationHandler) ReleaseHold(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.ClaimsFromContext(r)
+    if claims == nil {
+        unauthorized(w, "no auth")
+        return
+    }
+    res, err := h.reservations.ByID(router.Param(r, "id"))
+    if err != nil || res.Status != models.StatusHeld {
+        notFound(w, "hold not found")
+        return
+    }
+    if claims.Role != "admin" && res.UserID != claims.Sub {
+        forbidden(w, "not allowed")
+        return
+    }
+    if err := h.reservations.DeleteHold(res.ID); err != nil {
+        notFound(w, "hold not found")
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// confirmHold turns userID's hold into a confirmed reservation with the
+// special requests given at checkout, moving it to an accessible table if
+// they need one.
+func (h *ReservationHandler) confirmHold(w http.ResponseWriter, userID, restaurantID, holdID string, sr models.SpecialRequests) {
+    res, err := h.reservations.ByID(holdID)
+    if err != nil || res.Status != models.StatusHeld || res.RestaurantID != restaurantID || res.UserID != userID {
+        notFound(w, "hold not found")
+        return
+    }
+    if err := sr.Normalize(); err != nil {
+        badRequest(w, err.Error())
+        return
+    }
+    if e := h.reseat(res, sr); e != nil {
+        e.write(w)
+        return
+    }
+    if err := h.reservations.UpdateStatus(res.ID, models.StatusConfirmed, time.Now()); err != nil {
+        var te *store.TransitionError
+        if errors.Is(err, store.ErrHoldExpired) || errors.As(err, &te) {
+            conflict(w, "hold has expired")
+            return
+        }
+        badRequest(w, "could not confirm hold")
+        return
+    }
+    if !sr.Empty() {
+        if err := h.reservations.UpdateSpecialRequests(res.ID, sr); err != nil {
+            badRequest(w, "could not update reservation")
+            return
+        }
+    }
+    res, err = h.reservations.ByID(holdID)
+    if err != nil {
+        notFound(w, "reservation not found")
+        return
+    }
+    writeJSON(w, http.StatusCreated, res)
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+    "encoding/json"
+    "net/http"
+    "strings"
+    "time"
+
+    "orderation/internal/models"
+    "orderation/internal/store"
+    "orderation/internal/web/router"
+)
+
+type HoursHandler struct {
+    restaurants store.RestaurantStore
+    hours       store.HoursStore
+    exceptions  store.ExceptionStore
+}
+
+func NewHoursHandler(rest store.RestaurantStore, hours store.HoursStore, exceptions store.ExceptionStore) *HoursHandler {
+    return &HoursHandler{restaurants: rest, hours: hours, exceptions: exceptions}
+}
+
+type servicePeriodReq struct {
+    Weekday   *int   `json:"weekday"`
+    Name      string `json:"name"`
+    OpenTime  string `json:"openTime"`
+    CloseTime string `json:"closeTime"`
+}
+
+// apply validates the request and copies it onto p, returning an error
+// message when the request is invalid.
+func (req *servicePeriodReq) apply(p *models.ServicePeriod) string {
+    if req.Weekday == nil || *req.Weekday < 0 || *req.Weekday > 6 {
+        return "weekday must be 0 (Sunday) to 6 (Saturday)"
+    }
+    req.OpenTime = strings.TrimSpace(req.OpenTime)
+    req.CloseTime = strings.TrimSpace(req.CloseTime)
+    if _, _, err := parseTime(req.OpenTime); err != nil {
+        return "openTime must be HH:MM"
+    }
+    if _, _, err := parseTime(req.CloseTime); err != nil {
+        return "closeTime must be HH:MM"
+    }
+    p.Weekday = time.Weekday(*req.Weekday)
+    p.Name = strings.TrimSpace(req.Name)
+    p.OpenTime = req.OpenTime
+    p.CloseTime = req.CloseTime
+    return ""
+}
+
+func (h *HoursHandler) List(w http.ResponseWriter, r *http.Request) {
+    rid := router.Param(r, "id")
+    if _, err := h.restaurants.ByID(rid); err != nil {
+        notFound(w, "restaurant not found")
+        return
+    }
+    list, _ := h.hours.ListByRestaurant(rid)
+    writeJSON(w, http.StatusOK, list)
+}
+
+func (h *HoursHandler) Create(w http.ResponseWriter, r *http.Request) {
+    rid := router.Param(r, "id")
+    if _, err := h.restaurants.ByID(rid); err != nil {
+        notFound(w, "restaurant not found")
+        return
+    }
+    var req servicePeriodReq
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        badRequest(w, "invalid json")
+        return
+    }
+    p := &models.ServicePeriod{RestaurantID: rid}
+    if msg := req.apply(p); msg != "" {
+        badRequest(w, msg)
+        return
+    }
+    if err := h.hours.Create(p); err != nil {
+        badRequest(w, "could not create service period")
+        return
+    }
+    writeJSON(w, http.StatusCreated, p)
+}
+
+func (h *HoursHandler) Update(w http.ResponseWriter, r *http.Request) {
+    p, ok := h.period(w, r)
+    if !ok {
+        return
+    }
+    var req servicePeriodReq
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        badRequest(w, "invalid json")
+        return
+    }
+    updated := *p
+    if msg := req.apply(&updated); msg != "" {
+        badRequest(w, msg)
+        return
+    }
+    if err := h.hours.Update(&updated); err != nil {
+        badRequest(w, "could not update service period")
+        return
+    }
+    writeJSON(w, http.StatusOK, &updated)
+}
+
+func (h *HoursHandler) Delete(w http.ResponseWriter, r *http.Request) {
+    p, ok := h.period(w, r)
+    if !ok {
+        return
+    }
+    if err := h.hours.Delete(p.ID); err != nil {
+        badRequest(w, "could not delete service period")
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// period loads the service period named in the URL and checks it belongs to
+// the restaurant in the URL.
+func (h *HoursHandler) period(w http.ResponseWriter, r *http.Request) (*models.ServicePeriod, bool) {
+    p, err := h.hours.ByID(router.Param(r, "periodId"))
+    if err != nil || p.RestaurantID != router.Param(r, "id") {
+        notFound(w, "service period not found")
+        return nil, false
+    }
+    return p, true
+}
+
+type hoursExceptionReq struct {
+    Date      string `json:"date"`
+    Closed    bool   `json:"closed"`
+    OpenTime  string `json:"openTime"`
+    CloseTime string `json:"closeTime"`
+    Note      string `json:"note"`
+}
+
+// ListExceptions returns upcoming exceptions, starting today in the
+// restaurant's time zone unless ?from= is given. ?to= bounds the range.
+func (h *HoursHandler) ListExceptions(w http.ResponseWriter, r *http.Request) {
+    rid := router.Param(r, "id")
+    rest, err := h.restaurants.ByID(rid)
+    if err != nil {
+        notFound(w, "restaurant not found")
+        return
+    }
+    from := r.URL.Query().Get("from")
+    if from == "" {
+        from = time.Now().In(rest.Location()).Format(dateLayout)
+    } else if _, err := time.Parse(dateLayout, from); err != nil {
+        badRequest(w, "from must be YYYY-MM-DD")
+        return
+    }
+    to := r.URL.Query().Get("to")
+    if to != "" {
+        if _, err := time.Parse(dateLayout, to); err != nil {
+            badRequest(w, "to must be YYYY-MM-DD")
+            return
+        }
+    }
+    list, _ := h.exceptions.ListByRestaurant(rid, from, to)
+    writeJSON(w, http.StatusOK, list)
+}
+
+func (h *HoursHandler) CreateException(w http.ResponseWriter, r *http.Request) {
+    rid := router.Param(r, "id")
+    if _, err := h.restaurants.ByID(rid); err != nil {
+        notFound(w, "restaurant not found")
+        return
+    }
+    var req hoursExceptionReq
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        badRequest(w, "invalid json")
+        return
+    }
+    req.Date = strings.TrimSpace(req.Date)
+    if _, err := time.Parse(dateLayout, req.Date); err != nil {
+        badRequest(w, "date must be YYYY-MM-DD")
+        return
+    }
+    e := &models.HoursException{RestaurantID: rid, Date: req.Date, Closed: req.Closed, Note: strings.TrimSpace(req.Note)}
+    if !req.Closed {
+        e.OpenTime = strings.TrimSpace(req.OpenTime)
+        e.CloseTime = strings.TrimSpace(req.CloseTime)
+        if _, _, err := parseTime(e.OpenTime); err != nil {
+            badRequest(w, "openTime must be HH:MM unless closed")
+            return
+        }
+        if _, _, err := parseTime(e.CloseTime); err != nil {
+            badRequest(w, "closeTime must be HH:MM unless closed")
+            return
+        }
+    }
+    if err := h.exceptions.Create(e); err != nil {
+        conflict(w, "an exception already exists for this date")
+        return
+    }
+    writeJSON(w, http.StatusCreated, e)
+}
+
+func (h *HoursHandler) DeleteException(w http.ResponseWriter, r *http.Request) {
+    e, err := h.exceptions.ByID(router.Param(r, "exceptionId"))
+    if err != nil || e.RestaurantID != router.Param(r, "id") {
+        notFound(w, "exception not found")
+        return
+    }
+    if err := h.exceptions.Delete(e.ID); err != nil {
+        badRequest(w, "could not delete exception")
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
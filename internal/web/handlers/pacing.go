@@ -0,0 +1,110 @@
+package handlers
+
+import (
+    "net/http"
+    "time"
+
+    "orderation/internal/models"
+    "orderation/internal/store"
+)
+
+// codePacingLimit is the error code of a booking refused by pacing rules.
+const codePacingLimit = "pacing_limit_reached"
+
+// pacing checks new bookings against the restaurant's pacing rules, using
+// the start counts loaded once for a time range.
+type pacing struct {
+    rules  models.PacingRules
+    sched  *schedule // to find the service period; nil without period overrides
+    starts []store.StartCount
+}
+
+// loadPacing loads what is needed to check starts in [from, to) against the
+// restaurant's pacing rules, ignoring excludeID (if set).
+func (h *ReservationHandler) loadPacing(restaurant *models.Restaurant, from, to time.Time, excludeID string) (*pacing, error) {
+    p := &pacing{rules: restaurant.Settings.Pacing}
+    if !p.rules.Enabled() {
+        return p, nil
+    }
+    window := p.rules.Interval()
+    starts, err := h.reservations.CountStarts(restaurant.ID, from.Add(-window), to.Add(window), excludeID)
+    if err != nil {
+        return nil, err
+    }
+    p.starts = starts
+    if len(p.rules.Periods) > 0 {
+        if p.sched, err = loadSchedule(h.hours, h.exceptions, restaurant, from, to); err != nil {
+            return nil, err
+        }
+    }
+    return p, nil
+}
+
+// pacingError returns a 409 if a party of guests starting at start would
+// break the restaurant's pacing rules, or nil.
+func (h *ReservationHandler) pacingError(restaurant *models.Restaurant, start, end time.Time, guests int, excludeID string) *bookingError {
+    p, err := h.loadPacing(restaurant, start, end, excludeID)
+    if err != nil {
+        return &bookingError{status: http.StatusBadRequest, msg: "could not load reservations"}
+    }
+    if !p.allows(start, guests) {
+        return &bookingError{status: http.StatusConflict, code: codePacingLimit, msg: "the kitchen is fully booked for starts around this time; please choose another time"}
+    }
+    return nil
+}
+
+// allows reports whether a party of guests can start at start without any
+// pacing window that contains it going over the limits.
+func (p *pacing) allows(start time.Time, guests int) bool {
+    if !p.rules.Enabled() {
+        return true
+    }
+    period := ""
+    if p.sched != nil {
+        if sp := p.sched.periodAt(start); sp != nil {
+            period = sp.Name
+        }
+    }
+    maxGuests, maxParties := p.rules.LimitsFor(period)
+    if maxGuests <= 0 && maxParties <= 0 {
+        return true
+    }
+    window := p.rules.Interval()
+    // The busiest window containing start begins at start itself or at an
+    // earlier start less than one window before it.
+    candidates := []time.Time{start}
+    for _, c := range p.starts {
+        if c.Start.After(start.Add(-window)) && c.Start.Before(start) {
+            candidates = append(candidates, c.Start)
+        }
+    }
+    for _, from := range candidates {
+        to := from.Add(window)
+        parties, total := 1, guests
+        for _, c := range p.starts {
+            if !c.Start.Before(from) && c.Start.Before(to) {
+                parties += c.Parties
+                total += c.Guests
+            }
+        }
+        if maxParties > 0 && parties > maxParties || maxGuests > 0 && total > maxGuests {
+            return false
+        }
+    }
+    return true
+}
+
+func validatePacing(p models.PacingRules) string {
+    if p.IntervalMinutes < 0 || p.IntervalMinutes > 120 {
+        return "pacing.intervalMinutes must be between 0 and 120"
+    }
+    if p.MaxGuests < 0 || p.MaxParties < 0 {
+        return "pacing limits must be >= 0"
+    }
+    for _, o := range p.Periods {
+        if o.Period == "" || o.MaxGuests < 0 || o.MaxParties < 0 {
+            return "pacing.periods need a period name and limits >= 0"
+        }
+    }
+    return ""
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+    "testing"
+    "time"
+
+    "orderation/internal/models"
+    "orderation/internal/store"
+)
+
+func TestPacingAllows(t *testing.T) {
+    loc := time.UTC
+    // 2026-03-03 is a Tuesday
+    at := func(hour, min int) time.Time { return time.Date(2026, 3, 3, hour, min, 0, 0, loc) }
+    p := &pacing{
+        rules: models.PacingRules{MaxParties: 2, MaxGuests: 10, Periods: []models.PeriodPacing{{Period: "dinner", MaxParties: 1}}},
+        sched: &schedule{loc: loc, periods: []*models.ServicePeriod{
+            {Weekday: time.Tuesday, Name: "lunch", OpenTime: "11:00", CloseTime: "15:00"},
+            {Weekday: time.Tuesday, Name: "Dinner", OpenTime: "18:00", CloseTime: "22:00"},
+        }},
+        starts: []store.StartCount{
+            {Start: at(12, 0), Parties: 1, Guests: 4},
+            {Start: at(12, 10), Parties: 1, Guests: 4},
+            {Start: at(19, 0), Parties: 1, Guests: 2},
+        },
+    }
+    cases := []struct {
+        name   string
+        start  time.Time
+        guests int
+        want   bool
+    }{
+        {"window already has two parties", at(12, 5), 2, false},
+        {"window from 12:10 has one party", at(12, 20), 2, true},
+        {"guest limit", at(12, 20), 7, false},
+        {"clear of earlier starts", at(12, 30), 8, true},
+        {"dinner override", at(19, 10), 2, false},
+        {"dinner after window", at(19, 15), 2, true},
+    }
+    for _, c := range cases {
+        if got := p.allows(c.start, c.guests); got != c.want {
+            t.Errorf("%s: allows = %v, want %v", c.name, got, c.want)
+        }
+    }
+}
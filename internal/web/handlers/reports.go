@@ -0,0 +1,203 @@
+package handlers
+
+import (
+    "math"
+    "net/http"
+    "strings"
+    "time"
+
+    "orderation/internal/models"
+    "orderation/internal/store"
+    "orderation/internal/web/router"
+)
+
+// usage compares booked and open table time in one slice of a report.
+type usage struct {
+    BookedTableMinutes int     `json:"bookedTableMinutes"`
+    OpenTableMinutes   int     `json:"openTableMinutes"`
+    Utilization        float64 `json:"utilization"` // percent
+}
+
+type hourUsage struct {
+    Hour int `json:"hour"` // local hour of day, 0-23
+    usage
+}
+
+type weekdayUsage struct {
+    Weekday string `json:"weekday"` // monday to sunday
+    usage
+}
+
+// capacityWaste counts the seats left empty at the tables booked parties
+// were given.
+type capacityWaste struct {
+    Parties    int     `json:"parties"`
+    Seats      int     `json:"seats"` // capacity of their tables or combinations
+    Guests     int     `json:"guests"`
+    EmptySeats int     `json:"emptySeats"`
+    Rate       float64 `json:"rate"` // percent of seats left empty
+}
+
+type occupancyReport struct {
+    From             string         `json:"from"`             // local dates, inclusive
+    To               string         `json:"to"`
+    Reservations     int            `json:"reservations"`     // in any status but held
+    AveragePartySize float64        `json:"averagePartySize"` // of booked parties
+    AverageLeadHours float64        `json:"averageLeadHours"` // from booking to start, booked parties
+    CancellationRate float64        `json:"cancellationRate"` // percent of reservations
+    NoShowRate       float64        `json:"noShowRate"`       // percent of parties due: seated, completed or no-show
+    ByHour           []hourUsage    `json:"byHour"`
+    ByWeekday        []weekdayUsage `json:"byWeekday"`
+    CapacityWaste    capacityWaste  `json:"capacityWaste"`
+}
+
+// add counts minutes of table time in the slice.
+func (u *usage) add(booked, open int) {
+    u.BookedTableMinutes += booked
+    u.OpenTableMinutes += open
+}
+
+// weekdayIndex numbers the days from Monday, as reports list them.
+func weekdayIndex(d time.Weekday) int {
+    return (int(d) + 6) % 7
+}
+
+// eachHour calls f with the parts of [start, end) that fall in each hour of
+// the local time in loc, with the part's start in loc.
+func eachHour(start, end time.Time, loc *time.Location, f func(start, end time.Time)) {
+    for start = start.In(loc); start.Before(end); {
+        next := earliestTime(store.HourStart(start).Add(time.Hour), end)
+        f(start, next)
+        start = next.In(loc)
+    }
+}
+
+// OccupancyReport analyses the reservations starting between the local dates
+// ?from= and ?to= (YYYY-MM-DD, inclusive; default this week): table
+// utilization by hour of day and day of week, party size, lead time,
+// cancellation and no-show rates, and seats left empty at booked tables.
+func (h *RestaurantHandler) OccupancyReport(w http.ResponseWriter, r *http.Request) {
+    restaurant, err := h.restaurants.ByID(router.Param(r, "id"))
+    if err != nil {
+        notFound(w, "restaurant not found")
+        return
+    }
+    from, to, ok := dateRange(w, r, restaurant, time.Now())
+    if !ok {
+        return
+    }
+    loc := restaurant.Location()
+    rep := occupancyReport{From: from.Format(dateLayout), To: to.AddDate(0, 0, -1).Format(dateLayout)}
+
+    t, err := h.countRange(restaurant.ID, from, to)
+    if err != nil {
+        internalError(w, "could not count reservations")
+        return
+    }
+    rep.Reservations = t.total - t.byStatus[models.StatusHeld]
+    rep.CancellationRate = percent(t.byStatus[models.StatusCancelled], rep.Reservations)
+    due := t.byStatus[models.StatusSeated] + t.byStatus[models.StatusCompleted] + t.byStatus[models.StatusNoShow]
+    rep.NoShowRate = percent(t.byStatus[models.StatusNoShow], due)
+
+    lead, err := h.reservations.LeadTime(restaurant.ID, from, to)
+    if err != nil {
+        internalError(w, "could not count reservations")
+        return
+    }
+    if lead.Parties > 0 {
+        rep.AverageLeadHours = math.Round(float64(lead.Minutes)/60/float64(lead.Parties)*10) / 10
+    }
+
+    tables, err := h.tables.ListAllByRestaurant(restaurant.ID)
+    if err != nil {
+        internalError(w, "could not load tables")
+        return
+    }
+    sched, err := loadSchedule(h.hours, h.exceptions, restaurant, from, to)
+    if err != nil {
+        internalError(w, "could not load opening hours")
+        return
+    }
+    hours := make([]hourUsage, 24)
+    days := make([]weekdayUsage, 7)
+    for i := range hours {
+        hours[i].Hour = i
+    }
+    for i := range days {
+        days[i].Weekday = strings.ToLower(time.Weekday((i + 1) % 7).String())
+    }
+    for _, iv := range sched.intervals(from, to) {
+        eachHour(latestTime(iv.start, from), earliestTime(iv.end, to), loc, func(start, end time.Time) {
+            open := openTableMinutes(tables, start, end)
+            hours[start.Hour()].add(0, open)
+            days[weekdayIndex(start.Weekday())].add(0, open)
+        })
+    }
+    booked, err := h.reservations.HourlyTableMinutes(restaurant.ID, from, to, loc)
+    if err != nil {
+        internalError(w, "could not count reservations")
+        return
+    }
+    for _, c := range booked {
+        local := c.Hour.In(loc)
+        hours[local.Hour()].add(c.TableMinutes, 0)
+        days[weekdayIndex(local.Weekday())].add(c.TableMinutes, 0)
+    }
+    for i := range hours {
+        hours[i].Utilization = percent(hours[i].BookedTableMinutes, hours[i].OpenTableMinutes)
+    }
+    for i := range days {
+        days[i].Utilization = percent(days[i].BookedTableMinutes, days[i].OpenTableMinutes)
+    }
+    rep.ByHour, rep.ByWeekday = hours, days
+
+    seatings, err := h.reservations.CountBySeating(restaurant.ID, from, to)
+    if err != nil {
+        internalError(w, "could not count reservations")
+        return
+    }
+    waste := &rep.CapacityWaste
+    for _, s := range seatings {
+        waste.Parties += s.Parties
+        waste.Guests += s.Guests
+        seats := h.seatsFor(s.TableID, s.CombinationID) * s.Parties
+        waste.Seats += seats
+        if seats > s.Guests {
+            waste.EmptySeats += seats - s.Guests
+        }
+    }
+    waste.Rate = percent(waste.EmptySeats, waste.Seats)
+    if waste.Parties > 0 {
+        rep.AveragePartySize = math.Round(float64(waste.Guests)/float64(waste.Parties)*10) / 10
+    }
+    writeJSON(w, http.StatusOK, rep)
+}
+
+// seatsFor is the capacity of a combination, or of the table if there is no
+// combination or it has been removed. Deleted tables keep their capacity;
+// unknown tables count as 0.
+func (h *RestaurantHandler) seatsFor(tableID, combinationID string) int {
+    if combinationID != "" {
+        if c, err := h.combinations.ByID(combinationID); err == nil {
+            return c.Capacity
+        }
+    }
+    if t, err := h.tables.ByID(tableID); err == nil {
+        return t.Capacity
+    }
+    return 0
+}
+
+func latestTime(a, b time.Time) time.Time {
+    if a.After(b) {
+        return a
+    }
+    return b
+}
+
+func earliestTime(a, b time.Time) time.Time {
+    if a.Before(b) {
+        return a
+    }
+    return b
+}
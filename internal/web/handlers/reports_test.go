@@ -0,0 +1,30 @@
+package handlers
+
+import (
+    "testing"
+    "time"
+)
+
+func TestEachHourSplitsByLocalHour(t *testing.T) {
+    loc, err := time.LoadLocation("Asia/Kolkata") // UTC+5:30
+    if err != nil {
+        t.Skip("tzdata not available")
+    }
+    start := time.Date(2026, 3, 3, 19, 15, 0, 0, loc).UTC()
+    type part struct {
+        hour, minutes int
+    }
+    var got []part
+    eachHour(start, start.Add(105*time.Minute), loc, func(s, e time.Time) {
+        got = append(got, part{s.Hour(), int(e.Sub(s) / time.Minute)})
+    })
+    want := []part{{19, 45}, {20, 60}}
+    if len(got) != len(want) {
+        t.Fatalf("expected %v, got %v", want, got)
+    }
+    for i := range want {
+        if got[i] != want[i] {
+            t.Fatalf("part %d: expected %v, got %v", i, want[i], got[i])
+        }
+    }
+}
@@ -2,10 +2,10 @@ package handlers
 
 import (
     "encoding/json"
+    "errors"
+    "io"
     "net/http"
     "sort"
-    "strconv"
-    "strings"
     "time"
 
     "orderation/internal/models"
@@ -14,196 +14,138 @@ import (
     "orderation/internal/web/router"
 )
 
+// maxBookingDuration is the longest a reservation can last, as long as the
+// longest dining time the settings allow.
+const maxBookingDuration = 12 * time.Hour
+
+// msgTooLong is the error for a reservation longer than maxBookingDuration.
+const msgTooLong = "a reservation can last at most 12 hours"
+
 type ReservationHandler struct {
     reservations store.ReservationStore
     restaurants  store.RestaurantStore
     tables       store.TableStore
     users        store.UserStore
+    hours        store.HoursStore
+    exceptions   store.ExceptionStore
+    combinations store.TableCombinationStore
+    waitlist     store.WaitlistStore
+    series       store.SeriesStore
+    blocks       store.TableBlockStore
+    limits       BookingLimits
+}
+
+func NewReservationHandler(res store.ReservationStore, rest store.RestaurantStore, tables store.TableStore, users store.UserStore, hours store.HoursStore, exceptions store.ExceptionStore, combinations store.TableCombinationStore, waitlist store.WaitlistStore, series store.SeriesStore, blocks store.TableBlockStore, limits BookingLimits) *ReservationHandler {
+    return &ReservationHandler{reservations: res, restaurants: rest, tables: tables, users: users, hours: hours, exceptions: exceptions, combinations: combinations, waitlist: waitlist, series: series, blocks: blocks, limits: limits}
 }
 
-func NewReservationHandler(res store.ReservationStore, rest store.RestaurantStore, tables store.TableStore, users store.UserStore) *ReservationHandler {
-    return &ReservationHandler{reservations: res, restaurants: rest, tables: tables, users: users}
+// isWithinOperatingHours checks the reservation against the restaurant's weekly
+// schedule and any holiday or special-hours exceptions
+func (h *ReservationHandler) isWithinOperatingHours(restaurant *models.Restaurant, start, end time.Time) bool {
+    sched, err := loadSchedule(h.hours, h.exceptions, restaurant, start, end)
+    if err != nil {
+        return false
+    }
+    return sched.covers(start, end)
 }
 
 type availabilityReq struct {
-    Start  time.Time `json:"start"`
-    End    time.Time `json:"end"`
-    Guests int       `json:"guests"`
+    Start      time.Time `json:"start"`
+    End        time.Time `json:"end"` // optional; defaults to the restaurant's dining time
+    Guests     int       `json:"guests"`
+    Accessible bool      `json:"accessible"` // only wheelchair-accessible tables
 }
 
 type availabilityResp struct {
-    TableID  string `json:"tableId"`
-    Capacity int    `json:"capacity"`
+    TableID       string        `json:"tableId,omitempty"`
+    TableIDs      []string      `json:"tableIds,omitempty"`
+    CombinationID string        `json:"combinationId,omitempty"`
+    Capacity      int           `json:"capacity"`
+    Table         *models.Table `json:"table,omitempty"` // the single table, for its section and features
 }
 
 func (h *ReservationHandler) Availability(w http.ResponseWriter, r *http.Request) {
     rid := router.Param(r, "id")
-    if _, err := h.restaurants.ByID(rid); err != nil {
+    restaurant, err := h.restaurants.ByID(rid)
+    if err != nil {
         notFound(w, "restaurant not found")
         return
     }
+    if e := archivedError(restaurant); e != nil {
+        e.write(w)
+        return
+    }
     var req availabilityReq
     if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
         badRequest(w, "invalid json")
         return
     }
+    if req.End.IsZero() && req.Guests > 0 {
+        req.End = req.Start.Add(restaurant.Settings.DurationFor(req.Guests))
+    }
     if !req.End.After(req.Start) || req.Guests <= 0 {
         badRequest(w, "invalid time range or guests")
         return
     }
-    tables, _ := h.tables.ListByRestaurant(rid)
-    // sort by capacity asc
-    sort.Slice(tables, func(i, j int) bool { return tables[i].Capacity < tables[j].Capacity })
-    available := []availabilityResp{}
-    for _, t := range tables {
-        if t.Capacity < req.Guests {
-            continue
-        }
-        overlaps, _ := h.reservations.ListOverlap(store.ReservationFilter{
-            RestaurantID: rid,
-            TableID:      t.ID,
-            StartBefore:  req.Start,
-            EndAfter:     req.End,
-        })
-        if len(overlaps) == 0 {
-            available = append(available, availabilityResp{TableID: t.ID, Capacity: t.Capacity})
-        }
+    if req.End.Sub(req.Start) > maxBookingDuration {
+        badRequest(w, msgTooLong)
+        return
     }
-    writeJSON(w, http.StatusOK, available)
-}
-
-// isWithinOperatingHours checks if the reservation time is within restaurant operating hours
-func (h *ReservationHandler) isWithinOperatingHours(restaurant *models.Restaurant, start, end time.Time) bool {
-    // Parse operating hours (format: "09:00")
-    openHour, openMin, err := parseTime(restaurant.OpenTime)
-    if err != nil {
-        return false
+    if !enforceBookingRules(w, restaurant, req.Start, req.Guests) {
+        return
     }
-    closeHour, closeMin, err := parseTime(restaurant.CloseTime)
-    if err != nil {
-        return false
+    if !h.isWithinOperatingHours(restaurant, req.Start, req.End) {
+        badRequest(w, "requested time is outside restaurant operating hours")
+        return
     }
-    
-    // Convert UTC times to local time (assuming restaurant operates in Asia/Shanghai timezone)
-    loc, err := time.LoadLocation("Asia/Shanghai")
+    // a start the kitchen cannot take has no available tables
+    pace, err := h.loadPacing(restaurant, req.Start, req.End, "")
     if err != nil {
-        // Fallback to UTC+8 if timezone loading fails
-        loc = time.FixedZone("CST", 8*3600)
+        badRequest(w, "could not load reservations")
+        return
     }
-    
-    localStart := start.In(loc)
-    localEnd := end.In(loc)
-    
-    // Get the date and time components in local time
-    startDate := localStart.Truncate(24 * time.Hour)
-    endDate := localEnd.Truncate(24 * time.Hour)
-    
-    // Check each day of the reservation in local time
-    for date := startDate; !date.After(endDate); date = date.Add(24 * time.Hour) {
-        // Create operating hours for this specific date in the local timezone
-        openTime := time.Date(date.Year(), date.Month(), date.Day(), openHour, openMin, 0, 0, loc)
-        closeTime := time.Date(date.Year(), date.Month(), date.Day(), closeHour, closeMin, 0, 0, loc)
-        
-        // Handle overnight hours (e.g., 22:00 - 02:00)
-        if closeTime.Before(openTime) {
-            closeTime = closeTime.Add(24 * time.Hour)
-        }
-        
-        // Check if reservation overlaps with this day's operating hours
-        dayStart := localStart
-        if localStart.Before(date) {
-            dayStart = date
-        }
-        dayEnd := localEnd
-        if localEnd.After(date.Add(24*time.Hour)) {
-            dayEnd = date.Add(24 * time.Hour)
-        }
-        
-        // If there's any part of the reservation on this day
-        if dayStart.Before(dayEnd) {
-            // Check if this part is within operating hours
-            if dayStart.Before(openTime) || dayEnd.After(closeTime) {
-                return false // Any part outside operating hours means rejection
-            }
-        }
+    if !pace.allows(req.Start, req.Guests) {
+        writeJSON(w, http.StatusOK, []availabilityResp{})
+        return
     }
-    
-    return true
-}
-
-// findBestAvailableTable finds the most suitable available table using smart allocation
-func (h *ReservationHandler) findBestAvailableTable(restaurantID string, start, end time.Time, guests int) *models.Table {
-    tables, err := h.tables.ListByRestaurant(restaurantID)
+    tables, _ := h.tables.ListByRestaurant(rid)
+    // sort by capacity asc
+    sort.Slice(tables, func(i, j int) bool { return tables[i].Capacity < tables[j].Capacity })
+    occ, err := h.loadOccupancy(restaurant, req.Start, req.End, "")
     if err != nil {
-        return nil
+        badRequest(w, "could not load reservations")
+        return
     }
-    
-    var availableTables []*models.Table
-    
-    // First, find all available tables that can accommodate the guests
+    needs := seatingNeeds{accessible: req.Accessible}
+    available := []availabilityResp{}
     for _, t := range tables {
-        if t.Capacity < guests {
+        if t.Capacity < req.Guests || !needs.admits(t) {
             continue
         }
-        
-        overlaps, _ := h.reservations.ListOverlap(store.ReservationFilter{
-            RestaurantID: restaurantID,
-            TableID:      t.ID,
-            StartBefore:  start,
-            EndAfter:     end,
-        })
-        
-        if len(overlaps) == 0 {
-            availableTables = append(availableTables, t)
+        if occ.free(t.ID, req.Start, req.End) {
+            available = append(available, availabilityResp{TableID: t.ID, Capacity: t.Capacity, Table: t})
         }
     }
-    
-    if len(availableTables) == 0 {
-        return nil
-    }
-    
-    // Smart allocation strategy:
-    // 1. Prefer tables with capacity closest to guest count (minimize waste)
-    // 2. If multiple tables have same capacity, choose randomly
-    sort.Slice(availableTables, func(i, j int) bool {
-        // Sort by capacity difference from guest count (ascending)
-        diffI := availableTables[i].Capacity - guests
-        diffJ := availableTables[j].Capacity - guests
-        if diffI != diffJ {
-            return diffI < diffJ
+    // Offer joinable tables only when no single table seats the party.
+    if len(available) == 0 {
+        combos, _ := h.combinations.ListByRestaurant(rid)
+        for _, c := range combos {
+            if c.Capacity >= req.Guests && len(c.TableIDs) > 0 && occ.freeAll(c.TableIDs, req.Start, req.End) && h.admitsAll(needs, c.TableIDs) {
+                available = append(available, availabilityResp{TableIDs: c.TableIDs, CombinationID: c.ID, Capacity: c.Capacity})
+            }
         }
-        // If same difference, sort by table ID for deterministic behavior
-        return availableTables[i].ID < availableTables[j].ID
-    })
-    
-    return availableTables[0]
-}
-
-// parseTime parses time string in "HH:MM" format
-func parseTime(timeStr string) (hour, minute int, err error) {
-    parts := strings.Split(timeStr, ":")
-    if len(parts) != 2 {
-        return 0, 0, err
     }
-    
-    hour, err = strconv.Atoi(parts[0])
-    if err != nil {
-        return 0, 0, err
-    }
-    
-    minute, err = strconv.Atoi(parts[1])
-    if err != nil {
-        return 0, 0, err
-    }
-    
-    return hour, minute, nil
+    writeJSON(w, http.StatusOK, available)
 }
 
 type createReservationReq struct {
-    Start  time.Time `json:"start"`
-    End    time.Time `json:"end"`
-    Guests int       `json:"guests"`
-    Table  string    `json:"tableId"`
+    Start       time.Time `json:"start"`
+    End         time.Time `json:"end"` // optional; defaults to the restaurant's dining time
+    Guests      int       `json:"guests"`
+    Table       string    `json:"tableId"`
+    Combination string    `json:"combinationId"`
+    HoldID      string    `json:"holdId"` // confirm this hold instead of booking anew
+    models.SpecialRequests
 }
 
 func (h *ReservationHandler) Create(w http.ResponseWriter, r *http.Request) {
@@ -218,73 +160,429 @@ func (h *ReservationHandler) Create(w http.ResponseWriter, r *http.Request) {
         badRequest(w, "invalid json")
         return
     }
-    if !req.End.After(req.Start) || req.Guests <= 0 {
-        badRequest(w, "invalid time range or guests")
+    claims := middleware.ClaimsFromContext(r)
+    if claims == nil {
+        unauthorized(w, "no auth")
+        return
+    }
+    if req.HoldID != "" {
+        h.confirmHold(w, claims.Sub, rid, req.HoldID, req.SpecialRequests)
         return
     }
+    if res := h.book(w, restaurant, req, models.Reservation{UserID: claims.Sub, Status: models.StatusConfirmed}); res != nil {
+        writeJSON(w, http.StatusCreated, res)
+    }
+}
+
+// bookingError is why a booking or change cannot be made, with the status
+// and body to report it.
+type bookingError struct {
+    status     int
+    code       string // machine-readable, empty for plain validation errors
+    msg        string
+    violations []ruleViolation
+}
+
+func (e *bookingError) write(w http.ResponseWriter) {
+    body := map[string]any{"error": e.msg}
+    if e.code != "" {
+        body["code"] = e.code
+    }
+    if e.violations != nil {
+        body["violations"] = e.violations
+    }
+    writeJSON(w, e.status, body)
+}
+
+// archivedError refuses new bookings at an archived restaurant; existing
+// ones can still be changed and cancelled.
+func archivedError(restaurant *models.Restaurant) *bookingError {
+    if !restaurant.Archived() {
+        return nil
+    }
+    return &bookingError{status: http.StatusConflict, code: "restaurant_archived", msg: "restaurant is archived and takes no new bookings"}
+}
+
+// book validates the request, allocates tables and stores a reservation
+// built from tmpl (owner, status and contact details). It writes the error
+// response and returns nil when the booking cannot be made.
+func (h *ReservationHandler) book(w http.ResponseWriter, restaurant *models.Restaurant, req createReservationReq, tmpl models.Reservation) *models.Reservation {
+    res, e := h.place(restaurant, req, tmpl)
+    if e != nil {
+        e.write(w)
+        return nil
+    }
+    return res
+}
+
+// place is book without writing a response.
+func (h *ReservationHandler) place(restaurant *models.Restaurant, req createReservationReq, tmpl models.Reservation) (*models.Reservation, *bookingError) {
+    rid := restaurant.ID
+    if e := archivedError(restaurant); e != nil {
+        return nil, e
+    }
+    if req.End.IsZero() && req.Guests > 0 {
+        req.End = req.Start.Add(restaurant.Settings.DurationFor(req.Guests))
+    }
+    if !req.End.After(req.Start) || req.Guests <= 0 {
+        return nil, &bookingError{status: http.StatusBadRequest, msg: "invalid time range or guests"}
+    }
+    if req.End.Sub(req.Start) > maxBookingDuration {
+        return nil, &bookingError{status: http.StatusBadRequest, msg: msgTooLong}
+    }
+    if err := req.SpecialRequests.Normalize(); err != nil {
+        return nil, &bookingError{status: http.StatusBadRequest, msg: err.Error()}
+    }
+    if e := bookingRulesError(restaurant, req.Start, req.Guests); e != nil {
+        return nil, e
+    }
+    if e := h.bookingLimitsError(&tmpl, restaurant, req.Start, req.End, ""); e != nil {
+        return nil, e
+    }
     
     // Check if reservation time is within restaurant operating hours
     if !h.isWithinOperatingHours(restaurant, req.Start, req.End) {
-        badRequest(w, "reservation time is outside restaurant operating hours")
-        return
+        return nil, &bookingError{status: http.StatusBadRequest, msg: "reservation time is outside restaurant operating hours"}
     }
-    // pick table if not provided
-    var table *models.Table
-    if req.Table != "" {
-        t, err := h.tables.ByID(req.Table)
-        if err != nil || t.RestaurantID != rid {
-            badRequest(w, "invalid tableId")
-            return
+    if e := h.pacingError(restaurant, req.Start, req.End, req.Guests, ""); e != nil {
+        return nil, e
+    }
+    // pick tables if not provided
+    needs := needsOf(req.SpecialRequests)
+    var chosen *allocation
+    if req.Table != "" || req.Combination != "" {
+        a, msg := h.allocationFor(rid, req.Table, req.Combination)
+        if a == nil {
+            return nil, &bookingError{status: http.StatusBadRequest, msg: msg}
         }
-        table = t
-    } else {
-        // Smart table allocation: find the best available table
-        table = h.findBestAvailableTable(rid, req.Start, req.End, req.Guests)
-        if table == nil {
-            badRequest(w, "no available table for the requested time")
-            return
+        if a.capacity < req.Guests {
+            return nil, &bookingError{status: http.StatusBadRequest, msg: "table not available"}
+        }
+        if !h.admitsAll(needs, a.tableIDs) {
+            return nil, &bookingError{status: http.StatusBadRequest, msg: msgNotAccessible}
         }
+        if h.outOfService(rid, a.tableIDs, req.Start, req.End) {
+            return nil, &bookingError{status: http.StatusConflict, msg: "table not available"}
+        }
+        chosen = a
     }
-    // ensure it's actually available
-    overlaps, _ := h.reservations.ListOverlap(store.ReservationFilter{RestaurantID: rid, TableID: table.ID, StartBefore: req.Start, EndAfter: req.End})
-    if len(overlaps) > 0 || table.Capacity < req.Guests {
-        badRequest(w, "table not available")
-        return
+    // A concurrent booking can take auto-allocated tables between the
+    // availability check and the insert, so re-pick a few times before giving up.
+    for attempt := 0; attempt < 3; attempt++ {
+        a := chosen
+        if a == nil {
+            // Smart table allocation: a single table, or a combination for large parties
+            a = h.findBestAllocation(restaurant, req.Start, req.End, req.Guests, "", needs)
+            if a == nil {
+                return nil, &bookingError{status: http.StatusBadRequest, msg: "no available table for the requested time"}
+            }
+        }
+        res := tmpl
+        res.RestaurantID, res.StartTime, res.EndTime, res.Guests = rid, req.Start, req.End, req.Guests
+        res.TurnoverMinutes = restaurant.Settings.TurnoverMinutes
+        res.SpecialRequests = req.SpecialRequests
+        a.apply(&res)
+        err := h.reservations.CreateIfFree(&res)
+        if err == nil {
+            return &res, nil
+        }
+        var ce *store.ConflictError
+        if !errors.As(err, &ce) {
+            return nil, &bookingError{status: http.StatusBadRequest, msg: "could not create reservation"}
+        }
+        if chosen != nil {
+            break
+        }
     }
+    return nil, &bookingError{status: http.StatusConflict, msg: "table not available"}
+}
+
+type cancelReservationReq struct {
+    Reason string `json:"reason"`
+}
+
+// Cancel cancels a reservation under the restaurant's cancellation policy.
+func (h *ReservationHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+    id := router.Param(r, "id")
     claims := middleware.ClaimsFromContext(r)
     if claims == nil {
         unauthorized(w, "no auth")
         return
     }
-    res := &models.Reservation{RestaurantID: rid, TableID: table.ID, UserID: claims.Sub, StartTime: req.Start, EndTime: req.End, Guests: req.Guests, Status: "confirmed"}
-    if err := h.reservations.Create(res); err != nil {
-        badRequest(w, "could not create reservation")
+    res, err := h.reservations.ByID(id)
+    if err != nil {
+        notFound(w, "reservation not found")
+        return
+    }
+    if claims.Role != "admin" && res.UserID != claims.Sub {
+        forbidden(w, "not allowed")
         return
     }
-    writeJSON(w, http.StatusCreated, res)
+    // the reason may come as JSON body or, for clients that drop DELETE
+    // bodies, as a query parameter
+    var req cancelReservationReq
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+        badRequest(w, "invalid json")
+        return
+    }
+    if req.Reason == "" {
+        req.Reason = r.URL.Query().Get("reason")
+    }
+    h.cancel(w, res, req.Reason, claims.Role == "admin")
 }
 
-func (h *ReservationHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+type modifyReservationReq struct {
+    Start       *time.Time `json:"start"`
+    End         *time.Time `json:"end"`
+    Guests      *int       `json:"guests"`
+    Table       *string    `json:"tableId"`
+    Combination *string    `json:"combinationId"`
+
+    Occasion      *string                  `json:"occasion"`
+    Dietary       *[]string                `json:"dietary"`
+    Accessibility *[]string                `json:"accessibility"`
+    Notes         *string                  `json:"notes"`
+    Seating       *models.TablePreferences `json:"seating"` // {} clears the preferences
+}
+
+// moves reports whether the request changes the time, party size or tables.
+func (req modifyReservationReq) moves() bool {
+    return req.Start != nil || req.End != nil || req.Guests != nil || req.Table != nil || req.Combination != nil
+}
+
+// specialRequests applies the request's special request fields to cur and
+// reports whether any was given.
+func (req modifyReservationReq) specialRequests(cur models.SpecialRequests) (models.SpecialRequests, bool) {
+    sr := cur
+    if req.Occasion != nil {
+        sr.Occasion = *req.Occasion
+    }
+    if req.Dietary != nil {
+        sr.Dietary = *req.Dietary
+    }
+    if req.Accessibility != nil {
+        sr.Accessibility = *req.Accessibility
+    }
+    if req.Notes != nil {
+        sr.Notes = *req.Notes
+    }
+    if req.Seating != nil {
+        sr.Seating = req.Seating
+        if *req.Seating == (models.TablePreferences{}) {
+            sr.Seating = nil
+        }
+    }
+    return sr, req.Occasion != nil || req.Dietary != nil || req.Accessibility != nil || req.Notes != nil || req.Seating != nil
+}
+
+// Modify changes the time, party size, table or special requests of a
+// reservation in one step. The original booking is kept if the new slot is
+// not available.
+func (h *ReservationHandler) Modify(w http.ResponseWriter, r *http.Request) {
     id := router.Param(r, "id")
     claims := middleware.ClaimsFromContext(r)
     if claims == nil {
         unauthorized(w, "no auth")
         return
     }
-    res, err := h.reservations.ByID(id)
+    cur, err := h.reservations.ByID(id)
     if err != nil {
         notFound(w, "reservation not found")
         return
     }
-    if claims.Role != "admin" && res.UserID != claims.Sub {
+    if claims.Role != "admin" && cur.UserID != claims.Sub {
         forbidden(w, "not allowed")
         return
     }
-    if err := h.reservations.Cancel(id); err != nil {
-        badRequest(w, "unable to cancel")
+    if !models.HoldsTable(cur.Status) {
+        conflict(w, "reservation is "+cur.Status+" and can no longer be changed")
+        return
+    }
+    var req modifyReservationReq
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        badRequest(w, "invalid json")
+        return
+    }
+    sr, changed := req.specialRequests(cur.SpecialRequests)
+    if err := sr.Normalize(); err != nil {
+        badRequest(w, err.Error())
+        return
+    }
+    // Fill in unchanged fields; moving only the start keeps the duration.
+    next := *cur
+    next.SpecialRequests = sr
+    if req.Start != nil {
+        next.StartTime = *req.Start
+        next.EndTime = req.Start.Add(cur.EndTime.Sub(cur.StartTime))
+    }
+    if req.End != nil {
+        next.EndTime = *req.End
+    }
+    if req.Guests != nil {
+        next.Guests = *req.Guests
+    }
+    if !next.EndTime.After(next.StartTime) || next.Guests <= 0 {
+        badRequest(w, "invalid time range or guests")
+        return
+    }
+    if next.EndTime.Sub(next.StartTime) > maxBookingDuration {
+        badRequest(w, msgTooLong)
         return
     }
-    writeJSON(w, http.StatusOK, map[string]string{"status": "cancelled"})
+    restaurant, err := h.restaurants.ByID(cur.RestaurantID)
+    if err != nil {
+        notFound(w, "restaurant not found")
+        return
+    }
+
+    // Use the requested table or combination; otherwise keep the current
+    // tables when they still fit and are free, or allocate new ones.
+    var chosen *allocation
+    if req.Table != nil && *req.Table != "" || req.Combination != nil && *req.Combination != "" {
+        var tableID, combinationID string
+        if req.Table != nil {
+            tableID = *req.Table
+        }
+        if req.Combination != nil {
+            combinationID = *req.Combination
+        }
+        a, msg := h.allocationFor(cur.RestaurantID, tableID, combinationID)
+        if a == nil {
+            badRequest(w, msg)
+            return
+        }
+        if a.capacity < next.Guests {
+            badRequest(w, "table not available")
+            return
+        }
+        chosen = a
+    }
+    // a new accessibility requirement may need other tables
+    if req.moves() || !h.admitsAll(needsOf(sr), cur.Tables()) {
+        if e := h.move(restaurant, cur, &next, chosen, req.Start != nil || req.Guests != nil, ""); e != nil {
+            e.write(w)
+            return
+        }
+    }
+    if changed {
+        if err := h.reservations.UpdateSpecialRequests(id, sr); err != nil {
+            badRequest(w, "could not update reservation")
+            return
+        }
+    }
+    updated, err := h.reservations.ByID(id)
+    if err != nil {
+        notFound(w, "reservation not found")
+        return
+    }
+    writeJSON(w, http.StatusOK, updated)
+}
+
+// reseat moves res to tables that meet the seating requirements of its new
+// special requests sr, e.g. an accessible table once a wheelchair is added.
+// Its time and party size are kept, and so are its tables if they qualify.
+func (h *ReservationHandler) reseat(res *models.Reservation, sr models.SpecialRequests) *bookingError {
+    if h.admitsAll(needsOf(sr), res.Tables()) {
+        return nil
+    }
+    restaurant, err := h.restaurants.ByID(res.RestaurantID)
+    if err != nil {
+        return &bookingError{status: http.StatusNotFound, msg: "restaurant not found"}
+    }
+    next := *res
+    next.SpecialRequests = sr
+    return h.move(restaurant, res, &next, nil, false, "")
+}
+
+// move changes cur to next's time and party size on the chosen tables, or
+// on its current tables when they still fit and are free, or on newly
+// allocated ones. All of them must meet the seating requirements of next's
+// special requests, and none may be avoid (if set). Booking rules and pacing
+// are checked only when paced is set, i.e. the start or party size changed;
+// the owner's booking limits only when the time changes.
+func (h *ReservationHandler) move(restaurant *models.Restaurant, cur, next *models.Reservation, chosen *allocation, paced bool, avoid string) *bookingError {
+    if paced {
+        if e := bookingRulesError(restaurant, next.StartTime, next.Guests); e != nil {
+            return e
+        }
+    }
+    if !next.StartTime.Equal(cur.StartTime) || !next.EndTime.Equal(cur.EndTime) {
+        if e := h.bookingLimitsError(cur, restaurant, next.StartTime, next.EndTime, cur.ID); e != nil {
+            return e
+        }
+    }
+    if !h.isWithinOperatingHours(restaurant, next.StartTime, next.EndTime) {
+        return &bookingError{status: http.StatusBadRequest, msg: "reservation time is outside restaurant operating hours"}
+    }
+    if paced {
+        if e := h.pacingError(restaurant, next.StartTime, next.EndTime, next.Guests, cur.ID); e != nil {
+            return e
+        }
+    }
+    next.TurnoverMinutes = restaurant.Settings.TurnoverMinutes
+    needs := needsOf(next.SpecialRequests)
+    needs.avoid = avoid
+    if chosen != nil && !h.admitsAll(needs, chosen.tableIDs) {
+        return &bookingError{status: http.StatusBadRequest, msg: msgNotAccessible}
+    }
+    if chosen != nil && h.outOfService(cur.RestaurantID, chosen.tableIDs, next.StartTime, next.EndTime) {
+        return &bookingError{status: http.StatusConflict, msg: "table not available"}
+    }
+    if chosen == nil {
+        if a, _ := h.allocationFor(cur.RestaurantID, cur.TableID, cur.CombinationID); a != nil && a.capacity >= next.Guests && h.admitsAll(needs, a.tableIDs) {
+            if occ, err := h.loadOccupancy(restaurant, next.StartTime, next.EndTime, cur.ID); err == nil && occ.freeAll(a.tableIDs, next.StartTime, next.EndTime) {
+                chosen = a
+            }
+        }
+        if chosen == nil {
+            chosen = h.findBestAllocation(restaurant, next.StartTime, next.EndTime, next.Guests, cur.ID, needs)
+        }
+        if chosen == nil {
+            return &bookingError{status: http.StatusConflict, msg: "no available table for the requested time"}
+        }
+    }
+    chosen.apply(next)
+    if err := h.reservations.UpdateIfFree(next); err != nil {
+        var ce *store.ConflictError
+        if errors.As(err, &ce) {
+            return &bookingError{status: http.StatusConflict, msg: "table not available"}
+        }
+        return &bookingError{status: http.StatusBadRequest, msg: "could not update reservation"}
+    }
+    return nil
+}
+
+// Transition returns an admin handler that moves a reservation to status,
+// e.g. POST /reservations/:id/seat for models.StatusSeated.
+func (h *ReservationHandler) Transition(status string) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        id := router.Param(r, "id")
+        res, err := h.reservations.ByID(id)
+        if err != nil {
+            notFound(w, "reservation not found")
+            return
+        }
+        now := time.Now()
+        if status == models.StatusNoShow && now.Before(res.StartTime) {
+            conflict(w, "cannot mark a no-show before the reservation starts")
+            return
+        }
+        if err := h.reservations.UpdateStatus(id, status, now); err != nil {
+            var te *store.TransitionError
+            if errors.As(err, &te) {
+                conflict(w, te.Error())
+                return
+            }
+            badRequest(w, "unable to update reservation")
+            return
+        }
+        res, err = h.reservations.ByID(id)
+        if err != nil {
+            notFound(w, "reservation not found")
+            return
+        }
+        writeJSON(w, http.StatusOK, res)
+    }
 }
 
 func (h *ReservationHandler) ListMine(w http.ResponseWriter, r *http.Request) {
@@ -294,6 +592,13 @@ func (h *ReservationHandler) ListMine(w http.ResponseWriter, r *http.Request) {
         return
     }
     list, _ := h.reservations.ListByUser(claims.Sub)
-    writeJSON(w, http.StatusOK, list)
+    // checkout holds are not bookings yet
+    out := make([]*models.Reservation, 0, len(list))
+    for _, res := range list {
+        if res.Status != models.StatusHeld {
+            out = append(out, res)
+        }
+    }
+    writeJSON(w, http.StatusOK, out)
 }
 
@@ -12,24 +12,41 @@ import (
 )
 
 type RestaurantHandler struct {
-    restaurants store.RestaurantStore
-    tables      store.TableStore
+    restaurants  store.RestaurantStore
+    tables       store.TableStore
     reservations store.ReservationStore
+    hours        store.HoursStore
+    exceptions   store.ExceptionStore
+    blocks       store.TableBlockStore
+    floor        store.FloorPlanStore
+    combinations store.TableCombinationStore
+    // the rest are only needed to purge a restaurant
+    series       store.SeriesStore
+    waitlist     store.WaitlistStore
 }
 
-func NewRestaurantHandler(restaurants store.RestaurantStore, tables store.TableStore, reservations store.ReservationStore) *RestaurantHandler {
+func NewRestaurantHandler(restaurants store.RestaurantStore, tables store.TableStore, reservations store.ReservationStore, hours store.HoursStore, exceptions store.ExceptionStore, combinations store.TableCombinationStore, blocks store.TableBlockStore, floor store.FloorPlanStore, series store.SeriesStore, waitlist store.WaitlistStore) *RestaurantHandler {
     return &RestaurantHandler{
         restaurants:  restaurants,
         tables:       tables,
         reservations: reservations,
+        hours:        hours,
+        exceptions:   exceptions,
+        combinations: combinations,
+        blocks:       blocks,
+        floor:        floor,
+        series:       series,
+        waitlist:     waitlist,
     }
 }
 
 type createRestaurantReq struct {
-    Name      string `json:"name"`
-    Address   string `json:"address"`
-    OpenTime  string `json:"openTime"`
-    CloseTime string `json:"closeTime"`
+    Name      string                    `json:"name"`
+    Address   string                    `json:"address"`
+    OpenTime  string                    `json:"openTime"`
+    CloseTime string                    `json:"closeTime"`
+    TimeZone  string                    `json:"timeZone"`
+    Settings  models.RestaurantSettings `json:"settings"`
 }
 
 func (h *RestaurantHandler) Create(w http.ResponseWriter, r *http.Request) {
@@ -43,17 +60,58 @@ func (h *RestaurantHandler) Create(w http.ResponseWriter, r *http.Request) {
         badRequest(w, "name required")
         return
     }
-    rest := &models.Restaurant{Name: req.Name, Address: strings.TrimSpace(req.Address), OpenTime: strings.TrimSpace(req.OpenTime), CloseTime: strings.TrimSpace(req.CloseTime)}
+    req.TimeZone = strings.TrimSpace(req.TimeZone)
+    if req.TimeZone == "" {
+        req.TimeZone = models.DefaultTimeZone
+    }
+    if _, err := time.LoadLocation(req.TimeZone); err != nil {
+        badRequest(w, "invalid timeZone")
+        return
+    }
+    req.OpenTime = strings.TrimSpace(req.OpenTime)
+    req.CloseTime = strings.TrimSpace(req.CloseTime)
+    // openTime/closeTime are optional shorthand for the same window every day;
+    // the weekly schedule can be refined later under /hours.
+    seedHours := req.OpenTime != "" || req.CloseTime != ""
+    if seedHours {
+        if _, _, err := parseTime(req.OpenTime); err != nil {
+            badRequest(w, "openTime must be HH:MM")
+            return
+        }
+        if _, _, err := parseTime(req.CloseTime); err != nil {
+            badRequest(w, "closeTime must be HH:MM")
+            return
+        }
+    }
+    if msg := validateSettings(req.Settings); msg != "" {
+        badRequest(w, msg)
+        return
+    }
+    rest := &models.Restaurant{Name: req.Name, Address: strings.TrimSpace(req.Address), OpenTime: req.OpenTime, CloseTime: req.CloseTime, TimeZone: req.TimeZone, Settings: req.Settings}
     if err := h.restaurants.Create(rest); err != nil {
         badRequest(w, "could not create restaurant")
         return
     }
+    if seedHours {
+        _, _ = h.createDailyHours(rest.ID, req.OpenTime, req.CloseTime)
+    }
     writeJSON(w, http.StatusCreated, rest)
 }
 
+// List returns the restaurants that are not archived.
 func (h *RestaurantHandler) List(w http.ResponseWriter, r *http.Request) {
+    writeJSON(w, http.StatusOK, h.listArchived(false))
+}
+
+func (h *RestaurantHandler) listArchived(archived bool) []*models.Restaurant {
     list, _ := h.restaurants.List()
-    writeJSON(w, http.StatusOK, list)
+    out := make([]*models.Restaurant, 0, len(list))
+    for _, rest := range list {
+        if rest.Archived() == archived {
+            out = append(out, rest)
+        }
+    }
+    return out
 }
 
 func (h *RestaurantHandler) GetByID(w http.ResponseWriter, r *http.Request) {
@@ -66,24 +124,240 @@ func (h *RestaurantHandler) GetByID(w http.ResponseWriter, r *http.Request) {
     writeJSON(w, http.StatusOK, rest)
 }
 
+type updateRestaurantReq struct {
+    Name      *string `json:"name"`
+    Address   *string `json:"address"`
+    OpenTime  *string `json:"openTime"`
+    CloseTime *string `json:"closeTime"`
+    TimeZone  *string `json:"timeZone"`
+}
+
+// Update changes the name, address, time zone and opening hours present in
+// the request body. New openTime/closeTime move the daily window seeded on
+// create, or seed it as Create does if the restaurant has no schedule yet;
+// once the weekly schedule has been changed through /hours they are refused
+// with 409, and the schedule is edited there instead.
+func (h *RestaurantHandler) Update(w http.ResponseWriter, r *http.Request) {
+    rest, err := h.restaurants.ByID(router.Param(r, "id"))
+    if err != nil {
+        notFound(w, "restaurant not found")
+        return
+    }
+    var req updateRestaurantReq
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        badRequest(w, "invalid json")
+        return
+    }
+    next := *rest
+    if req.Name != nil {
+        if next.Name = strings.TrimSpace(*req.Name); next.Name == "" {
+            badRequest(w, "name required")
+            return
+        }
+    }
+    if req.Address != nil {
+        next.Address = strings.TrimSpace(*req.Address)
+    }
+    if req.TimeZone != nil {
+        if next.TimeZone = strings.TrimSpace(*req.TimeZone); next.TimeZone == "" {
+            next.TimeZone = models.DefaultTimeZone
+        }
+        if _, err := time.LoadLocation(next.TimeZone); err != nil {
+            badRequest(w, "invalid timeZone")
+            return
+        }
+    }
+    if req.OpenTime != nil {
+        next.OpenTime = strings.TrimSpace(*req.OpenTime)
+    }
+    if req.CloseTime != nil {
+        next.CloseTime = strings.TrimSpace(*req.CloseTime)
+    }
+    undoHours := func() {}
+    if next.OpenTime != rest.OpenTime || next.CloseTime != rest.CloseTime {
+        if _, _, err := parseTime(next.OpenTime); err != nil {
+            badRequest(w, "openTime must be HH:MM")
+            return
+        }
+        if _, _, err := parseTime(next.CloseTime); err != nil {
+            badRequest(w, "closeTime must be HH:MM")
+            return
+        }
+        periods, err := h.hours.ListByRestaurant(rest.ID)
+        if err != nil {
+            badRequest(w, "could not load opening hours")
+            return
+        }
+        switch {
+        case len(periods) == 0:
+            ids, err := h.createDailyHours(rest.ID, next.OpenTime, next.CloseTime)
+            if err != nil {
+                badRequest(w, "could not update opening hours")
+                return
+            }
+            undoHours = func() { h.deleteHours(ids) }
+        case !uniformHours(periods, rest):
+            writeError(w, http.StatusConflict, "custom_opening_hours", "opening hours have been customised; change them through /hours")
+            return
+        default:
+            if err := h.setHours(periods, next.OpenTime, next.CloseTime); err != nil {
+                badRequest(w, "could not update opening hours")
+                return
+            }
+            undoHours = func() { _ = h.setHours(periods, rest.OpenTime, rest.CloseTime) }
+        }
+    }
+    if err := h.restaurants.Update(&next); err != nil {
+        undoHours()
+        badRequest(w, "could not update restaurant")
+        return
+    }
+    writeJSON(w, http.StatusOK, next)
+}
+
+// uniformHours reports whether the weekly schedule is still the one seeded on
+// create: a single period every day from rest's openTime to closeTime.
+func uniformHours(periods []*models.ServicePeriod, rest *models.Restaurant) bool {
+    days := map[time.Weekday]bool{}
+    for _, p := range periods {
+        if days[p.Weekday] || p.OpenTime != rest.OpenTime || p.CloseTime != rest.CloseTime {
+            return false
+        }
+        days[p.Weekday] = true
+    }
+    return len(days) == 7
+}
+
+// createDailyHours seeds the weekly schedule with the same openTime-closeTime
+// period every day and returns the IDs of the periods. If one cannot be saved,
+// those already created are removed.
+func (h *RestaurantHandler) createDailyHours(restaurantID, openTime, closeTime string) ([]string, error) {
+    var ids []string
+    for wd := time.Sunday; wd <= time.Saturday; wd++ {
+        p := &models.ServicePeriod{RestaurantID: restaurantID, Weekday: wd, OpenTime: openTime, CloseTime: closeTime}
+        if err := h.hours.Create(p); err != nil {
+            h.deleteHours(ids)
+            return nil, err
+        }
+        ids = append(ids, p.ID)
+    }
+    return ids, nil
+}
+
+func (h *RestaurantHandler) deleteHours(ids []string) {
+    for _, id := range ids {
+        _ = h.hours.Delete(id)
+    }
+}
+
+// setHours moves every period to openTime-closeTime. If one cannot be saved,
+// those already changed are put back.
+func (h *RestaurantHandler) setHours(periods []*models.ServicePeriod, openTime, closeTime string) error {
+    old := make([]models.ServicePeriod, len(periods))
+    for i, p := range periods {
+        old[i] = *p
+    }
+    for i := range old {
+        next := old[i]
+        next.OpenTime, next.CloseTime = openTime, closeTime
+        if err := h.hours.Update(&next); err != nil {
+            for j := range old[:i] {
+                _ = h.hours.Update(&old[j])
+            }
+            return err
+        }
+    }
+    return nil
+}
+
+func (h *RestaurantHandler) Settings(w http.ResponseWriter, r *http.Request) {
+    rest, err := h.restaurants.ByID(router.Param(r, "id"))
+    if err != nil {
+        notFound(w, "restaurant not found")
+        return
+    }
+    writeJSON(w, http.StatusOK, rest.Settings)
+}
+
+// UpdateSettings changes only the settings present in the request body.
+func (h *RestaurantHandler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+    rest, err := h.restaurants.ByID(router.Param(r, "id"))
+    if err != nil {
+        notFound(w, "restaurant not found")
+        return
+    }
+    next := *rest
+    // decode over a copy so omitted fields keep their current values
+    next.Settings.PartyDurations = append([]models.PartyDuration(nil), rest.Settings.PartyDurations...)
+    if err := json.NewDecoder(r.Body).Decode(&next.Settings); err != nil {
+        badRequest(w, "invalid json")
+        return
+    }
+    if msg := validateSettings(next.Settings); msg != "" {
+        badRequest(w, msg)
+        return
+    }
+    if err := h.restaurants.Update(&next); err != nil {
+        badRequest(w, "could not update settings")
+        return
+    }
+    writeJSON(w, http.StatusOK, next.Settings)
+}
+
+// validateSettings returns an error message for out-of-range settings, or "".
+func validateSettings(s models.RestaurantSettings) string {
+    if s.DefaultDurationMinutes < 0 || s.DefaultDurationMinutes > 12*60 {
+        return "defaultDurationMinutes must be between 0 and 720"
+    }
+    if s.TurnoverMinutes < 0 || s.TurnoverMinutes > 4*60 {
+        return "turnoverMinutes must be between 0 and 240"
+    }
+    for _, p := range s.PartyDurations {
+        if p.MaxGuests <= 0 || p.Minutes < 15 || p.Minutes > 12*60 {
+            return "partyDurations need maxGuests > 0 and minutes between 15 and 720"
+        }
+    }
+    if s.Cancellation.DeadlineHours < 0 || s.Cancellation.DeadlineHours > 30*24 {
+        return "cancellation.deadlineHours must be between 0 and 720"
+    }
+    if msg := validatePacing(s.Pacing); msg != "" {
+        return msg
+    }
+    return validateBookingRules(s.BookingRules)
+}
+
 type RestaurantDetails struct {
     *models.Restaurant
-    Stats RestaurantStats `json:"stats"`
-    Tables []TableInfo     `json:"tables"`
+    LocalTime time.Time               `json:"localTime"` // current time in the restaurant's zone
+    Booking   BookingWindow           `json:"booking"`
+    Hours     []*models.ServicePeriod `json:"hours"`
+    Stats     RestaurantStats         `json:"stats"`
+    Tables    []TableInfo             `json:"tables"`
+}
+
+// BookingWindow is the range of starts that can be booked right now, derived
+// from the restaurant's booking rules for date pickers.
+type BookingWindow struct {
+    EarliestStart        time.Time  `json:"earliestStart"`
+    LatestStart          *time.Time `json:"latestStart,omitempty"` // nil when bookings open any time ahead
+    MinPartySize         int        `json:"minPartySize,omitempty"`
+    MaxPartySize         int        `json:"maxPartySize,omitempty"`
+    StartIntervalMinutes int        `json:"startIntervalMinutes,omitempty"`
 }
 
 type RestaurantStats struct {
-    TotalTables       int `json:"totalTables"`
-    TotalCapacity     int `json:"totalCapacity"`
-    TotalReservations int `json:"totalReservations"`
-    ActiveReservations int `json:"activeReservations"`
+    TotalTables        int `json:"totalTables"`
+    TotalCapacity      int `json:"totalCapacity"`
+    TotalReservations  int `json:"totalReservations"`  // in any status
+    ActiveReservations int `json:"activeReservations"` // still holding a table
+    currentStats
 }
 
 type TableInfo struct {
     ID       string `json:"id"`
     Name     string `json:"name"`
     Capacity int    `json:"capacity"`
-    Status   string `json:"status"` // available, occupied, reserved
+    Status   string `json:"status"` // as in the floor plan state
 }
 
 func (h *RestaurantHandler) GetDetails(w http.ResponseWriter, r *http.Request) {
@@ -96,69 +370,57 @@ func (h *RestaurantHandler) GetDetails(w http.ResponseWriter, r *http.Request) {
         return
     }
     
+    now := time.Now().In(restaurant.Location())
+    hours, _ := h.hours.ListByRestaurant(id)
+
     // Get tables info
     tables, _ := h.tables.ListByRestaurant(id)
     var tableInfos []TableInfo
     totalCapacity := 0
     
+    states, _ := h.floorState(restaurant, tables, now)
     for _, table := range tables {
         tableInfo := TableInfo{
             ID:       table.ID,
             Name:     table.Name,
             Capacity: table.Capacity,
-            Status:   "available", // Default status
+            Status:   floorAvailable,
         }
-        
-        // Check if table has active reservations (simple check for now)
-        overlaps, _ := h.reservations.ListOverlap(store.ReservationFilter{
-            RestaurantID: id,
-            TableID:      table.ID,
-            StartBefore:  time.Now(),
-            EndAfter:     time.Now(),
-        })
-        
-        if len(overlaps) > 0 {
-            tableInfo.Status = "occupied"
+        if st := states[table.ID]; st != nil {
+            tableInfo.Status = st.Status
         }
         
         tableInfos = append(tableInfos, tableInfo)
         totalCapacity += table.Capacity
     }
     
-    // Get reservation statistics (simplified)
-    // This could be enhanced with more complex queries
     stats := RestaurantStats{
-        TotalTables:       len(tables),
-        TotalCapacity:     totalCapacity,
-        TotalReservations: 0, // Would need a count query
-        ActiveReservations: 0, // Would need a count query for active reservations
+        TotalTables:   len(tables),
+        TotalCapacity: totalCapacity,
+    }
+    if all, err := h.countRange(id, time.Unix(0, 0), now.AddDate(upcomingYears, 0, 0)); err == nil {
+        stats.TotalReservations, stats.ActiveReservations = all.total, all.holding
+    }
+    if all, err := h.tables.ListAllByRestaurant(id); err == nil {
+        stats.currentStats, _ = h.current(restaurant, all, now)
     }
     
+    rules := restaurant.Settings.BookingRules
+    window := BookingWindow{MinPartySize: rules.MinPartySize, MaxPartySize: rules.MaxPartySize, StartIntervalMinutes: rules.StartIntervalMinutes}
+    earliest, latest := rules.Window(now)
+    window.EarliestStart = earliest
+    if !latest.IsZero() {
+        window.LatestStart = &latest
+    }
+
     details := RestaurantDetails{
         Restaurant: restaurant,
+        LocalTime:  now,
+        Booking:    window,
+        Hours:      hours,
         Stats:      stats,
         Tables:     tableInfos,
     }
     
     writeJSON(w, http.StatusOK, details)
 }
-
-func (h *RestaurantHandler) Delete(w http.ResponseWriter, r *http.Request) {
-    id := router.Param(r, "id")
-    
-    // Check if restaurant exists
-    _, err := h.restaurants.ByID(id)
-    if err != nil {
-        notFound(w, "restaurant not found")
-        return
-    }
-    
-    // Delete the restaurant
-    if err := h.restaurants.Delete(id); err != nil {
-        badRequest(w, "failed to delete restaurant")
-        return
-    }
-    
-    w.WriteHeader(http.StatusNoContent)
-}
-
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+    "fmt"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+
+    "orderation/internal/models"
+    "orderation/internal/store"
+)
+
+// interval is a half-open time range [start, end).
+type interval struct {
+    start time.Time
+    end   time.Time
+}
+
+// dateLayout is the format of local calendar dates in the API and stores.
+const dateLayout = "2006-01-02"
+
+// schedule evaluates a restaurant's weekly service periods and date
+// exceptions in its time zone. Availability, slot search and booking all go
+// through it.
+type schedule struct {
+    loc        *time.Location
+    periods    []*models.ServicePeriod
+    exceptions map[string]*models.HoursException // keyed by local date
+}
+
+// loadSchedule loads the weekly periods plus the exceptions needed to
+// evaluate opening hours between from and to.
+func loadSchedule(hours store.HoursStore, exceptions store.ExceptionStore, restaurant *models.Restaurant, from, to time.Time) (*schedule, error) {
+    periods, err := hours.ListByRestaurant(restaurant.ID)
+    if err != nil {
+        return nil, err
+    }
+    loc := restaurant.Location()
+    // A period or exception that opens the day before can still reach into from.
+    first := from.In(loc).AddDate(0, 0, -1).Format(dateLayout)
+    last := to.In(loc).Format(dateLayout)
+    list, err := exceptions.ListByRestaurant(restaurant.ID, first, last)
+    if err != nil {
+        return nil, err
+    }
+    byDate := make(map[string]*models.HoursException, len(list))
+    for _, e := range list {
+        byDate[e.Date] = e
+    }
+    return &schedule{loc: loc, periods: periods, exceptions: byDate}, nil
+}
+
+// intervals returns the opening windows that overlap [from, to), with
+// touching or overlapping periods merged into one window.
+func (s *schedule) intervals(from, to time.Time) []interval {
+    lf := from.In(s.loc)
+    lt := to.In(s.loc)
+    // Start a day early so periods that opened yesterday and run past
+    // midnight are included.
+    day := time.Date(lf.Year(), lf.Month(), lf.Day(), 0, 0, 0, 0, s.loc).AddDate(0, 0, -1)
+    last := time.Date(lt.Year(), lt.Month(), lt.Day(), 0, 0, 0, 0, s.loc)
+    var all []interval
+    for ; !day.After(last); day = day.AddDate(0, 0, 1) {
+        all = append(all, s.dayIntervals(day)...)
+    }
+    sort.Slice(all, func(i, j int) bool { return all[i].start.Before(all[j].start) })
+    var merged []interval
+    for _, iv := range all {
+        if n := len(merged); n > 0 && !iv.start.After(merged[n-1].end) {
+            if iv.end.After(merged[n-1].end) {
+                merged[n-1].end = iv.end
+            }
+            continue
+        }
+        merged = append(merged, iv)
+    }
+    out := merged[:0]
+    for _, iv := range merged {
+        if iv.start.Before(to) && iv.end.After(from) {
+            out = append(out, iv)
+        }
+    }
+    return out
+}
+
+// dayIntervals returns the windows that open on the given local date
+// (midnight in s.loc): the exception for that date if there is one, otherwise
+// the weekly periods for its weekday.
+func (s *schedule) dayIntervals(day time.Time) []interval {
+    if e := s.exceptions[day.Format(dateLayout)]; e != nil {
+        if e.Closed {
+            return nil
+        }
+        if iv, ok := periodInterval(day, e.OpenTime, e.CloseTime); ok {
+            return []interval{iv}
+        }
+        return nil
+    }
+    var out []interval
+    for _, p := range s.periods {
+        if p.Weekday != day.Weekday() {
+            continue
+        }
+        if iv, ok := periodInterval(day, p.OpenTime, p.CloseTime); ok {
+            out = append(out, iv)
+        }
+    }
+    return out
+}
+
+// periodInterval anchors an open/close pair to a local date. A close time at
+// or before the open time ends on the following day.
+func periodInterval(day time.Time, open, close string) (interval, bool) {
+    oh, om, err := parseTime(open)
+    if err != nil {
+        return interval{}, false
+    }
+    ch, cm, err := parseTime(close)
+    if err != nil {
+        return interval{}, false
+    }
+    start := time.Date(day.Year(), day.Month(), day.Day(), oh, om, 0, 0, day.Location())
+    end := time.Date(day.Year(), day.Month(), day.Day(), ch, cm, 0, 0, day.Location())
+    if !end.After(start) {
+        end = time.Date(day.Year(), day.Month(), day.Day()+1, ch, cm, 0, 0, day.Location())
+    }
+    return interval{start: start, end: end}, true
+}
+
+// periodAt returns the weekly service period open at t, or nil when t is
+// outside every period or falls on a date whose hours an exception replaces.
+func (s *schedule) periodAt(t time.Time) *models.ServicePeriod {
+    lt := t.In(s.loc)
+    day := time.Date(lt.Year(), lt.Month(), lt.Day(), 0, 0, 0, 0, s.loc)
+    // yesterday's late periods can still be open after midnight
+    for _, d := range []time.Time{day, day.AddDate(0, 0, -1)} {
+        if s.exceptions[d.Format(dateLayout)] != nil {
+            continue
+        }
+        for _, p := range s.periods {
+            if p.Weekday != d.Weekday() {
+                continue
+            }
+            if iv, ok := periodInterval(d, p.OpenTime, p.CloseTime); ok && !t.Before(iv.start) && t.Before(iv.end) {
+                return p
+            }
+        }
+    }
+    return nil
+}
+
+// covers reports whether [start, end) lies entirely inside one opening window.
+func (s *schedule) covers(start, end time.Time) bool {
+    for _, iv := range s.intervals(start, end) {
+        if !start.Before(iv.start) && !end.After(iv.end) {
+            return true
+        }
+    }
+    return false
+}
+
+// parseTime parses time string in "HH:MM" format; "24:00" is accepted as the
+// end of the day.
+func parseTime(timeStr string) (hour, minute int, err error) {
+    parts := strings.Split(strings.TrimSpace(timeStr), ":")
+    if len(parts) != 2 {
+        return 0, 0, fmt.Errorf("invalid time %q", timeStr)
+    }
+    
+    hour, err = strconv.Atoi(parts[0])
+    if err != nil {
+        return 0, 0, err
+    }
+    
+    minute, err = strconv.Atoi(parts[1])
+    if err != nil {
+        return 0, 0, err
+    }
+    
+    if hour < 0 || minute < 0 || minute > 59 || hour > 24 || (hour == 24 && minute != 0) {
+        return 0, 0, fmt.Errorf("invalid time %q", timeStr)
+    }
+    return hour, minute, nil
+}
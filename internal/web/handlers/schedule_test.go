@@ -0,0 +1,82 @@
+package handlers
+
+import (
+    "testing"
+    "time"
+
+    "orderation/internal/models"
+)
+
+func TestScheduleCovers(t *testing.T) {
+    loc, err := time.LoadLocation("Europe/Berlin")
+    if err != nil {
+        t.Skip("tzdata not available")
+    }
+    s := &schedule{loc: loc, periods: []*models.ServicePeriod{
+        {Weekday: time.Tuesday, OpenTime: "11:30", CloseTime: "14:00"},
+        {Weekday: time.Tuesday, OpenTime: "18:00", CloseTime: "23:00"},
+        {Weekday: time.Friday, OpenTime: "18:00", CloseTime: "02:00"},
+        {Weekday: time.Saturday, OpenTime: "00:00", CloseTime: "00:00"},
+    }}
+    // 2026-03-03 is a Tuesday, 2026-03-02 a Monday
+    at := func(day, hour, min int) time.Time { return time.Date(2026, 3, day, hour, min, 0, 0, loc) }
+    cases := []struct {
+        name       string
+        start, end time.Time
+        want       bool
+    }{
+        {"lunch", at(3, 12, 0), at(3, 13, 30), true},
+        {"dinner", at(3, 19, 0), at(3, 21, 0), true},
+        {"between services", at(3, 13, 30), at(3, 18, 30), false},
+        {"closed monday", at(2, 19, 0), at(2, 21, 0), false},
+        {"friday past midnight", at(6, 23, 30), at(7, 1, 30), true},
+        {"friday overnight into saturday", at(7, 1, 30), at(7, 2, 30), true}, // saturday is open all day
+        {"saturday into closed sunday", at(7, 23, 0), at(8, 0, 30), false},
+        {"utc input", at(3, 19, 0).UTC(), at(3, 20, 0).UTC(), true},
+    }
+    for _, c := range cases {
+        if got := s.covers(c.start, c.end); got != c.want {
+            t.Errorf("%s: covers = %v, want %v", c.name, got, c.want)
+        }
+    }
+}
+
+func TestParseTime(t *testing.T) {
+    for _, in := range []string{"", "9", "25:00", "10:60", "24:30", "ab:cd"} {
+        if _, _, err := parseTime(in); err == nil {
+            t.Errorf("parseTime(%q): expected error", in)
+        }
+    }
+    if h, m, err := parseTime("09:05"); err != nil || h != 9 || m != 5 {
+        t.Errorf("parseTime(09:05) = %d, %d, %v", h, m, err)
+    }
+}
+
+func TestScheduleExceptions(t *testing.T) {
+    loc := time.UTC
+    daily := []*models.ServicePeriod{}
+    for wd := time.Sunday; wd <= time.Saturday; wd++ {
+        daily = append(daily, &models.ServicePeriod{Weekday: wd, OpenTime: "10:00", CloseTime: "22:00"})
+    }
+    s := &schedule{loc: loc, periods: daily, exceptions: map[string]*models.HoursException{
+        "2026-12-25": {Date: "2026-12-25", Closed: true},
+        "2026-12-31": {Date: "2026-12-31", OpenTime: "17:00", CloseTime: "01:00"},
+    }}
+    at := func(month, day, hour, min int) time.Time { return time.Date(2026, time.Month(month), day, hour, min, 0, 0, loc) }
+    cases := []struct {
+        name       string
+        start, end time.Time
+        want       bool
+    }{
+        {"regular day", at(12, 24, 12, 0), at(12, 24, 14, 0), true},
+        {"closed christmas", at(12, 25, 12, 0), at(12, 25, 14, 0), false},
+        {"new year's eve lunch replaced", at(12, 31, 12, 0), at(12, 31, 14, 0), false},
+        {"new year's eve past midnight", at(12, 31, 23, 0), at(1, 1, 0, 30).AddDate(1, 0, 0), true},
+        {"new year's day regular", at(1, 1, 12, 0).AddDate(1, 0, 0), at(1, 1, 13, 0).AddDate(1, 0, 0), true},
+    }
+    for _, c := range cases {
+        if got := s.covers(c.start, c.end); got != c.want {
+            t.Errorf("%s: covers = %v, want %v", c.name, got, c.want)
+        }
+    }
+}
@@ -0,0 +1,347 @@
+package handlers
+
+import (
+    "encoding/json"
+    "errors"
+    "io"
+    "net/http"
+    "strconv"
+    "time"
+
+    "orderation/internal/models"
+    "orderation/internal/web/middleware"
+    "orderation/internal/web/router"
+)
+
+// maxSeriesOccurrences caps how many reservations one series creates; rules
+// ending by UNTIL are cut off there.
+const maxSeriesOccurrences = 52
+
+type createSeriesReq struct {
+    Start       time.Time `json:"start"` // first occurrence
+    End         time.Time `json:"end"`   // optional; defaults to the restaurant's dining time
+    Guests      int       `json:"guests"`
+    Table       string    `json:"tableId"`
+    Combination string    `json:"combinationId"`
+    Rule        string    `json:"rule"` // e.g. FREQ=WEEKLY;COUNT=10
+}
+
+// reservationConflict is an occurrence that could not be booked or changed.
+type reservationConflict struct {
+    Start         time.Time `json:"start"`
+    ReservationID string    `json:"reservationId,omitempty"`
+    Code          string    `json:"code,omitempty"`
+    Error         string    `json:"error"`
+}
+
+func newReservationConflict(start time.Time, id string, e *bookingError) reservationConflict {
+    return reservationConflict{Start: start, ReservationID: id, Code: e.code, Error: e.msg}
+}
+
+// CreateSeries books a recurring reservation. Every occurrence is booked like
+// a single reservation; those that cannot be are reported as conflicts and
+// the rest are kept. Nothing is stored if no occurrence can be booked.
+func (h *ReservationHandler) CreateSeries(w http.ResponseWriter, r *http.Request) {
+    rid := router.Param(r, "id")
+    restaurant, err := h.restaurants.ByID(rid)
+    if err != nil {
+        notFound(w, "restaurant not found")
+        return
+    }
+    if e := archivedError(restaurant); e != nil {
+        e.write(w)
+        return
+    }
+    claims := middleware.ClaimsFromContext(r)
+    if claims == nil {
+        unauthorized(w, "no auth")
+        return
+    }
+    var req createSeriesReq
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        badRequest(w, "invalid json")
+        return
+    }
+    if req.Start.IsZero() || req.Guests <= 0 {
+        badRequest(w, "start and guests are required")
+        return
+    }
+    if req.End.IsZero() {
+        req.End = req.Start.Add(restaurant.Settings.DurationFor(req.Guests))
+    }
+    if !req.End.After(req.Start) {
+        badRequest(w, "invalid time range or guests")
+        return
+    }
+    if req.End.Sub(req.Start) > maxBookingDuration {
+        badRequest(w, msgTooLong)
+        return
+    }
+    loc := restaurant.Location()
+    rule, err := models.ParseRecurrenceRule(req.Rule, loc)
+    if err != nil {
+        badRequest(w, "invalid rule: "+err.Error())
+        return
+    }
+    if rule.Count > maxSeriesOccurrences {
+        badRequest(w, "a series can have at most "+strconv.Itoa(maxSeriesOccurrences)+" occurrences")
+        return
+    }
+    duration := req.End.Sub(req.Start)
+    series := &models.ReservationSeries{
+        RestaurantID:    rid,
+        UserID:          claims.Sub,
+        Rule:            req.Rule,
+        FirstStart:      req.Start,
+        DurationMinutes: int(duration / time.Minute),
+        Guests:          req.Guests,
+        TableID:         req.Table,
+        CombinationID:   req.Combination,
+    }
+    if err := h.series.Create(series); err != nil {
+        badRequest(w, "could not create series")
+        return
+    }
+    booked := []*models.Reservation{}
+    conflicts := []reservationConflict{}
+    for _, start := range rule.Occurrences(req.Start.In(loc), maxSeriesOccurrences) {
+        one := createReservationReq{Start: start, End: start.Add(duration), Guests: req.Guests, Table: req.Table, Combination: req.Combination}
+        res, e := h.place(restaurant, one, models.Reservation{UserID: claims.Sub, Status: models.StatusConfirmed, SeriesID: series.ID})
+        if e != nil {
+            conflicts = append(conflicts, newReservationConflict(start, "", e))
+            continue
+        }
+        booked = append(booked, res)
+    }
+    if len(booked) == 0 {
+        _ = h.series.Delete(series.ID)
+        writeJSON(w, http.StatusConflict, map[string]any{"error": "no occurrence of the series could be booked", "conflicts": conflicts})
+        return
+    }
+    writeJSON(w, http.StatusCreated, map[string]any{"series": series, "reservations": booked, "conflicts": conflicts})
+}
+
+// seriesFor loads the series named in the path for its owner or an admin,
+// writing the error response and returning nil otherwise.
+func (h *ReservationHandler) seriesFor(w http.ResponseWriter, r *http.Request) (*models.ReservationSeries, bool) {
+    claims := middleware.ClaimsFromContext(r)
+    if claims == nil {
+        unauthorized(w, "no auth")
+        return nil, false
+    }
+    series, err := h.series.ByID(router.Param(r, "id"))
+    if err != nil {
+        notFound(w, "series not found")
+        return nil, false
+    }
+    if claims.Role != "admin" && series.UserID != claims.Sub {
+        forbidden(w, "not allowed")
+        return nil, false
+    }
+    return series, claims.Role == "admin"
+}
+
+// GetSeries returns a series with all of its occurrences.
+func (h *ReservationHandler) GetSeries(w http.ResponseWriter, r *http.Request) {
+    series, _ := h.seriesFor(w, r)
+    if series == nil {
+        return
+    }
+    list, err := h.reservations.ListBySeries(series.ID)
+    if err != nil {
+        badRequest(w, "could not load reservations")
+        return
+    }
+    writeJSON(w, http.StatusOK, map[string]any{"series": series, "reservations": list})
+}
+
+// fromParam reads the optional ?from= cutoff for changes to a series; it
+// defaults to now.
+func fromParam(r *http.Request) (time.Time, error) {
+    if v := r.URL.Query().Get("from"); v != "" {
+        return time.Parse(time.RFC3339, v)
+    }
+    return time.Now(), nil
+}
+
+// CancelSeries cancels every occurrence starting at or after ?from= (default
+// now) under the cancellation policy. Occurrences that cannot be cancelled
+// are reported; the series itself is cancelled once no occurrence holds a
+// table any more.
+func (h *ReservationHandler) CancelSeries(w http.ResponseWriter, r *http.Request) {
+    series, admin := h.seriesFor(w, r)
+    if series == nil {
+        return
+    }
+    from, err := fromParam(r)
+    if err != nil {
+        badRequest(w, "from must be RFC3339")
+        return
+    }
+    var req cancelReservationReq
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+        badRequest(w, "invalid json")
+        return
+    }
+    if req.Reason == "" {
+        req.Reason = r.URL.Query().Get("reason")
+    }
+    list, err := h.reservations.ListBySeries(series.ID)
+    if err != nil {
+        badRequest(w, "could not load reservations")
+        return
+    }
+    cancelled := []string{}
+    skipped := []reservationConflict{}
+    remaining := 0
+    for _, res := range list {
+        if !models.HoldsTable(res.Status) {
+            continue
+        }
+        if res.StartTime.Before(from) {
+            remaining++
+            continue
+        }
+        if _, e := h.cancelUnderPolicy(res, req.Reason, admin); e != nil {
+            skipped = append(skipped, newReservationConflict(res.StartTime, res.ID, e))
+            remaining++
+            continue
+        }
+        cancelled = append(cancelled, res.ID)
+    }
+    if remaining == 0 && series.Status != models.SeriesCancelled {
+        series.Status = models.SeriesCancelled
+        if err := h.series.Update(series); err != nil {
+            badRequest(w, "could not update series")
+            return
+        }
+    }
+    writeJSON(w, http.StatusOK, map[string]any{"series": series, "cancelled": cancelled, "skipped": skipped})
+}
+
+type updateSeriesReq struct {
+    From            *time.Time `json:"from"` // first occurrence to change; defaults to now
+    Time            *string    `json:"time"` // new local start time, "HH:MM"
+    DurationMinutes *int       `json:"durationMinutes"`
+    Guests          *int       `json:"guests"`
+    Table           *string    `json:"tableId"`
+    Combination     *string    `json:"combinationId"`
+}
+
+// UpdateSeries changes the time, length, party size or table of every
+// occurrence starting at or after from, as PATCH /reservations/:id would for
+// each. An occurrence that cannot be moved keeps its booking and is reported.
+func (h *ReservationHandler) UpdateSeries(w http.ResponseWriter, r *http.Request) {
+    series, _ := h.seriesFor(w, r)
+    if series == nil {
+        return
+    }
+    if series.Status == models.SeriesCancelled {
+        conflict(w, "series is cancelled")
+        return
+    }
+    var req updateSeriesReq
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        badRequest(w, "invalid json")
+        return
+    }
+    restaurant, err := h.restaurants.ByID(series.RestaurantID)
+    if err != nil {
+        notFound(w, "restaurant not found")
+        return
+    }
+    loc := restaurant.Location()
+    from := time.Now()
+    if req.From != nil {
+        from = *req.From
+    }
+    hour, minute := -1, 0
+    if req.Time != nil {
+        hour, minute, err = parseTime(*req.Time)
+        if err != nil || hour == 24 {
+            badRequest(w, "time must be HH:MM")
+            return
+        }
+    }
+    if req.DurationMinutes != nil && *req.DurationMinutes <= 0 {
+        badRequest(w, "durationMinutes must be > 0")
+        return
+    }
+    if req.DurationMinutes != nil && time.Duration(*req.DurationMinutes)*time.Minute > maxBookingDuration {
+        badRequest(w, msgTooLong)
+        return
+    }
+    if req.Guests != nil && *req.Guests <= 0 {
+        badRequest(w, "guests must be > 0")
+        return
+    }
+    guests := series.Guests
+    if req.Guests != nil {
+        guests = *req.Guests
+    }
+    var chosen *allocation
+    if req.Table != nil && *req.Table != "" || req.Combination != nil && *req.Combination != "" {
+        var tableID, combinationID string
+        if req.Table != nil {
+            tableID = *req.Table
+        }
+        if req.Combination != nil {
+            combinationID = *req.Combination
+        }
+        a, msg := h.allocationFor(series.RestaurantID, tableID, combinationID)
+        if a == nil {
+            badRequest(w, msg)
+            return
+        }
+        if a.capacity < guests {
+            badRequest(w, "table not available")
+            return
+        }
+        chosen = a
+        series.TableID, series.CombinationID = tableID, combinationID
+    }
+
+    list, err := h.reservations.ListBySeries(series.ID)
+    if err != nil {
+        badRequest(w, "could not load reservations")
+        return
+    }
+    updated := []*models.Reservation{}
+    conflicts := []reservationConflict{}
+    for _, cur := range list {
+        if !models.HoldsTable(cur.Status) || cur.StartTime.Before(from) {
+            continue
+        }
+        next := *cur
+        if hour >= 0 {
+            day := cur.StartTime.In(loc)
+            next.StartTime = time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, loc)
+        }
+        next.EndTime = next.StartTime.Add(cur.EndTime.Sub(cur.StartTime))
+        if req.DurationMinutes != nil {
+            next.EndTime = next.StartTime.Add(time.Duration(*req.DurationMinutes) * time.Minute)
+        }
+        next.Guests = guests
+        if e := h.move(restaurant, cur, &next, chosen, hour >= 0 || req.Guests != nil, ""); e != nil {
+            conflicts = append(conflicts, newReservationConflict(cur.StartTime, cur.ID, e))
+            continue
+        }
+        if res, err := h.reservations.ByID(cur.ID); err == nil {
+            updated = append(updated, res)
+        }
+    }
+
+    if hour >= 0 {
+        first := series.FirstStart.In(loc)
+        series.FirstStart = time.Date(first.Year(), first.Month(), first.Day(), hour, minute, 0, 0, loc)
+    }
+    if req.DurationMinutes != nil {
+        series.DurationMinutes = *req.DurationMinutes
+    }
+    series.Guests = guests
+    if err := h.series.Update(series); err != nil {
+        badRequest(w, "could not update series")
+        return
+    }
+    writeJSON(w, http.StatusOK, map[string]any{"series": series, "reservations": updated, "conflicts": conflicts})
+}
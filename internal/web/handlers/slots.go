@@ -0,0 +1,228 @@
+package handlers
+
+import (
+    "net/http"
+    "strconv"
+    "time"
+
+    "orderation/internal/models"
+    "orderation/internal/store"
+    "orderation/internal/web/router"
+)
+
+const defaultSlotInterval = 15 * time.Minute
+
+// occupancy indexes a restaurant's table-holding reservations by table, so
+// many candidate times can be checked against a single store query.
+type occupancy struct {
+    byTable  map[string][]interval
+    turnover time.Duration // cleanup time a new booking needs after its end
+}
+
+// loadOccupancy fetches every reservation of the restaurant that overlaps
+// [from, to) plus turnover in one query, ignoring excludeID (if set). Tables
+// blocked out of service count as occupied for the block.
+func (h *ReservationHandler) loadOccupancy(restaurant *models.Restaurant, from, to time.Time, excludeID string) (*occupancy, error) {
+    turnover := restaurant.Settings.Turnover()
+    list, err := h.reservations.ListOverlap(store.ReservationFilter{
+        RestaurantID: restaurant.ID,
+        ExcludeID:    excludeID,
+        StartBefore:  from,
+        EndAfter:     to.Add(turnover),
+    })
+    if err != nil {
+        return nil, err
+    }
+    now := time.Now()
+    o := &occupancy{byTable: map[string][]interval{}, turnover: turnover}
+    for _, r := range list {
+        iv := interval{start: r.StartTime, end: r.ClearAt(now)}
+        for _, tid := range r.Tables() {
+            o.byTable[tid] = append(o.byTable[tid], iv)
+        }
+    }
+    blocks, err := h.blocks.ListByRestaurant(restaurant.ID, from, to.Add(turnover))
+    if err != nil {
+        return nil, err
+    }
+    for _, b := range blocks {
+        o.byTable[b.TableID] = append(o.byTable[b.TableID], interval{start: b.Start, end: b.End})
+    }
+    return o, nil
+}
+
+// outOfService reports whether any of the tables is blocked during
+// [start, end). The atomic booking step refuses blocked tables as well; an
+// explicitly chosen table is checked here first so it is turned down before
+// any insert or move is tried.
+func (h *ReservationHandler) outOfService(restaurantID string, tableIDs []string, start, end time.Time) bool {
+    blocks, err := h.blocks.ListByRestaurant(restaurantID, start, end)
+    if err != nil {
+        return true
+    }
+    for _, b := range blocks {
+        for _, id := range tableIDs {
+            if b.TableID == id {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+// free reports whether the table has nothing booked overlapping [start, end)
+// once both sides' turnover time is included.
+func (o *occupancy) free(tableID string, start, end time.Time) bool {
+    end = end.Add(o.turnover)
+    for _, iv := range o.byTable[tableID] {
+        if iv.start.Before(end) && iv.end.After(start) {
+            return false
+        }
+    }
+    return true
+}
+
+// freeAll reports whether every one of the tables is free during [start, end).
+func (o *occupancy) freeAll(tableIDs []string, start, end time.Time) bool {
+    for _, id := range tableIDs {
+        if !o.free(id, start, end) {
+            return false
+        }
+    }
+    return true
+}
+
+type slotResp struct {
+    Start           time.Time `json:"start"`
+    End             time.Time `json:"end"`
+    AvailableTables int       `json:"availableTables"` // free tables, or free combinations when no single table fits
+}
+
+// Slots lists every bookable start time on a local date for a party size:
+// GET /restaurants/:id/slots?date=YYYY-MM-DD&guests=4&duration=120&interval=15
+// (duration and interval in minutes; duration defaults to the restaurant's
+// dining time for the party size). With accessible=true only
+// wheelchair-accessible tables count. Starts the restaurant's booking rules
+// would refuse are left out.
+func (h *ReservationHandler) Slots(w http.ResponseWriter, r *http.Request) {
+    rid := router.Param(r, "id")
+    restaurant, err := h.restaurants.ByID(rid)
+    if err != nil {
+        notFound(w, "restaurant not found")
+        return
+    }
+    if e := archivedError(restaurant); e != nil {
+        e.write(w)
+        return
+    }
+    q := r.URL.Query()
+    loc := restaurant.Location()
+    date, err := time.ParseInLocation(dateLayout, q.Get("date"), loc)
+    if err != nil {
+        badRequest(w, "date must be YYYY-MM-DD")
+        return
+    }
+    guests, err := strconv.Atoi(q.Get("guests"))
+    if err != nil || guests <= 0 {
+        badRequest(w, "guests must be a positive number")
+        return
+    }
+    if v := partySizeViolations(restaurant.Settings.BookingRules, guests); len(v) > 0 {
+        (&bookingError{status: http.StatusBadRequest, code: codeBookingRules, msg: v[0].Message, violations: v}).write(w)
+        return
+    }
+    duration, ok := minutesParam(q.Get("duration"), restaurant.Settings.DurationFor(guests), 15, 12*60)
+    if !ok {
+        badRequest(w, "duration must be between 15 and 720 minutes")
+        return
+    }
+    step, ok := minutesParam(q.Get("interval"), defaultSlotInterval, 5, 120)
+    if !ok {
+        badRequest(w, "interval must be between 5 and 120 minutes")
+        return
+    }
+
+    dayStart := date
+    dayEnd := date.AddDate(0, 0, 1)
+    sched, err := loadSchedule(h.hours, h.exceptions, restaurant, dayStart, dayEnd.Add(duration))
+    if err != nil {
+        badRequest(w, "could not load opening hours")
+        return
+    }
+    needs := seatingNeeds{accessible: q.Get("accessible") == "true"}
+    tables, _ := h.tables.ListByRestaurant(rid)
+    var fitting []*models.Table
+    for _, t := range tables {
+        if t.Capacity >= guests && needs.admits(t) {
+            fitting = append(fitting, t)
+        }
+    }
+    combos, _ := h.combinations.ListByRestaurant(rid)
+    var fittingCombos []*models.TableCombination
+    for _, c := range combos {
+        if c.Capacity >= guests && len(c.TableIDs) > 0 && h.admitsAll(needs, c.TableIDs) {
+            fittingCombos = append(fittingCombos, c)
+        }
+    }
+    occ, err := h.loadOccupancy(restaurant, dayStart, dayEnd.Add(duration), "")
+    if err != nil {
+        badRequest(w, "could not load reservations")
+        return
+    }
+    pace, err := h.loadPacing(restaurant, dayStart, dayEnd, "")
+    if err != nil {
+        badRequest(w, "could not load reservations")
+        return
+    }
+
+    now := time.Now()
+    slots := []slotResp{}
+    for _, iv := range sched.intervals(dayStart, dayEnd) {
+        start := iv.start
+        if start.Before(dayStart) {
+            // a period carried over from the previous evening; keep its grid
+            start = start.Add(dayStart.Sub(start).Truncate(step))
+            if start.Before(dayStart) {
+                start = start.Add(step)
+            }
+        }
+        for ; start.Before(dayEnd) && !start.Add(duration).After(iv.end); start = start.Add(step) {
+            // skip starts the booking rules (lead time, advance window,
+            // start interval) would refuse
+            if len(checkBookingRules(restaurant, start, guests, now)) > 0 || !pace.allows(start, guests) {
+                continue
+            }
+            end := start.Add(duration)
+            n := 0
+            for _, t := range fitting {
+                if occ.free(t.ID, start, end) {
+                    n++
+                }
+            }
+            if n == 0 {
+                for _, c := range fittingCombos {
+                    if occ.freeAll(c.TableIDs, start, end) {
+                        n++
+                    }
+                }
+            }
+            if n > 0 {
+                slots = append(slots, slotResp{Start: start, End: end, AvailableTables: n})
+            }
+        }
+    }
+    writeJSON(w, http.StatusOK, slots)
+}
+
+// minutesParam parses an optional query value in minutes, returning def when
+// it is empty and ok=false when it is not a number within [min, max].
+func minutesParam(v string, def time.Duration, min, max int) (time.Duration, bool) {
+    if v == "" {
+        return def, true
+    }
+    n, err := strconv.Atoi(v)
+    if err != nil || n < min || n > max {
+        return 0, false
+    }
+    return time.Duration(n) * time.Minute, true
+}
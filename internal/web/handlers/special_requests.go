@@ -0,0 +1,119 @@
+package handlers
+
+import (
+    "net/http"
+    "net/url"
+    "strings"
+    "time"
+
+    "orderation/internal/models"
+    "orderation/internal/web/router"
+)
+
+// SpecialRequestOptions lists the occasions, dietary restrictions and
+// accessibility needs a reservation can carry.
+func (h *ReservationHandler) SpecialRequestOptions(w http.ResponseWriter, r *http.Request) {
+    writeJSON(w, http.StatusOK, map[string][]string{
+        "occasions":     models.Occasions,
+        "dietary":       models.DietaryRestrictions,
+        "accessibility": models.AccessibilityNeeds,
+    })
+}
+
+// ListRestaurantReservations shows staff the reservations starting on one
+// day (?date=, default today in the restaurant's time zone), optionally
+// filtered by status and special requests, e.g. ?dietary=allergy for every
+// party with an allergy.
+func (h *ReservationHandler) ListRestaurantReservations(w http.ResponseWriter, r *http.Request) {
+    restaurant, err := h.restaurants.ByID(router.Param(r, "id"))
+    if err != nil {
+        notFound(w, "restaurant not found")
+        return
+    }
+    q := r.URL.Query()
+    loc := restaurant.Location()
+    day := time.Now().In(loc)
+    if v := q.Get("date"); v != "" {
+        if day, err = time.ParseInLocation(dateLayout, v, loc); err != nil {
+            badRequest(w, "date must be YYYY-MM-DD")
+            return
+        }
+    }
+    from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc)
+    list, err := h.reservations.ListByRestaurant(restaurant.ID, from, from.AddDate(0, 0, 1))
+    if err != nil {
+        badRequest(w, "could not load reservations")
+        return
+    }
+    out := make([]*models.Reservation, 0, len(list))
+    for _, res := range list {
+        if s := q.Get("status"); s != "" && res.Status != s {
+            continue
+        }
+        if matchesSpecialRequests(q, res.SpecialRequests) {
+            out = append(out, res)
+        }
+    }
+    writeJSON(w, http.StatusOK, out)
+}
+
+// matchesSpecialRequests applies the ?occasion=, ?dietary=, ?accessibility=
+// and ?notes= filters. Each takes a code from the fixed lists or "any";
+// dietary also takes "allergy" and notes only "any".
+func matchesSpecialRequests(q url.Values, sr models.SpecialRequests) bool {
+    if v := q.Get("occasion"); v != "" && !(sr.Occasion != "" && (v == "any" || strings.EqualFold(v, sr.Occasion))) {
+        return false
+    }
+    if v := q.Get("dietary"); v != "" && !(v == "allergy" && sr.HasAllergy() || matchesCode(v, sr.Dietary)) {
+        return false
+    }
+    if v := q.Get("accessibility"); v != "" && !matchesCode(v, sr.Accessibility) {
+        return false
+    }
+    if v := q.Get("notes"); v != "" && sr.Notes == "" {
+        return false
+    }
+    return true
+}
+
+func matchesCode(filter string, codes []string) bool {
+    for _, c := range codes {
+        if filter == "any" || strings.EqualFold(filter, c) {
+            return true
+        }
+    }
+    return false
+}
+
+// LookupUpdateRequests replaces the special requests of a guest booking
+// identified like Lookup, moving it to an accessible table if they now
+// need one.
+func (h *ReservationHandler) LookupUpdateRequests(w http.ResponseWriter, r *http.Request) {
+    var req lookupReq
+    res := h.guestReservation(w, r, &req)
+    if res == nil {
+        return
+    }
+    if !models.HoldsTable(res.Status) {
+        conflict(w, "reservation is "+res.Status+" and can no longer be changed")
+        return
+    }
+    if err := req.SpecialRequests.Normalize(); err != nil {
+        badRequest(w, err.Error())
+        return
+    }
+    if e := h.reseat(res, req.SpecialRequests); e != nil {
+        e.write(w)
+        return
+    }
+    if err := h.reservations.UpdateSpecialRequests(res.ID, req.SpecialRequests); err != nil {
+        badRequest(w, "could not update reservation")
+        return
+    }
+    updated, err := h.reservations.ByID(res.ID)
+    if err != nil {
+        notFound(w, "reservation not found")
+        return
+    }
+    writeJSON(w, http.StatusOK, updated)
+}
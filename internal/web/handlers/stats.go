@@ -0,0 +1,211 @@
+package handlers
+
+import (
+    "math"
+    "net/http"
+    "time"
+
+    "orderation/internal/models"
+    "orderation/internal/web/router"
+)
+
+// maxStatsDays caps the date range of one statistics request.
+const maxStatsDays = 366
+
+// statusTotals sums the per-status counts of a range of reservations.
+type statusTotals struct {
+    byStatus map[string]int // parties
+    total    int
+    holding  int // still holding a table, see models.HoldsTable
+    covers   int // guests of booked parties, see models.Booked
+}
+
+func (h *RestaurantHandler) countRange(rid string, from, to time.Time) (statusTotals, error) {
+    t := statusTotals{byStatus: map[string]int{}}
+    counts, err := h.reservations.CountByStatus(rid, from, to)
+    if err != nil {
+        return t, err
+    }
+    for _, c := range counts {
+        t.byStatus[c.Status] = c.Parties
+        t.total += c.Parties
+        if models.HoldsTable(c.Status) {
+            t.holding += c.Parties
+        }
+        if models.Booked(c.Status) {
+            t.covers += c.Guests
+        }
+    }
+    return t, nil
+}
+
+// openTableMinutes is the table time in [start, end): the minutes each of
+// tables was in use, from when it was added until it was deleted.
+func openTableMinutes(tables []*models.Table, start, end time.Time) int {
+    n := 0
+    for _, t := range tables {
+        s, e := latestTime(start, t.CreatedAt), end
+        if t.DeletedAt != nil {
+            e = earliestTime(e, *t.DeletedAt)
+        }
+        if e.After(s) {
+            n += int(e.Sub(s) / time.Minute)
+        }
+    }
+    return n
+}
+
+// utilization compares the table time booked in [from, to) with the table
+// time the restaurant is open, and returns both in minutes along with the
+// booked share as a percentage. Open time counts the tables there were at the
+// time, so tables should include deleted ones. Bookings running past closing
+// count in full, so the share can exceed 100.
+func (h *RestaurantHandler) utilization(restaurant *models.Restaurant, tables []*models.Table, from, to time.Time) (int, int, float64, error) {
+    booked, err := h.reservations.TableMinutes(restaurant.ID, from, to)
+    if err != nil {
+        return 0, 0, 0, err
+    }
+    sched, err := loadSchedule(h.hours, h.exceptions, restaurant, from, to)
+    if err != nil {
+        return 0, 0, 0, err
+    }
+    open := 0
+    for _, iv := range sched.intervals(from, to) {
+        open += openTableMinutes(tables, latestTime(iv.start, from), earliestTime(iv.end, to))
+    }
+    return booked, open, percent(booked, open), nil
+}
+
+// percent is part as a percentage of whole to one decimal place, 0 if whole
+// is 0.
+func percent(part, whole int) float64 {
+    if whole == 0 {
+        return 0
+    }
+    return math.Round(float64(part)*1000/float64(whole)) / 10
+}
+
+// dayStart is local midnight on t's date; weekStart is the Monday of its week.
+func dayStart(t time.Time) time.Time {
+    return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func weekStart(t time.Time) time.Time {
+    return dayStart(t).AddDate(0, 0, -(int(t.Weekday())+6)%7)
+}
+
+// currentStats are the figures for today and this week shown with the
+// restaurant details and the statistics.
+type currentStats struct {
+    CoversToday          int     `json:"coversToday"`
+    CoversThisWeek       int     `json:"coversThisWeek"`
+    UpcomingReservations int     `json:"upcomingReservations"` // holding a table and not started yet
+    UtilizationToday     float64 `json:"utilizationToday"`     // percent of today's open table time booked
+}
+
+func (h *RestaurantHandler) current(restaurant *models.Restaurant, tables []*models.Table, now time.Time) (currentStats, error) {
+    var c currentStats
+    today := dayStart(now.In(restaurant.Location()))
+    week := weekStart(today)
+    t, err := h.countRange(restaurant.ID, today, today.AddDate(0, 0, 1))
+    if err != nil {
+        return c, err
+    }
+    c.CoversToday = t.covers
+    if t, err = h.countRange(restaurant.ID, week, week.AddDate(0, 0, 7)); err != nil {
+        return c, err
+    }
+    c.CoversThisWeek = t.covers
+    if t, err = h.countRange(restaurant.ID, now, now.AddDate(upcomingYears, 0, 0)); err != nil {
+        return c, err
+    }
+    c.UpcomingReservations = t.holding
+    if _, _, c.UtilizationToday, err = h.utilization(restaurant, tables, today, today.AddDate(0, 0, 1)); err != nil {
+        return c, err
+    }
+    return c, nil
+}
+
+// dateRange reads the local dates ?from= and ?to= (YYYY-MM-DD, inclusive)
+// as [from, to). It defaults to the week of now, Monday to Sunday, or to
+// seven days from ?from=. The error response is written if they are invalid.
+func dateRange(w http.ResponseWriter, r *http.Request, restaurant *models.Restaurant, now time.Time) (time.Time, time.Time, bool) {
+    loc := restaurant.Location()
+    from := weekStart(now.In(loc))
+    var err error
+    if v := r.URL.Query().Get("from"); v != "" {
+        if from, err = time.ParseInLocation(dateLayout, v, loc); err != nil {
+            badRequest(w, "from must be YYYY-MM-DD")
+            return from, from, false
+        }
+    }
+    to := from.AddDate(0, 0, 7)
+    if v := r.URL.Query().Get("to"); v != "" {
+        last, err := time.ParseInLocation(dateLayout, v, loc)
+        if err != nil {
+            badRequest(w, "to must be YYYY-MM-DD")
+            return from, to, false
+        }
+        to = last.AddDate(0, 0, 1)
+    }
+    if !to.After(from) || to.After(from.AddDate(0, 0, maxStatsDays)) {
+        badRequest(w, "to must be on or after from and at most a year later")
+        return from, to, false
+    }
+    return from, to, true
+}
+
+type statsResp struct {
+    From               string         `json:"from"` // local dates, inclusive
+    To                 string         `json:"to"`
+    TotalReservations  int            `json:"totalReservations"`
+    ByStatus           map[string]int `json:"byStatus"`
+    Covers             int            `json:"covers"`
+    BookedTableMinutes int            `json:"bookedTableMinutes"`
+    OpenTableMinutes   int            `json:"openTableMinutes"`
+    Utilization        float64        `json:"utilization"` // percent
+    currentStats
+}
+
+// Stats reports reservations by status, covers and table utilization for
+// the reservations starting between the local dates ?from= and ?to=
+// (YYYY-MM-DD, inclusive; default this week, Monday to Sunday), along with
+// today's and this week's covers and the upcoming reservations.
+func (h *RestaurantHandler) Stats(w http.ResponseWriter, r *http.Request) {
+    restaurant, err := h.restaurants.ByID(router.Param(r, "id"))
+    if err != nil {
+        notFound(w, "restaurant not found")
+        return
+    }
+    now := time.Now()
+    from, to, ok := dateRange(w, r, restaurant, now)
+    if !ok {
+        return
+    }
+    tables, err := h.tables.ListAllByRestaurant(restaurant.ID)
+    if err != nil {
+        internalError(w, "could not load tables")
+        return
+    }
+    t, err := h.countRange(restaurant.ID, from, to)
+    if err != nil {
+        internalError(w, "could not count reservations")
+        return
+    }
+    resp := statsResp{
+        From:              from.Format(dateLayout),
+        To:                to.AddDate(0, 0, -1).Format(dateLayout),
+        TotalReservations: t.total,
+        ByStatus:          t.byStatus,
+        Covers:            t.covers,
+    }
+    if resp.BookedTableMinutes, resp.OpenTableMinutes, resp.Utilization, err = h.utilization(restaurant, tables, from, to); err != nil {
+        internalError(w, "could not compute utilization")
+        return
+    }
+    if resp.currentStats, err = h.current(restaurant, tables, now); err != nil {
+        internalError(w, "could not count reservations")
+        return
+    }
+    writeJSON(w, http.StatusOK, resp)
+}
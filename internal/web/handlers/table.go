@@ -4,6 +4,8 @@ import (
     "encoding/json"
     "net/http"
     "strconv"
+    "strings"
+    "time"
 
     "orderation/internal/models"
     "orderation/internal/store"
@@ -11,17 +13,25 @@ import (
 )
 
 type TableHandler struct {
-    restaurants store.RestaurantStore
-    tables      store.TableStore
+    restaurants  store.RestaurantStore
+    tables       store.TableStore
+    combinations store.TableCombinationStore
+    blocks       store.TableBlockStore
+    bookings     *ReservationHandler // moves reservations off a table being changed
 }
 
-func NewTableHandler(rest store.RestaurantStore, tables store.TableStore) *TableHandler {
-    return &TableHandler{restaurants: rest, tables: tables}
+func NewTableHandler(rest store.RestaurantStore, tables store.TableStore, combinations store.TableCombinationStore, blocks store.TableBlockStore, bookings *ReservationHandler) *TableHandler {
+    return &TableHandler{restaurants: rest, tables: tables, combinations: combinations, blocks: blocks, bookings: bookings}
 }
 
 type createTableReq struct {
-    Name     string `json:"name"`
-    Capacity int    `json:"capacity"`
+    Name       string `json:"name"`
+    Capacity   int    `json:"capacity"`
+    Section    string `json:"section"`    // e.g. patio, bar, main
+    Accessible bool   `json:"accessible"` // wheelchair accessible
+    HighTop    bool   `json:"highTop"`
+    Booth      bool   `json:"booth"`
+    Smoking    bool   `json:"smoking"`
 }
 
 func (h *TableHandler) Create(w http.ResponseWriter, r *http.Request) {
@@ -39,7 +49,16 @@ func (h *TableHandler) Create(w http.ResponseWriter, r *http.Request) {
         badRequest(w, "capacity must be > 0")
         return
     }
-    t := &models.Table{RestaurantID: rid, Name: req.Name, Capacity: req.Capacity}
+    t := &models.Table{
+        RestaurantID: rid,
+        Name:         req.Name,
+        Capacity:     req.Capacity,
+        Section:      strings.ToLower(strings.TrimSpace(req.Section)),
+        Accessible:   req.Accessible,
+        HighTop:      req.HighTop,
+        Booth:        req.Booth,
+        Smoking:      req.Smoking,
+    }
     if err := h.tables.Create(t); err != nil {
         badRequest(w, "could not create table")
         return
@@ -47,6 +66,172 @@ func (h *TableHandler) Create(w http.ResponseWriter, r *http.Request) {
     writeJSON(w, http.StatusCreated, t)
 }
 
+// tableFor loads the table named in the path, writing a 404 for unknown
+// and deleted tables.
+func (h *TableHandler) tableFor(w http.ResponseWriter, r *http.Request) *models.Table {
+    t, err := h.tables.ByID(router.Param(r, "id"))
+    if err != nil || t.DeletedAt != nil {
+        notFound(w, "table not found")
+        return nil
+    }
+    return t
+}
+
+// Update replaces a table's name, capacity and attributes. Upcoming
+// reservations the table would no longer fit, by size or accessibility, are
+// handled as by clearTable.
+func (h *TableHandler) Update(w http.ResponseWriter, r *http.Request) {
+    t := h.tableFor(w, r)
+    if t == nil {
+        return
+    }
+    var req createTableReq
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        badRequest(w, "invalid json")
+        return
+    }
+    if req.Capacity <= 0 {
+        badRequest(w, "capacity must be > 0")
+        return
+    }
+    next := *t
+    next.Name, next.Capacity = req.Name, req.Capacity
+    next.Section = strings.ToLower(strings.TrimSpace(req.Section))
+    next.Accessible, next.HighTop, next.Booth, next.Smoking = req.Accessible, req.HighTop, req.Booth, req.Smoking
+
+    now := time.Now()
+    upcoming, err := h.upcoming(t.ID, now, now.AddDate(upcomingYears, 0, 0))
+    if err != nil {
+        badRequest(w, "could not load reservations")
+        return
+    }
+    var misfits []*models.Reservation
+    for _, res := range upcoming {
+        // a combination's capacity does not change with its tables
+        if res.CombinationID == "" && res.Guests > next.Capacity || res.NeedsAccessibleTable() && !next.Accessible {
+            misfits = append(misfits, res)
+        }
+    }
+    if !h.clearTable(w, r, t.ID, misfits) {
+        return
+    }
+    if err := h.tables.Update(&next); err != nil {
+        badRequest(w, "could not update table")
+        return
+    }
+    writeJSON(w, http.StatusOK, next)
+}
+
+// Delete takes a table out of use. Past reservations keep pointing at it;
+// upcoming ones are handled as by clearTable. Tables that are part of a
+// combination cannot be deleted until the combination is.
+func (h *TableHandler) Delete(w http.ResponseWriter, r *http.Request) {
+    t := h.tableFor(w, r)
+    if t == nil {
+        return
+    }
+    combos, err := h.combinations.ListByRestaurant(t.RestaurantID)
+    if err != nil {
+        badRequest(w, "could not load combinations")
+        return
+    }
+    for _, c := range combos {
+        for _, id := range c.TableIDs {
+            if id == t.ID {
+                writeError(w, http.StatusConflict, "table_in_combination", "table is part of combination "+c.ID+"; delete the combination first")
+                return
+            }
+        }
+    }
+    now := time.Now()
+    upcoming, err := h.upcoming(t.ID, now, now.AddDate(upcomingYears, 0, 0))
+    if err != nil {
+        badRequest(w, "could not load reservations")
+        return
+    }
+    if !h.clearTable(w, r, t.ID, upcoming) {
+        return
+    }
+    if err := h.tables.Delete(t.ID, now); err != nil {
+        badRequest(w, "could not delete table")
+        return
+    }
+    writeJSON(w, http.StatusOK, map[string]any{"status": "deleted", "reassigned": len(upcoming)})
+}
+
+// upcomingYears is how far ahead Update and Delete look for reservations on
+// a table.
+const upcomingYears = 10
+
+// upcoming lists the reservations holding the table during [from, to).
+func (h *TableHandler) upcoming(tableID string, from, to time.Time) ([]*models.Reservation, error) {
+    return h.bookings.reservations.ListOverlap(store.ReservationFilter{TableID: tableID, StartBefore: from, EndAfter: to})
+}
+
+// clearTable deals with the reservations in the way of a change to a table.
+// Without ?reassign=true it refuses with 409 and their IDs; with it, they
+// are moved to other tables, or none is and the change is refused if some
+// cannot be. It reports whether the change may go ahead.
+func (h *TableHandler) clearTable(w http.ResponseWriter, r *http.Request, tableID string, affected []*models.Reservation) bool {
+    if len(affected) == 0 {
+        return true
+    }
+    if r.URL.Query().Get("reassign") != "true" {
+        ids := make([]string, 0, len(affected))
+        for _, res := range affected {
+            ids = append(ids, res.ID)
+        }
+        writeJSON(w, http.StatusConflict, map[string]any{
+            "error":        "table has reservations; retry with ?reassign=true to move them to other tables",
+            "code":         "table_in_use",
+            "reservations": ids,
+        })
+        return false
+    }
+    if conflicts := h.bookings.reassign(affected, tableID); len(conflicts) > 0 {
+        writeJSON(w, http.StatusConflict, map[string]any{
+            "error":     "some reservations could not be moved to another table",
+            "code":      "reassign_failed",
+            "conflicts": conflicts,
+        })
+        return false
+    }
+    return true
+}
+
+// reassign moves each reservation off tableID, keeping its time and party
+// size, and returns those that could not be moved. Seated parties stay put.
+// Unless every one can be moved, those already moved are put back.
+func (h *ReservationHandler) reassign(list []*models.Reservation, tableID string) []reservationConflict {
+    conflicts := []reservationConflict{}
+    var moved []models.Reservation // as they were before the move
+    for _, cur := range list {
+        if cur.Status == models.StatusSeated {
+            conflicts = append(conflicts, reservationConflict{Start: cur.StartTime, ReservationID: cur.ID, Code: "seated", Error: "party is seated at the table"})
+            continue
+        }
+        restaurant, err := h.restaurants.ByID(cur.RestaurantID)
+        if err != nil {
+            conflicts = append(conflicts, reservationConflict{Start: cur.StartTime, ReservationID: cur.ID, Error: "restaurant not found"})
+            continue
+        }
+        orig, next := *cur, *cur
+        if e := h.move(restaurant, cur, &next, nil, false, tableID); e != nil {
+            conflicts = append(conflicts, newReservationConflict(cur.StartTime, cur.ID, e))
+            continue
+        }
+        moved = append(moved, orig)
+    }
+    if len(conflicts) > 0 {
+        for i := len(moved) - 1; i >= 0; i-- {
+            // their old tables were freed by the move; should one have been
+            // taken meanwhile, the reservation simply stays on its new ones
+            _ = h.reservations.UpdateIfFree(&moved[i])
+        }
+    }
+    return conflicts
+}
+
 func (h *TableHandler) ListByRestaurant(w http.ResponseWriter, r *http.Request) {
     rid := router.Param(r, "id")
     if _, err := h.restaurants.ByID(rid); err != nil {
@@ -54,18 +239,93 @@ func (h *TableHandler) ListByRestaurant(w http.ResponseWriter, r *http.Request)
         return
     }
     list, _ := h.tables.ListByRestaurant(rid)
-    // optional query filter by min capacity
-    if q := r.URL.Query().Get("minCapacity"); q != "" {
-        if n, err := strconv.Atoi(q); err == nil {
-            out := make([]*models.Table, 0, len(list))
-            for _, t := range list {
-                if t.Capacity >= n {
-                    out = append(out, t)
-                }
-            }
-            list = out
+    // optional query filters by min capacity, section and accessibility
+    q := r.URL.Query()
+    minCapacity, _ := strconv.Atoi(q.Get("minCapacity"))
+    section := q.Get("section")
+    accessible := q.Get("accessible") == "true"
+    out := make([]*models.Table, 0, len(list))
+    for _, t := range list {
+        if t.Capacity >= minCapacity && (section == "" || strings.EqualFold(section, t.Section)) && (!accessible || t.Accessible) {
+            out = append(out, t)
+        }
+    }
+    writeJSON(w, http.StatusOK, out)
+}
+
+type createCombinationReq struct {
+    Name     string   `json:"name"`
+    TableIDs []string `json:"tableIds"`
+    Capacity int      `json:"capacity"`
+}
+
+// CreateCombination registers a set of tables that can be joined for one
+// large party. Capacity defaults to the sum of the member tables.
+func (h *TableHandler) CreateCombination(w http.ResponseWriter, r *http.Request) {
+    rid := router.Param(r, "id")
+    if _, err := h.restaurants.ByID(rid); err != nil {
+        notFound(w, "restaurant not found")
+        return
+    }
+    var req createCombinationReq
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        badRequest(w, "invalid json")
+        return
+    }
+    if len(req.TableIDs) < 2 {
+        badRequest(w, "a combination needs at least two tables")
+        return
+    }
+    seen := map[string]bool{}
+    sum := 0
+    for _, id := range req.TableIDs {
+        t, err := h.tables.ByID(id)
+        if err != nil || t.RestaurantID != rid || t.DeletedAt != nil {
+            badRequest(w, "invalid tableId "+id)
+            return
+        }
+        if seen[id] {
+            badRequest(w, "duplicate tableId "+id)
+            return
         }
+        seen[id] = true
+        sum += t.Capacity
+    }
+    if req.Capacity < 0 {
+        badRequest(w, "capacity must be > 0")
+        return
+    }
+    if req.Capacity == 0 {
+        req.Capacity = sum
+    }
+    c := &models.TableCombination{RestaurantID: rid, Name: req.Name, TableIDs: req.TableIDs, Capacity: req.Capacity}
+    if err := h.combinations.Create(c); err != nil {
+        badRequest(w, "could not create combination")
+        return
+    }
+    writeJSON(w, http.StatusCreated, c)
+}
+
+func (h *TableHandler) ListCombinations(w http.ResponseWriter, r *http.Request) {
+    rid := router.Param(r, "id")
+    if _, err := h.restaurants.ByID(rid); err != nil {
+        notFound(w, "restaurant not found")
+        return
     }
+    list, _ := h.combinations.ListByRestaurant(rid)
     writeJSON(w, http.StatusOK, list)
 }
 
+func (h *TableHandler) DeleteCombination(w http.ResponseWriter, r *http.Request) {
+    rid := router.Param(r, "id")
+    c, err := h.combinations.ByID(router.Param(r, "combinationId"))
+    if err != nil || c.RestaurantID != rid {
+        notFound(w, "combination not found")
+        return
+    }
+    if err := h.combinations.Delete(c.ID); err != nil {
+        badRequest(w, "could not delete combination")
+        return
+    }
+    writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
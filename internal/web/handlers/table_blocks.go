@@ -0,0 +1,90 @@
+package handlers
+
+import (
+    "encoding/json"
+    "net/http"
+    "time"
+
+    "orderation/internal/models"
+    "orderation/internal/web/router"
+)
+
+type createBlockReq struct {
+    Start  time.Time `json:"start"`
+    End    time.Time `json:"end"`
+    Reason string    `json:"reason"` // e.g. "repair" or "private setup"
+}
+
+// CreateBlock takes a table out of service for [start, end). Reservations
+// holding the table then are handled as by clearTable.
+func (h *TableHandler) CreateBlock(w http.ResponseWriter, r *http.Request) {
+    t := h.tableFor(w, r)
+    if t == nil {
+        return
+    }
+    var req createBlockReq
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        badRequest(w, "invalid json")
+        return
+    }
+    if req.Start.IsZero() || !req.End.After(req.Start) {
+        badRequest(w, "start and end are required and end must be after start")
+        return
+    }
+    affected, err := h.upcoming(t.ID, req.Start, req.End)
+    if err != nil {
+        badRequest(w, "could not load reservations")
+        return
+    }
+    if !h.clearTable(w, r, t.ID, affected) {
+        return
+    }
+    b := &models.TableBlock{RestaurantID: t.RestaurantID, TableID: t.ID, Start: req.Start, End: req.End, Reason: req.Reason}
+    if err := h.blocks.Create(b); err != nil {
+        badRequest(w, "could not create block")
+        return
+    }
+    writeJSON(w, http.StatusCreated, b)
+}
+
+// ListBlocks lists the restaurant's blocks overlapping ?from= to ?to=
+// (RFC3339), by default the next 30 days.
+func (h *TableHandler) ListBlocks(w http.ResponseWriter, r *http.Request) {
+    rid := router.Param(r, "id")
+    if _, err := h.restaurants.ByID(rid); err != nil {
+        notFound(w, "restaurant not found")
+        return
+    }
+    from, err := fromParam(r)
+    if err != nil {
+        badRequest(w, "from must be RFC3339")
+        return
+    }
+    to := from.AddDate(0, 0, 30)
+    if v := r.URL.Query().Get("to"); v != "" {
+        if to, err = time.Parse(time.RFC3339, v); err != nil {
+            badRequest(w, "to must be RFC3339")
+            return
+        }
+    }
+    list, err := h.blocks.ListByRestaurant(rid, from, to)
+    if err != nil {
+        badRequest(w, "could not load blocks")
+        return
+    }
+    writeJSON(w, http.StatusOK, list)
+}
+
+// DeleteBlock puts a blocked table back into service.
+func (h *TableHandler) DeleteBlock(w http.ResponseWriter, r *http.Request) {
+    b, err := h.blocks.ByID(router.Param(r, "id"))
+    if err != nil {
+        notFound(w, "block not found")
+        return
+    }
+    if err := h.blocks.Delete(b.ID); err != nil {
+        badRequest(w, "could not delete block")
+        return
+    }
+    writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
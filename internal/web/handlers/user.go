@@ -0,0 +1,46 @@
+package handlers
+
+import (
+    "encoding/json"
+    "net/http"
+
+    "orderation/internal/store"
+    "orderation/internal/web/router"
+)
+
+type UserHandler struct {
+    users store.UserStore
+}
+
+func NewUserHandler(users store.UserStore) *UserHandler {
+    return &UserHandler{users: users}
+}
+
+type updateLimitsReq struct {
+    LimitExempt *bool `json:"limitExempt"`
+}
+
+// UpdateLimits lets an admin exempt a trusted account from the per-user
+// booking limits, or lift the exemption again.
+func (h *UserHandler) UpdateLimits(w http.ResponseWriter, r *http.Request) {
+    id := router.Param(r, "id")
+    var req updateLimitsReq
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        badRequest(w, "invalid json")
+        return
+    }
+    if req.LimitExempt == nil {
+        badRequest(w, "limitExempt is required")
+        return
+    }
+    if err := h.users.SetLimitExempt(id, *req.LimitExempt); err != nil {
+        notFound(w, "user not found")
+        return
+    }
+    u, err := h.users.ByID(id)
+    if err != nil {
+        notFound(w, "user not found")
+        return
+    }
+    writeJSON(w, http.StatusOK, u)
+}
@@ -29,3 +29,16 @@ func notFound(w http.ResponseWriter, msg string) {
     writeJSON(w, http.StatusNotFound, map[string]string{"error": msg})
 }
 
+func conflict(w http.ResponseWriter, msg string) {
+    writeJSON(w, http.StatusConflict, map[string]string{"error": msg})
+}
+
+func internalError(w http.ResponseWriter, msg string) {
+    writeJSON(w, http.StatusInternalServerError, map[string]string{"error": msg})
+}
+
+// writeError writes an error with a machine-readable code next to the
+// message, for failures clients are expected to handle.
+func writeError(w http.ResponseWriter, status int, code, msg string) {
+    writeJSON(w, status, map[string]string{"error": msg, "code": code})
+}
@@ -0,0 +1,348 @@
+package handlers
+
+import (
+    "crypto/rand"
+    "crypto/subtle"
+    "encoding/hex"
+    "encoding/json"
+    "net/http"
+    "sort"
+    "time"
+
+    "orderation/internal/models"
+    "orderation/internal/web/middleware"
+    "orderation/internal/web/router"
+)
+
+const (
+    // waitlistOfferTTL is how long a waitlisted party has to claim a freed table.
+    waitlistOfferTTL    = 15 * time.Minute
+    // waitlistStep is the spacing of the start times tried within a party's window.
+    waitlistStep        = 15 * time.Minute
+    maxWaitlistWindow   = 12 * time.Hour
+    // maxWaitlistDuration is the longest dining time a party can ask for.
+    maxWaitlistDuration = 12 * time.Hour
+)
+
+type joinWaitlistReq struct {
+    WindowStart     time.Time `json:"windowStart"`
+    WindowEnd       time.Time `json:"windowEnd"` // optional; defaults to WindowStart
+    Guests          int       `json:"guests"`
+    DurationMinutes int       `json:"durationMinutes"` // optional; defaults to the restaurant's dining time
+    AutoBook        bool      `json:"autoBook"`
+}
+
+// JoinWaitlist puts the caller in line for a table starting anywhere in the
+// requested window.
+func (h *ReservationHandler) JoinWaitlist(w http.ResponseWriter, r *http.Request) {
+    rid := router.Param(r, "id")
+    restaurant, err := h.restaurants.ByID(rid)
+    if err != nil {
+        notFound(w, "restaurant not found")
+        return
+    }
+    if e := archivedError(restaurant); e != nil {
+        e.write(w)
+        return
+    }
+    claims := middleware.ClaimsFromContext(r)
+    if claims == nil {
+        unauthorized(w, "no auth")
+        return
+    }
+    var req joinWaitlistReq
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        badRequest(w, "invalid json")
+        return
+    }
+    if req.WindowEnd.IsZero() {
+        req.WindowEnd = req.WindowStart
+    }
+    if req.WindowStart.IsZero() || req.WindowEnd.Before(req.WindowStart) || req.WindowEnd.Sub(req.WindowStart) > maxWaitlistWindow {
+        badRequest(w, "invalid waitlist window")
+        return
+    }
+    if !req.WindowEnd.After(time.Now()) {
+        badRequest(w, "waitlist window is in the past")
+        return
+    }
+    if req.Guests <= 0 || req.DurationMinutes < 0 || req.DurationMinutes > int(maxWaitlistDuration/time.Minute) {
+        badRequest(w, "invalid guests or duration")
+        return
+    }
+    duration := time.Duration(req.DurationMinutes) * time.Minute
+    if duration == 0 {
+        duration = restaurant.Settings.DurationFor(req.Guests)
+    }
+    e := &models.WaitlistEntry{
+        RestaurantID:    rid,
+        UserID:          claims.Sub,
+        Date:            req.WindowStart.In(restaurant.Location()).Format(dateLayout),
+        WindowStart:     req.WindowStart,
+        WindowEnd:       req.WindowEnd,
+        Guests:          req.Guests,
+        DurationMinutes: int(duration / time.Minute),
+        AutoBook:        req.AutoBook,
+        Status:          models.WaitlistWaiting,
+    }
+    if err := h.waitlist.Create(e); err != nil {
+        badRequest(w, "could not join waitlist")
+        return
+    }
+    writeJSON(w, http.StatusCreated, e)
+}
+
+// ListWaitlist shows staff the restaurant's waitlist, optionally for one date.
+func (h *ReservationHandler) ListWaitlist(w http.ResponseWriter, r *http.Request) {
+    rid := router.Param(r, "id")
+    if _, err := h.restaurants.ByID(rid); err != nil {
+        notFound(w, "restaurant not found")
+        return
+    }
+    date := r.URL.Query().Get("date")
+    if date != "" {
+        if _, err := time.Parse(dateLayout, date); err != nil {
+            badRequest(w, "date must be YYYY-MM-DD")
+            return
+        }
+    }
+    list, _ := h.waitlist.ListByRestaurant(rid, date)
+    writeJSON(w, http.StatusOK, list)
+}
+
+func (h *ReservationHandler) ListMyWaitlist(w http.ResponseWriter, r *http.Request) {
+    claims := middleware.ClaimsFromContext(r)
+    if claims == nil {
+        unauthorized(w, "no auth")
+        return
+    }
+    list, _ := h.waitlist.ListByUser(claims.Sub)
+    writeJSON(w, http.StatusOK, list)
+}
+
+// waitlistEntryFor returns a copy of the entry named in the URL if the caller
+// owns it or is an admin, writing the error response otherwise.
+func (h *ReservationHandler) waitlistEntryFor(w http.ResponseWriter, r *http.Request) *models.WaitlistEntry {
+    claims := middleware.ClaimsFromContext(r)
+    if claims == nil {
+        unauthorized(w, "no auth")
+        return nil
+    }
+    e, err := h.waitlist.ByID(router.Param(r, "id"))
+    if err != nil {
+        notFound(w, "waitlist entry not found")
+        return nil
+    }
+    if claims.Role != "admin" && e.UserID != claims.Sub {
+        forbidden(w, "not allowed")
+        return nil
+    }
+    cp := *e
+    return &cp
+}
+
+func (h *ReservationHandler) LeaveWaitlist(w http.ResponseWriter, r *http.Request) {
+    e := h.waitlistEntryFor(w, r)
+    if e == nil {
+        return
+    }
+    if e.Status != models.WaitlistWaiting && e.Status != models.WaitlistOffered {
+        conflict(w, "waitlist entry is already "+e.Status)
+        return
+    }
+    e.Status = models.WaitlistLeft
+    e.ClaimToken = ""
+    if err := h.waitlist.Update(e); err != nil {
+        badRequest(w, "could not leave waitlist")
+        return
+    }
+    writeJSON(w, http.StatusOK, e)
+}
+
+type claimWaitlistReq struct {
+    ClaimToken string `json:"claimToken"`
+}
+
+// ClaimWaitlistOffer books the table offered to a waitlisted party. The
+// offer does not hold the table, so a claim can still lose it to a booking
+// made in the meantime; the party then goes back in line.
+func (h *ReservationHandler) ClaimWaitlistOffer(w http.ResponseWriter, r *http.Request) {
+    e := h.waitlistEntryFor(w, r)
+    if e == nil {
+        return
+    }
+    var req claimWaitlistReq
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        badRequest(w, "invalid json")
+        return
+    }
+    if e.Status != models.WaitlistOffered || e.OfferStart == nil || e.OfferExpiresAt == nil {
+        conflict(w, "no open offer for this waitlist entry")
+        return
+    }
+    if subtle.ConstantTimeCompare([]byte(req.ClaimToken), []byte(e.ClaimToken)) != 1 {
+        forbidden(w, "invalid claim token")
+        return
+    }
+    if time.Now().After(*e.OfferExpiresAt) {
+        conflict(w, "offer has expired")
+        return
+    }
+    restaurant, err := h.restaurants.ByID(e.RestaurantID)
+    if err != nil {
+        notFound(w, "restaurant not found")
+        return
+    }
+    if be := archivedError(restaurant); be != nil {
+        be.write(w)
+        return
+    }
+    res, be := h.bookWaitlisted(restaurant, e, *e.OfferStart)
+    if be != nil {
+        e.Status = models.WaitlistWaiting
+        e.OfferStart, e.OfferExpiresAt, e.ClaimToken = nil, nil, ""
+        _ = h.waitlist.Update(e)
+        if be.code == "" {
+            conflict(w, "the offered table is no longer available")
+            return
+        }
+        be.write(w)
+        return
+    }
+    e.Status = models.WaitlistBooked
+    e.ReservationID = res.ID
+    e.ClaimToken = ""
+    _ = h.waitlist.Update(e)
+    writeJSON(w, http.StatusCreated, res)
+}
+
+// bookWaitlisted reserves the best free tables for the entry's party at
+// start, subject to the same rules, limits and pacing as any other booking.
+func (h *ReservationHandler) bookWaitlisted(restaurant *models.Restaurant, e *models.WaitlistEntry, start time.Time) (*models.Reservation, *bookingError) {
+    req := createReservationReq{Start: start, End: start.Add(e.Duration()), Guests: e.Guests}
+    return h.place(restaurant, req, models.Reservation{UserID: e.UserID, Status: models.StatusConfirmed})
+}
+
+// offerFreedTables hands the time freed by a cancelled reservation to the
+// waitlist, see offerFreedTime.
+func (h *ReservationHandler) offerFreedTables(cancelled *models.Reservation) {
+    now := time.Now()
+    h.offerFreedTime(cancelled.RestaurantID, cancelled.StartTime, cancelled.ClearAt(now), now)
+}
+
+// ExpireWaitlistOffers takes the parties whose offer lapsed by now out of
+// line and passes the time they were offered on to the next party waiting.
+// It returns how many offers expired.
+func (h *ReservationHandler) ExpireWaitlistOffers(now time.Time) (int, error) {
+    expired, err := h.waitlist.ListExpiredOffers(now)
+    if err != nil {
+        return 0, err
+    }
+    for _, cur := range expired {
+        e := *cur
+        start := *e.OfferStart
+        e.Status = models.WaitlistExpired
+        e.ClaimToken = ""
+        if err := h.waitlist.Update(&e); err != nil {
+            return 0, err
+        }
+        h.offerFreedTime(e.RestaurantID, start, start.Add(e.Duration()), now)
+    }
+    return len(expired), nil
+}
+
+// offerFreedTime hands the time freed in [freedStart, freedEnd) to the first
+// waitlisted party that now fits: booked outright for autoBook entries,
+// otherwise offered with a claim token valid for waitlistOfferTTL.
+func (h *ReservationHandler) offerFreedTime(restaurantID string, freedStart, freedEnd, now time.Time) {
+    restaurant, err := h.restaurants.ByID(restaurantID)
+    if err != nil || restaurant.Archived() {
+        return
+    }
+    entries, err := h.waitlistOverlapping(restaurant, freedStart, freedEnd)
+    if err != nil {
+        return
+    }
+    for _, cur := range entries {
+        if !cur.Waiting() {
+            continue
+        }
+        start, ok := h.waitlistStart(restaurant, cur, freedStart, freedEnd, now)
+        if !ok {
+            continue
+        }
+        e := *cur
+        if e.AutoBook {
+            res, be := h.bookWaitlisted(restaurant, &e, start)
+            if be != nil {
+                continue
+            }
+            e.Status = models.WaitlistBooked
+            e.ReservationID = res.ID
+        } else {
+            expires := now.Add(waitlistOfferTTL)
+            e.Status = models.WaitlistOffered
+            e.OfferStart = &start
+            e.OfferExpiresAt = &expires
+            e.ClaimToken = newToken()
+        }
+        _ = h.waitlist.Update(&e)
+        return
+    }
+}
+
+// waitlistOverlapping returns the restaurant's entries, in the order they
+// joined, whose party could sit at some point in [from, to): entries are
+// matched on their window plus dining time rather than on their date, so
+// windows crossing midnight are found too.
+func (h *ReservationHandler) waitlistOverlapping(restaurant *models.Restaurant, from, to time.Time) ([]*models.WaitlistEntry, error) {
+    loc := restaurant.Location()
+    // an entry's window starts at most maxWaitlistWindow plus the longest
+    // dining time before the party sits down
+    first := dayStart(from.Add(-maxWaitlistWindow - maxWaitlistDuration).In(loc))
+    var out []*models.WaitlistEntry
+    for day := first; day.Before(to); day = day.AddDate(0, 0, 1) {
+        list, err := h.waitlist.ListByRestaurant(restaurant.ID, day.Format(dateLayout))
+        if err != nil {
+            return nil, err
+        }
+        for _, e := range list {
+            if e.WindowStart.Before(to) && e.WindowEnd.Add(e.Duration()).After(from) {
+                out = append(out, e)
+            }
+        }
+    }
+    sort.SliceStable(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+    return out, nil
+}
+
+// waitlistStart finds the earliest start in the entry's window that uses the
+// time freed in [freedStart, freedEnd), lies within opening hours, meets the
+// booking rules and pacing and has a table for the party.
+func (h *ReservationHandler) waitlistStart(restaurant *models.Restaurant, e *models.WaitlistEntry, freedStart, freedEnd, now time.Time) (time.Time, bool) {
+    for start := e.WindowStart; !start.After(e.WindowEnd); start = start.Add(waitlistStep) {
+        end := start.Add(e.Duration())
+        if start.Before(now) || !start.Before(freedEnd) || !end.After(freedStart) {
+            continue
+        }
+        if !h.isWithinOperatingHours(restaurant, start, end) || len(checkBookingRules(restaurant, start, e.Guests, now)) > 0 {
+            continue
+        }
+        if h.pacingError(restaurant, start, end, e.Guests, "") != nil {
+            continue
+        }
+        if h.findBestAllocation(restaurant, start, end, e.Guests, "", seatingNeeds{}) != nil {
+            return start, true
+        }
+    }
+    return time.Time{}, false
+}
+
+// newToken returns a random URL-safe secret.
+func newToken() string {
+    b := make([]byte, 16)
+    if _, err := rand.Read(b); err != nil {
+        panic(err)
+    }
+    return hex.EncodeToString(b)
+}
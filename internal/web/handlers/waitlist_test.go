@@ -0,0 +1,70 @@
+package handlers
+
+import (
+    "testing"
+    "time"
+
+    "orderation/internal/models"
+    "orderation/internal/store/memory"
+)
+
+func TestWaitlistOverlapping(t *testing.T) {
+    waitlist := memory.NewWaitlistStore()
+    h := &ReservationHandler{waitlist: waitlist}
+    restaurant := &models.Restaurant{ID: "r", TimeZone: "UTC"}
+    at := func(day, hour, min int) time.Time { return time.Date(2026, 3, day, hour, min, 0, 0, time.UTC) }
+    add := func(name string, start, end time.Time, minutes int) {
+        waitlist.Create(&models.WaitlistEntry{ID: name, RestaurantID: "r", Date: start.Format(dateLayout), WindowStart: start, WindowEnd: end, DurationMinutes: minutes, Status: models.WaitlistWaiting})
+    }
+    add("late", at(3, 22, 0), at(3, 23, 30), 120) // window runs towards midnight
+    add("early", at(3, 18, 0), at(3, 19, 0), 90)  // done well before
+    add("next", at(4, 0, 15), at(4, 1, 0), 60)    // dated the day of the freed time
+    add("later", at(4, 12, 0), at(4, 13, 0), 60)  // starts after it
+
+    // a late booking freed from 00:00, with turnover, to 01:00
+    list, err := h.waitlistOverlapping(restaurant, at(4, 0, 0), at(4, 1, 0))
+    if err != nil {
+        t.Fatal(err)
+    }
+    var got []string
+    for _, e := range list {
+        got = append(got, e.ID)
+    }
+    if len(got) != 2 || got[0] != "late" || got[1] != "next" {
+        t.Fatalf("expected the entries around midnight, got %v", got)
+    }
+}
+
+func TestExpireWaitlistOffers(t *testing.T) {
+    restaurants, tables, hours, waitlist := memory.NewRestaurantStore(), memory.NewTableStore(), memory.NewHoursStore(), memory.NewWaitlistStore()
+    h := NewReservationHandler(memory.NewReservationStore(), restaurants, tables, memory.NewUserStore(), hours, memory.NewExceptionStore(), memory.NewTableCombinationStore(), waitlist, memory.NewSeriesStore(), memory.NewTableBlockStore(), DefaultBookingLimits)
+    restaurant := &models.Restaurant{Name: "r", TimeZone: "UTC"}
+    restaurants.Create(restaurant)
+    tables.Create(&models.Table{RestaurantID: restaurant.ID, Name: "T1", Capacity: 4})
+    for d := time.Sunday; d <= time.Saturday; d++ {
+        hours.Create(&models.ServicePeriod{RestaurantID: restaurant.ID, Weekday: d, OpenTime: "00:00", CloseTime: "00:00"})
+    }
+    now := time.Now()
+    start := now.Truncate(time.Hour).Add(24 * time.Hour)
+    lapsed := now.Add(-time.Minute)
+    add := func(e *models.WaitlistEntry) *models.WaitlistEntry {
+        e.RestaurantID, e.Date, e.WindowStart, e.WindowEnd, e.Guests, e.DurationMinutes = restaurant.ID, start.Format(dateLayout), start, start.Add(time.Hour), 2, 60
+        waitlist.Create(e)
+        return e
+    }
+    first := add(&models.WaitlistEntry{Status: models.WaitlistOffered, OfferStart: &start, OfferExpiresAt: &lapsed, ClaimToken: "t"})
+    second := add(&models.WaitlistEntry{Status: models.WaitlistWaiting})
+
+    if n, err := h.ExpireWaitlistOffers(now); err != nil || n != 1 {
+        t.Fatalf("expected one expired offer, got %d (%v)", n, err)
+    }
+    if e, _ := waitlist.ByID(first.ID); e.Status != models.WaitlistExpired || e.ClaimToken != "" {
+        t.Fatalf("expected the lapsed party out of line, got %+v", e)
+    }
+    if e, _ := waitlist.ByID(second.ID); e.Status != models.WaitlistOffered || e.OfferStart == nil || !e.OfferStart.Equal(start) {
+        t.Fatalf("expected the next party to be offered the table, got %+v", e)
+    }
+    if n, _ := h.ExpireWaitlistOffers(now); n != 0 {
+        t.Fatalf("expected nothing left to expire, got %d", n)
+    }
+}
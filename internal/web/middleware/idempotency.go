@@ -0,0 +1,149 @@
+package middleware
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "io"
+    "log"
+    "net/http"
+    "time"
+
+    "orderation/internal/auth"
+    "orderation/internal/models"
+    "orderation/internal/store"
+)
+
+// maxIdempotencyKeyLen bounds the Idempotency-Key header value.
+const maxIdempotencyKeyLen = 255
+
+// maxIdempotentBody bounds the request body read to hash a keyed request.
+const maxIdempotentBody = 1 << 20
+
+// Idempotency returns middleware that honors an Idempotency-Key header on
+// POST, PATCH and DELETE requests. The first response for a user and key is
+// stored for ttl and replayed to retries with an Idempotent-Replayed header.
+// Reusing a key for a different request is rejected with 422, and a retry
+// that arrives while the first request is still running gets 409. Server
+// errors are not stored, so the request can be retried with the same key.
+//
+// Keys are scoped to the user of a valid bearer token. Requests without one
+// are scoped to the request itself, so an anonymous retry is only replayed a
+// response to an identical request (for a guest booking, one carrying the
+// same contact details) and a reused key is never rejected with 422.
+func Idempotency(tm *auth.TokenManager, st store.IdempotencyStore, ttl time.Duration) func(http.Handler) http.Handler {
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            key := r.Header.Get("Idempotency-Key")
+            if key == "" || (r.Method != "POST" && r.Method != "PATCH" && r.Method != "DELETE") {
+                next.ServeHTTP(w, r)
+                return
+            }
+            if len(key) > maxIdempotencyKeyLen {
+                http.Error(w, "Idempotency-Key is too long", http.StatusBadRequest)
+                return
+            }
+            body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBody))
+            if err != nil {
+                var tooLarge *http.MaxBytesError
+                if errors.As(err, &tooLarge) {
+                    http.Error(w, "request body is too large", http.StatusRequestEntityTooLarge)
+                    return
+                }
+                http.Error(w, "could not read request body", http.StatusBadRequest)
+                return
+            }
+            r.Body = io.NopCloser(bytes.NewReader(body))
+
+            sum := sha256.New()
+            io.WriteString(sum, r.Method+" "+r.URL.RequestURI()+"\n")
+            sum.Write(body)
+            hash := hex.EncodeToString(sum.Sum(nil))
+            scope := idempotencyScope(tm, r)
+            if scope == "" {
+                scope = "anon/" + hash
+            }
+            now := time.Now()
+            rec := &models.IdempotencyRecord{
+                Key:         scope + ":" + key,
+                RequestHash: hash,
+                CreatedAt:   now,
+                ExpiresAt:   now.Add(ttl),
+            }
+            prev, err := st.Begin(rec)
+            if err != nil {
+                http.Error(w, "could not check Idempotency-Key", http.StatusInternalServerError)
+                return
+            }
+            if prev != nil {
+                switch {
+                case prev.RequestHash != rec.RequestHash:
+                    http.Error(w, "Idempotency-Key was already used for a different request", http.StatusUnprocessableEntity)
+                case !prev.Completed:
+                    http.Error(w, "a request with this Idempotency-Key is still in progress", http.StatusConflict)
+                default:
+                    if prev.ContentType != "" {
+                        w.Header().Set("Content-Type", prev.ContentType)
+                    }
+                    w.Header().Set("Idempotent-Replayed", "true")
+                    w.WriteHeader(prev.StatusCode)
+                    w.Write(prev.Body)
+                }
+                return
+            }
+
+            rw := &recordingWriter{ResponseWriter: w, status: http.StatusOK}
+            next.ServeHTTP(rw, r)
+            if rw.status >= 500 {
+                if err := st.Abandon(rec.Key); err != nil {
+                    log.Printf("[warn] releasing idempotency key: %v", err)
+                }
+                return
+            }
+            rec.StatusCode = rw.status
+            rec.ContentType = w.Header().Get("Content-Type")
+            rec.Body = rw.body.Bytes()
+            if err := st.Complete(rec); err != nil {
+                log.Printf("[warn] storing idempotent response: %v", err)
+            }
+        })
+    }
+}
+
+// idempotencyScope is the user ID of the request's bearer token, or "" when
+// there is no valid token.
+func idempotencyScope(tm *auth.TokenManager, r *http.Request) string {
+    token := parseAuthHeader(r.Header.Get("Authorization"))
+    if token == "" {
+        return ""
+    }
+    c, err := tm.Verify(token)
+    if err != nil {
+        return ""
+    }
+    return c.Sub
+}
+
+// recordingWriter passes a response through while keeping a copy of its
+// status and body.
+type recordingWriter struct {
+    http.ResponseWriter
+    status      int
+    wroteHeader bool
+    body        bytes.Buffer
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+    if !w.wroteHeader {
+        w.status = status
+        w.wroteHeader = true
+    }
+    w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+    w.wroteHeader = true
+    w.body.Write(b)
+    return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+
+    "orderation/internal/auth"
+    "orderation/internal/store/memory"
+)
+
+func TestIdempotencyReplaysFirstResponse(t *testing.T) {
+    tm := auth.NewTokenManager("test-secret")
+    calls := 0
+    h := Idempotency(tm, memory.NewIdempotencyStore(), time.Hour)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        calls++
+        w.Header().Set("Content-Type", "application/json")
+        w.WriteHeader(http.StatusCreated)
+        fmt.Fprintf(w, `{"call":%d}`, calls)
+    }))
+    tok, _ := tm.Sign("u1", "user", time.Hour)
+    do := func(key, body, token string) *httptest.ResponseRecorder {
+        req := httptest.NewRequest("POST", "/things", strings.NewReader(body))
+        if key != "" { req.Header.Set("Idempotency-Key", key) }
+        if token != "" { req.Header.Set("Authorization", "Bearer "+token) }
+        rec := httptest.NewRecorder()
+        h.ServeHTTP(rec, req)
+        return rec
+    }
+
+    first := do("k1", `{"a":1}`, tok)
+    retry := do("k1", `{"a":1}`, tok)
+    if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() { t.Fatalf("expected replay of %q, got %d %q", first.Body.String(), retry.Code, retry.Body.String()) }
+    if retry.Header().Get("Idempotent-Replayed") != "true" { t.Fatalf("expected Idempotent-Replayed header on replay") }
+    if calls != 1 { t.Fatalf("expected handler to run once, ran %d times", calls) }
+    if rec := do("k1", `{"a":2}`, tok); rec.Code != http.StatusUnprocessableEntity { t.Fatalf("expected 422 for reused key, got %d", rec.Code) }
+    // the same key from another user or without a key is a new request
+    if rec := do("k1", `{"a":1}`, ""); rec.Body.String() != `{"call":2}` { t.Fatalf("expected anonymous request to run, got %q", rec.Body.String()) }
+    if rec := do("", `{"a":1}`, tok); rec.Body.String() != `{"call":3}` { t.Fatalf("expected request without key to run, got %q", rec.Body.String()) }
+    // anonymous keys only match identical requests
+    if rec := do("k1", `{"a":1}`, ""); rec.Body.String() != `{"call":2}` { t.Fatalf("expected anonymous retry to be replayed, got %q", rec.Body.String()) }
+    if rec := do("k1", `{"a":3}`, ""); rec.Code != http.StatusCreated || rec.Body.String() != `{"call":4}` { t.Fatalf("expected another guest's request with the same key to run, got %d %q", rec.Code, rec.Body.String()) }
+    if rec := do("k2", strings.Repeat("x", maxIdempotentBody+1), ""); rec.Code != http.StatusRequestEntityTooLarge || calls != 4 { t.Fatalf("expected 413 for an oversized body, got %d", rec.Code) }
+}
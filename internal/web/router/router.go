@@ -23,7 +23,8 @@ type segment struct {
 }
 
 type Router struct {
-    routes []route
+    routes     []route
+    middleware []func(http.Handler) http.Handler
 }
 
 func New(mux *http.ServeMux) *Router {
@@ -32,6 +33,12 @@ func New(mux *http.ServeMux) *Router {
     return r
 }
 
+// Use adds middleware that wraps every matched route. It runs after CORS
+// headers are set and path params are attached, in the order added.
+func (r *Router) Use(mw func(http.Handler) http.Handler) {
+    r.middleware = append(r.middleware, mw)
+}
+
 func (r *Router) Handle(method, pattern string, handler http.Handler) {
     segs := parsePattern(pattern)
     r.routes = append(r.routes, route{method: method, pattern: pattern, segments: segs, handler: handler})
@@ -40,8 +47,8 @@ func (r *Router) Handle(method, pattern string, handler http.Handler) {
 func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
     // Set CORS headers for all requests
     w.Header().Set("Access-Control-Allow-Origin", "*")
-    w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-    w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+    w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+    w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Idempotency-Key")
     
     // Handle preflight requests
     if req.Method == "OPTIONS" {
@@ -60,7 +67,11 @@ func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
         }
         // attach params to context
         ctx := context.WithValue(req.Context(), paramsKey, params)
-        rt.handler.ServeHTTP(w, req.WithContext(ctx))
+        handler := rt.handler
+        for i := len(r.middleware) - 1; i >= 0; i-- {
+            handler = r.middleware[i](handler)
+        }
+        handler.ServeHTTP(w, req.WithContext(ctx))
         return
     }
     http.NotFound(w, req)
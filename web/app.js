@@ -48,7 +48,9 @@ async function apiCall(url, options = {}) {
         }
         
         if (!response.ok) {
-            throw new Error(data.error || `HTTP ${response.status}: ${response.statusText}`);
+            const error = new Error(data.error || `HTTP ${response.status}: ${response.statusText}`);
+            error.code = data.code;
+            throw error;
         }
         
         return data;
@@ -222,20 +224,37 @@ async function loadRestaurants() {
                 <p><strong>ID:</strong> ${restaurant.id}</p>
                 <p><strong>地址:</strong> ${restaurant.address}</p>
                 <p><strong>营业时间:</strong> ${restaurant.openTime} - ${restaurant.closeTime}</p>
+                <div id="exceptions-${restaurant.id}"></div>
                 <p><strong>创建时间:</strong> ${new Date(restaurant.createdAt).toLocaleString()}</p>
                 ${currentUser && currentUser.role === 'admin' ? 
-                    `<button class="delete" onclick="deleteRestaurant('${restaurant.id}', '${restaurant.name}')">删除餐厅</button>` : 
+                    `<button class="delete" onclick="deleteRestaurant('${restaurant.id}', '${restaurant.name}')">归档餐厅</button>` : 
                     ''
                 }
             </div>
         `).join('');
         
         showResult('restaurantResult', `加载了 ${restaurants.length} 个餐厅`);
+        restaurants.forEach(restaurant => loadUpcomingExceptions(restaurant.id));
     } catch (error) {
         showResult('restaurantResult', `加载餐厅失败: ${error.message}`, true);
     }
 }
 
+// Show upcoming holiday closures and special hours under a restaurant
+async function loadUpcomingExceptions(restaurantId) {
+    try {
+        const exceptions = await apiCall(`/restaurants/${restaurantId}/exceptions`);
+        const container = document.getElementById(`exceptions-${restaurantId}`);
+        if (!container || !exceptions || exceptions.length === 0) {
+            return;
+        }
+        container.innerHTML = `<p><strong>特殊营业安排:</strong></p><ul>` + exceptions.map(e => `
+            <li>${e.date}: ${e.closed ? '休息' : `${e.openTime} - ${e.closeTime}`}${e.note ? `（${e.note}）` : ''}</li>
+        `).join('') + `</ul>`;
+    } catch (error) {
+        console.error('Failed to load exceptions:', error);
+    }
+}
 
 async function createRestaurant(event) {
     event.preventDefault();
@@ -258,7 +277,7 @@ async function createRestaurant(event) {
 }
 
 async function deleteRestaurant(restaurantId, restaurantName) {
-    if (!confirm(`确定要删除餐厅 "${restaurantName}" 吗？\n\n注意：删除餐厅后，相关的桌台和预订信息可能会受到影响。`)) {
+    if (!confirm(`确定要归档餐厅 "${restaurantName}" 吗？\n\n归档后餐厅不再接受新预订，已有的桌台和预订信息会保留，可以恢复。`)) {
         return;
     }
     
@@ -268,10 +287,10 @@ async function deleteRestaurant(restaurantId, restaurantName) {
             method: 'DELETE'
         });
         
-        showResult('restaurantResult', `餐厅 "${restaurantName}" 已成功删除`);
+        showResult('restaurantResult', `餐厅 "${restaurantName}" 已归档`);
         loadRestaurants();
     } catch (error) {
-        showResult('restaurantResult', `删除餐厅失败: ${error.message}`, true);
+        showResult('restaurantResult', `归档餐厅失败: ${error.message}`, true);
     }
 }
 
@@ -324,6 +343,7 @@ async function createTable(event) {
     const formData = new FormData(event.target);
     const data = Object.fromEntries(formData);
     data.capacity = parseInt(data.capacity);
+    data.accessible = formData.has('accessible');
     
     try {
         clearResult('tablesResult');
@@ -342,6 +362,73 @@ async function createTable(event) {
 
 
 // Reservation functions
+
+// Limit the date pickers and party size to the restaurant's booking rules
+async function loadBookingWindow(form) {
+    const restaurantId = form.restaurantId.value.trim();
+    if (!restaurantId) {
+        return;
+    }
+    try {
+        const details = await apiCall(`/restaurants/${restaurantId}/details`);
+        const bookingWindow = details.booking || {};
+        const toLocalInput = (iso) => {
+            const d = new Date(iso);
+            d.setMinutes(d.getMinutes() - d.getTimezoneOffset());
+            return d.toISOString().slice(0, 16);
+        };
+        for (const input of [form.startTime, form.endTime]) {
+            input.min = bookingWindow.earliestStart ? toLocalInput(bookingWindow.earliestStart) : '';
+            input.max = bookingWindow.latestStart ? toLocalInput(bookingWindow.latestStart) : '';
+        }
+        form.startTime.step = bookingWindow.startIntervalMinutes ? bookingWindow.startIntervalMinutes * 60 : '';
+        form.guests.min = bookingWindow.minPartySize || 1;
+        form.guests.max = bookingWindow.maxPartySize || '';
+    } catch (error) {
+        // unknown restaurant; the booking request reports it
+    }
+}
+
+// Checkout hold: keeps the chosen table while the booking form is filled in
+let currentHold = null;
+
+async function holdReservation(form) {
+    const data = Object.fromEntries(new FormData(form));
+    if (!data.restaurantId || !data.startTime || !data.endTime || !data.guests) {
+        return;
+    }
+    await releaseHold();
+    try {
+        currentHold = await apiCall(`/restaurants/${data.restaurantId}/holds`, {
+            method: 'POST',
+            body: JSON.stringify({
+                tableId: data.tableId || "",
+                start: new Date(data.startTime).toISOString(),
+                end: new Date(data.endTime).toISOString(),
+                guests: parseInt(data.guests)
+            })
+        });
+        const until = new Date(currentHold.holdExpiresAt).toLocaleTimeString();
+        showResult('reservationResult', `已为您保留桌台至 ${until}，请尽快提交预订`);
+    } catch (error) {
+        currentHold = null;
+        showResult('reservationResult', `暂时无法保留桌台: ${error.message}`, true);
+    }
+}
+
+async function releaseHold() {
+    if (!currentHold) {
+        return;
+    }
+    const holdId = currentHold.id;
+    currentHold = null;
+    try {
+        await apiCall(`/holds/${holdId}`, { method: 'DELETE' });
+    } catch (error) {
+        // already expired or swept
+    }
+}
+
 async function createReservation(event) {
     event.preventDefault();
     const formData = new FormData(event.target);
@@ -353,18 +440,27 @@ async function createReservation(event) {
         delete data.tableId;
     }
     
+    // Confirm the hold placed for this form if it is still valid
+    const hold = currentHold;
+    const body = hold && hold.restaurantId === data.restaurantId && new Date(hold.holdExpiresAt) > new Date()
+        ? { holdId: hold.id }
+        : {
+            tableId: data.tableId || "",
+            start: new Date(data.startTime).toISOString(),
+            end: new Date(data.endTime).toISOString(),
+            guests: data.guests
+        };
+    body.occasion = data.occasion || "";
+    body.notes = data.notes || "";
+    
     try {
         clearResult('reservationResult');
         const result = await apiCall(`/restaurants/${data.restaurantId}/reservations`, {
             method: 'POST',
-            body: JSON.stringify({
-                tableId: data.tableId || "",
-                start: new Date(data.startTime).toISOString(),
-                end: new Date(data.endTime).toISOString(),
-                guests: data.guests
-            })
+            body: JSON.stringify(body)
         });
         
+        currentHold = null;
         showResult('reservationResult', `预订创建成功！预订ID: ${result.id}`);
         event.target.reset();
     } catch (error) {
@@ -391,6 +487,8 @@ async function loadMyReservations() {
                 <p><strong>时间:</strong> ${new Date(reservation.startTime).toLocaleString()} - ${new Date(reservation.endTime).toLocaleString()}</p>
                 <p><strong>人数:</strong> ${reservation.guests} 人</p>
                 <p><strong>状态:</strong> ${getStatusText(reservation.status)}</p>
+                ${reservation.occasion ? `<p><strong>场合:</strong> ${reservation.occasion}</p>` : ''}
+                ${reservation.notes ? `<p><strong>备注:</strong> ${reservation.notes}</p>` : ''}
                 <p><strong>创建时间:</strong> ${new Date(reservation.createdAt).toLocaleString()}</p>
                 ${reservation.status !== 'cancelled' ? 
                     `<button class="delete" onclick="cancelReservation('${reservation.id}')">取消预订</button>` : 
@@ -418,12 +516,30 @@ async function cancelReservation(reservationId) {
         showResult('reservationResult', '预订已取消');
         loadMyReservations();
     } catch (error) {
+        // late cancellations need a reason
+        if (error.code === 'cancel_reason_required') {
+            const reason = prompt('已过免费取消时间，请填写取消原因：');
+            if (reason) {
+                try {
+                    await apiCall(`/reservations/${reservationId}`, {
+                        method: 'DELETE',
+                        body: JSON.stringify({ reason })
+                    });
+                    showResult('reservationResult', '预订已取消（逾期取消）');
+                    loadMyReservations();
+                    return;
+                } catch (retryError) {
+                    error = retryError;
+                }
+            }
+        }
         showResult('reservationResult', `取消预订失败: ${error.message}`, true);
     }
 }
 
 function getStatusText(status) {
     const statusMap = {
+        'held': '⏸️ 保留中',
         'confirmed': '✅ 已确认',
         'pending': '⏳ 待确认', 
         'cancelled': '❌ 已取消',